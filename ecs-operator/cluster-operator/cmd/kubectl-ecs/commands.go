@@ -0,0 +1,432 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+	"github.com/samuel/go-zookeeper/zk"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	componentBookie     = "bookie"
+	componentController = "controller"
+	componentNode       = "node"
+)
+
+var components = []string{componentBookie, componentController, componentNode}
+
+func checkComponent(component string) error {
+	if !util.ContainsString(components, component) {
+		return fmt.Errorf("unknown component (%s), must be one of: %s", component, strings.Join(components, ", "))
+	}
+	return nil
+}
+
+// getCluster returns the cluster selected by the global flags, with the
+// defaults applied the same way the operator does. It must not be updated,
+// since the defaults would be persisted in the spec; see updateCluster
+func (c *pluginContext) getCluster() (p *v1alpha1.ECSCluster, err error) {
+	p, err = c.findCluster()
+	if err != nil {
+		return nil, err
+	}
+	p.WithDefaults()
+	return p, nil
+}
+
+// updateCluster applies the change to the cluster selected by the global
+// flags, as stored, and updates it. The sections of the spec that the change
+// touches are created when missing, the operator defaults the rest
+func (c *pluginContext) updateCluster(change func(p *v1alpha1.ECSCluster)) (*v1alpha1.ECSCluster, error) {
+	p, err := c.findCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Spec.Bookkeeper == nil {
+		p.Spec.Bookkeeper = &v1alpha1.BookkeeperSpec{}
+	}
+	if p.Spec.ECS == nil {
+		p.Spec.ECS = &v1alpha1.ECSSpec{}
+	}
+	change(p)
+
+	err = c.client.Update(context.TODO(), p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ecs cluster (%s): %v", p.Name, err)
+	}
+	return p, nil
+}
+
+// findCluster returns the cluster selected by the global flags. When no name
+// is given, the namespace must contain exactly one cluster
+func (c *pluginContext) findCluster() (*v1alpha1.ECSCluster, error) {
+	p := &v1alpha1.ECSCluster{}
+	if c.clusterName != "" {
+		err := c.client.Get(context.TODO(), types.NamespacedName{Name: c.clusterName, Namespace: c.namespace}, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ecs cluster (%s): %v", c.clusterName, err)
+		}
+		return p, nil
+	}
+
+	clusterList := &v1alpha1.ECSClusterList{}
+	err := c.client.List(context.TODO(), &client.ListOptions{Namespace: c.namespace}, clusterList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ecs clusters: %v", err)
+	}
+
+	switch len(clusterList.Items) {
+	case 0:
+		return nil, fmt.Errorf("no ecs cluster found in namespace (%s)", c.namespace)
+	case 1:
+		return &clusterList.Items[0], nil
+	default:
+		return nil, fmt.Errorf("namespace (%s) has %d ecs clusters, select one with -c", c.namespace, len(clusterList.Items))
+	}
+}
+
+func labelsForComponent(p *v1alpha1.ECSCluster, component string) map[string]string {
+	switch component {
+	case componentBookie:
+		return util.LabelsForBookie(p)
+	case componentController:
+		return util.LabelsForController(p)
+	default:
+		return util.LabelsForNode(p)
+	}
+}
+
+func (c *pluginContext) listPods(p *v1alpha1.ECSCluster, component string) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	listOps := &client.ListOptions{
+		Namespace:     p.Namespace,
+		LabelSelector: labels.SelectorFromSet(labelsForComponent(p, component)),
+	}
+	err := c.client.List(context.TODO(), listOps, podList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s pods: %v", component, err)
+	}
+
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+func runStatus(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
+
+	p, err := c.getCluster()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster:   %s/%s\n", p.Namespace, p.Name)
+	fmt.Printf("Zookeeper: %s\n", p.Spec.ZookeeperUri)
+	fmt.Printf("Ready:     %d/%d\n\n", p.Status.ReadyReplicas, p.Status.Replicas)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tDESIRED\tREADY\tVERSIONS")
+	for _, component := range components {
+		pods, err := c.listPods(p, component)
+		if err != nil {
+			return err
+		}
+
+		ready := 0
+		versions := map[string]string{}
+		for i := range pods {
+			if util.IsPodReady(&pods[i]) {
+				ready++
+			}
+			for _, container := range pods[i].Spec.Containers {
+				versions[container.Image] = ""
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", component, desiredReplicas(p, component), ready,
+			strings.Join(util.SortedKeys(versions), ","))
+	}
+	w.Flush()
+
+	if len(p.Status.Conditions) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CONDITION\tSTATUS\tREASON\tMESSAGE")
+		for _, condition := range p.Status.Conditions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+func desiredReplicas(p *v1alpha1.ECSCluster, component string) int32 {
+	switch component {
+	case componentBookie:
+		return p.Spec.Bookkeeper.Replicas
+	case componentController:
+		return p.Spec.ECS.ControllerReplicas
+	default:
+		return p.Spec.ECS.NodeReplicas
+	}
+}
+
+func runScale(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("scale", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: kubectl ecs scale <component> <replicas>")
+	}
+	component := flags.Arg(0)
+	if err := checkComponent(component); err != nil {
+		return err
+	}
+	replicas, err := strconv.Atoi(flags.Arg(1))
+	if err != nil || replicas < 0 {
+		return fmt.Errorf("invalid number of replicas (%s)", flags.Arg(1))
+	}
+
+	p, err := c.updateCluster(func(p *v1alpha1.ECSCluster) {
+		switch component {
+		case componentBookie:
+			p.Spec.Bookkeeper.Replicas = int32(replicas)
+		case componentController:
+			p.Spec.ECS.ControllerReplicas = int32(replicas)
+		default:
+			p.Spec.ECS.NodeReplicas = int32(replicas)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ecs cluster %s: %s scaled to %d\n", p.Name, component, replicas)
+	return nil
+}
+
+func runUpgrade(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	version := flags.String("version", "", "Image tag to upgrade to")
+	ecsOnly := flags.Bool("ecs-only", false, "Upgrade the ECS image only and keep the BookKeeper image")
+	flags.Parse(args)
+
+	if *version == "" {
+		return fmt.Errorf("usage: kubectl ecs upgrade --version <version>")
+	}
+
+	p, err := c.updateCluster(func(p *v1alpha1.ECSCluster) {
+		if p.Spec.ECS.Image == nil {
+			p.Spec.ECS.Image = &v1alpha1.ECSImageSpec{}
+		}
+		p.Spec.ECS.Image.Tag = *version
+		if !*ecsOnly {
+			if p.Spec.Bookkeeper.Image == nil {
+				p.Spec.Bookkeeper.Image = &v1alpha1.BookkeeperImageSpec{}
+			}
+			p.Spec.Bookkeeper.Image.Tag = *version
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ecs cluster %s: upgrading to %s\n", p.Name, *version)
+	return nil
+}
+
+func runRestart(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("restart", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl ecs restart <component>")
+	}
+	component := flags.Arg(0)
+	if err := checkComponent(component); err != nil {
+		return err
+	}
+
+	// The operator restarts the pods one at a time when the restartedAt
+	// field of their component changes
+	restartedAt := time.Now().Format(time.RFC3339)
+	p, err := c.updateCluster(func(p *v1alpha1.ECSCluster) {
+		switch component {
+		case componentBookie:
+			p.Spec.Bookkeeper.RestartedAt = restartedAt
+		case componentController:
+			p.Spec.ECS.ControllerRestartedAt = restartedAt
+		default:
+			p.Spec.ECS.NodeRestartedAt = restartedAt
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restart %s: %v", component, err)
+	}
+	fmt.Printf("ecs cluster %s: %s restarting\n", p.Name, component)
+	return nil
+}
+
+func runLogs(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("f", false, "Stream the logs")
+	tail := flags.Int64("tail", -1, "Number of recent lines to print per pod. All lines when negative")
+	previous := flags.Bool("previous", false, "Print the logs of the previous container instance")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl ecs logs [-f] [--tail N] <component>")
+	}
+	component := flags.Arg(0)
+	if err := checkComponent(component); err != nil {
+		return err
+	}
+
+	p, err := c.getCluster()
+	if err != nil {
+		return err
+	}
+
+	pods, err := c.listPods(p, component)
+	if err != nil {
+		return err
+	}
+
+	opts := &corev1.PodLogOptions{Follow: *follow, Previous: *previous}
+	if *tail >= 0 {
+		opts.TailLines = tail
+	}
+
+	names := make([]string, len(pods))
+	for i := range pods {
+		names[i] = pods[i].Name
+	}
+	return printLogs(os.Stdout, names, func(name string) (io.ReadCloser, error) {
+		return c.kubeClient.CoreV1().Pods(p.Namespace).GetLogs(name, opts).Stream()
+	})
+}
+
+// printLogs prints the lines of every pod, interleaved and prefixed with the
+// pod name, and fails if the logs of any pod could not be read
+func printLogs(w io.Writer, pods []string, open func(name string) (io.ReadCloser, error)) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(pods))
+	for _, pod := range pods {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			stream, err := open(name)
+			if err != nil {
+				errs <- fmt.Errorf("failed to get logs of pod (%s): %v", name, err)
+				return
+			}
+			defer stream.Close()
+
+			scanner := bufio.NewScanner(stream)
+			for scanner.Scan() {
+				mu.Lock()
+				fmt.Fprintf(w, "[%s] %s\n", name, scanner.Text())
+				mu.Unlock()
+			}
+			if err := scanner.Err(); err != nil {
+				errs <- fmt.Errorf("failed to read logs of pod (%s): %v", name, err)
+			}
+		}(pod)
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		failed++
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to get the logs of %d of %d pods", failed, len(pods))
+	}
+	return nil
+}
+
+func runZk(c *pluginContext, args []string) error {
+	flags := flag.NewFlagSet("zk", flag.ExitOnError)
+	zkUri := flags.String("zk", "", "ZooKeeper address to connect to. Defaults to the address in the cluster spec")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || flags.Arg(0) != "tree" {
+		return fmt.Errorf("usage: kubectl ecs zk [--zk host:port] tree")
+	}
+
+	p, err := c.getCluster()
+	if err != nil {
+		return err
+	}
+
+	if *zkUri == "" {
+		*zkUri = p.Spec.ZookeeperUri
+	}
+	conn, _, err := zk.Connect([]string{*zkUri}, time.Second*5)
+	if err != nil {
+		return fmt.Errorf("failed to connect to zookeeper: %v", err)
+	}
+	defer conn.Close()
+
+	root := fmt.Sprintf("/%s/%s", util.ECSPath, p.Name)
+	tree, err := util.ListSubTreeBFS(conn, root)
+	if err != nil {
+		return fmt.Errorf("failed to construct BFS tree: %v", err)
+	}
+
+	var paths [][]string
+	for e := tree.Front(); e != nil; e = e.Next() {
+		paths = append(paths, strings.Split(e.Value.(string), "/"))
+	}
+	sort.Slice(paths, func(i, j int) bool { return lessPath(paths[i], paths[j]) })
+
+	depth := len(strings.Split(root, "/"))
+	for _, path := range paths {
+		if len(path) == depth {
+			fmt.Println(root)
+			continue
+		}
+		fmt.Printf("%s%s\n", strings.Repeat("  ", len(path)-depth), path[len(path)-1])
+	}
+	return nil
+}
+
+// lessPath orders znode paths depth-first, so that children follow their parent
+func lessPath(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,160 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commands", func() {
+	var (
+		c  *pluginContext
+		nn = types.NamespacedName{Name: "example", Namespace: "default"}
+	)
+
+	BeforeEach(func() {
+		p := &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nn.Name,
+				Namespace: nn.Namespace,
+			},
+		}
+		scheme.Scheme.AddKnownTypes(v1alpha1.SchemeGroupVersion, p, &v1alpha1.ECSClusterList{})
+		c = &pluginContext{
+			client:    fake.NewFakeClient(p),
+			namespace: nn.Namespace,
+		}
+	})
+
+	get := func() *v1alpha1.ECSCluster {
+		p := &v1alpha1.ECSCluster{}
+		Ω(c.client.Get(context.TODO(), nn, p)).Should(Succeed())
+		return p
+	}
+
+	Context("Scale", func() {
+		It("should only set the replicas of the component", func() {
+			Ω(runScale(c, []string{componentNode, "5"})).Should(Succeed())
+			p := get()
+			Ω(p.Spec.ECS.NodeReplicas).Should(BeEquivalentTo(5))
+			Ω(p.Spec.ECS.ControllerReplicas).Should(BeZero())
+			Ω(p.Spec.ECS.Image).Should(BeNil())
+			Ω(p.Spec.Bookkeeper.Replicas).Should(BeZero())
+			Ω(p.Spec.ZookeeperUri).Should(BeEmpty())
+			Ω(p.Spec.ExternalAccess).Should(BeNil())
+		})
+
+		It("should reject an unknown component", func() {
+			Ω(runScale(c, []string{"zookeeper", "3"})).ShouldNot(Succeed())
+		})
+	})
+
+	Context("Upgrade", func() {
+		It("should only set the image tags", func() {
+			Ω(runUpgrade(c, []string{"--version", "0.5.0"})).Should(Succeed())
+			p := get()
+			Ω(p.Spec.ECS.Image.Tag).Should(Equal("0.5.0"))
+			Ω(p.Spec.ECS.Image.Repository).Should(BeEmpty())
+			Ω(p.Spec.Bookkeeper.Image.Tag).Should(Equal("0.5.0"))
+			Ω(p.Spec.Bookkeeper.Image.Repository).Should(BeEmpty())
+		})
+
+		It("should keep the BookKeeper image with --ecs-only", func() {
+			Ω(runUpgrade(c, []string{"--version", "0.5.0", "--ecs-only"})).Should(Succeed())
+			p := get()
+			Ω(p.Spec.ECS.Image.Tag).Should(Equal("0.5.0"))
+			Ω(p.Spec.Bookkeeper.Image).Should(BeNil())
+		})
+	})
+
+	Context("Restart", func() {
+		It("should set the restartedAt field of the component", func() {
+			Ω(runRestart(c, []string{componentBookie})).Should(Succeed())
+			p := get()
+			Ω(p.Spec.Bookkeeper.RestartedAt).ShouldNot(BeEmpty())
+			Ω(p.Spec.ECS.ControllerRestartedAt).Should(BeEmpty())
+			Ω(p.Spec.ECS.NodeRestartedAt).Should(BeEmpty())
+		})
+
+		It("should set the restartedAt field of the controllers", func() {
+			Ω(runRestart(c, []string{componentController})).Should(Succeed())
+			p := get()
+			Ω(p.Spec.ECS.ControllerRestartedAt).ShouldNot(BeEmpty())
+			Ω(p.Spec.ECS.NodeRestartedAt).Should(BeEmpty())
+		})
+	})
+
+	Context("Cluster selection", func() {
+		It("should fail when the namespace has no cluster", func() {
+			c.namespace = "other"
+			_, err := c.findCluster()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail when the named cluster does not exist", func() {
+			c.clusterName = "missing"
+			_, err := c.findCluster()
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
+
+var _ = Describe("Logs", func() {
+	It("should prefix every line with the pod name", func() {
+		var out bytes.Buffer
+		err := printLogs(&out, []string{"node-0"}, func(name string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader("first\nsecond\n")), nil
+		})
+		Ω(err).Should(BeNil())
+		Ω(out.String()).Should(Equal("[node-0] first\n[node-0] second\n"))
+	})
+
+	It("should fail when a stream cannot be opened", func() {
+		var out bytes.Buffer
+		err := printLogs(&out, []string{"node-0", "node-1"}, func(name string) (io.ReadCloser, error) {
+			if name == "node-1" {
+				return nil, fmt.Errorf("pod not found")
+			}
+			return ioutil.NopCloser(strings.NewReader("line\n")), nil
+		})
+		Ω(err).Should(HaveOccurred())
+		Ω(out.String()).Should(Equal("[node-0] line\n"))
+	})
+
+	It("should fail when a stream breaks", func() {
+		var out bytes.Buffer
+		err := printLogs(&out, []string{"node-0"}, func(name string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(failingReader{}), nil
+		})
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,149 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+// kubectl-ecs is a kubectl plugin for day-2 operations on ECS clusters
+// deployed by the ECS operator. Install it anywhere in the PATH and run it as
+// "kubectl ecs <command>".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ecs/ecs-operator/pkg/apis"
+	"github.com/ecs/ecs-operator/pkg/version"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `Day-2 operations on ECS clusters.
+
+Usage:
+  kubectl ecs [-n namespace] [-c cluster] <command> [arguments]
+
+Commands:
+  status                          Show the readiness and version of every component
+  scale <component> <replicas>    Scale a component
+  upgrade --version <version>     Upgrade the ECS and BookKeeper images
+  restart <component>             Restart the pods of a component
+  logs <component>                Print the logs of every pod of a component
+  zk tree                         Print the ZooKeeper znodes of the cluster
+  version                         Print the plugin version
+
+Components: bookie, controller, node
+`
+
+// pluginContext holds the clients and the cluster selected by the global flags
+type pluginContext struct {
+	client      client.Client
+	kubeClient  kubernetes.Interface
+	namespace   string
+	clusterName string
+}
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-ecs", flag.ExitOnError)
+	namespace := flags.String("n", "", "Namespace of the ECS cluster. Defaults to the namespace of the current context")
+	clusterName := flags.String("c", "", "Name of the ECS cluster. May be omitted if the namespace has a single cluster")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fmt.Fprintln(os.Stderr, "\nGlobal flags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "version" {
+		fmt.Printf("kubectl-ecs Version: %v\n", version.Version)
+		fmt.Printf("Git SHA: %s\n", version.GitSHA)
+		return
+	}
+
+	ctx, err := newContext(*namespace, *clusterName)
+	if err != nil {
+		fatal(err)
+	}
+
+	switch args[0] {
+	case "status":
+		err = runStatus(ctx, args[1:])
+	case "scale":
+		err = runScale(ctx, args[1:])
+	case "upgrade":
+		err = runUpgrade(ctx, args[1:])
+	case "restart":
+		err = runRestart(ctx, args[1:])
+	case "logs":
+		err = runLogs(ctx, args[1:])
+	case "zk":
+		err = runZk(ctx, args[1:])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func newContext(namespace string, clusterName string) (*pluginContext, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+
+	if namespace == "" {
+		ns, _, err := clientConfig.Namespace()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace: %v", err)
+		}
+		namespace = ns
+	}
+
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+
+	if err = apis.AddToScheme(scheme.Scheme); err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+
+	return &pluginContext{
+		client:      c,
+		kubeClient:  kubeClient,
+		namespace:   namespace,
+		clusterName: clusterName,
+	}, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
@@ -13,18 +13,27 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ecs/ecs-operator/pkg/apis"
 	"github.com/ecs/ecs-operator/pkg/controller"
 	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/health"
+	"github.com/ecs/ecs-operator/pkg/util"
 	"github.com/ecs/ecs-operator/pkg/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
 	"github.com/operator-framework/operator-sdk/pkg/ready"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -35,11 +44,18 @@ import (
 
 var (
 	versionFlag bool
+	healthAddr  string
+	enablePprof bool
 )
 
 func init() {
 	flag.BoolVar(&versionFlag, "version", false, "Show version and quit")
 	flag.BoolVar(&controllerconfig.TestMode, "test", false, "Enable test mode. Do not use this flag in production")
+	flag.IntVar(&controllerconfig.MaxConcurrentReconciles, "max-concurrent-reconciles", 1, "Number of ECSClusters reconciled concurrently")
+	flag.StringVar(&healthAddr, "health-addr", ":8081", "Address serving the /healthz and /readyz endpoints")
+	flag.BoolVar(&enablePprof, "enable-pprof", false, "Serve the pprof endpoints under /debug/pprof/ on the health address")
+	flag.DurationVar(&controllerconfig.ResyncPeriod, "resync-period", 10*time.Minute, "Delay between periodic reconciliations of unchanged ECSClusters")
+	flag.StringVar(&controllerconfig.OperatorImage, "operator-image", os.Getenv("OPERATOR_IMAGE"), "Image run by the backup and restore Jobs. Defaults to $OPERATOR_IMAGE")
 }
 
 func printVersion() {
@@ -51,6 +67,20 @@ func printVersion() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "render":
+			runRender(os.Args[2:])
+			return
+		case exportZookeeperCommand:
+			runExportZookeeper(os.Args[2:])
+			return
+		case importZookeeperCommand:
+			runImportZookeeper(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	printVersion()
@@ -63,7 +93,7 @@ func main() {
 		log.Warn("----- Running in test mode. Make sure you are NOT in production -----")
 	}
 
-	namespace, err := k8sutil.GetWatchNamespace()
+	watchNamespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
 		log.Fatal(err, "failed to get watch namespace")
 	}
@@ -74,8 +104,17 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Serve the health endpoints right away, so that the liveness probe
+	// passes while waiting for the leader lock
+	go func() {
+		if err := http.ListenAndServe(healthAddr, health.Handler(enablePprof)); err != nil {
+			log.Errorf("failed to serve health endpoints: %v", err)
+		}
+	}()
+
 	// Become the leader before proceeding
 	leader.Become(context.TODO(), "ecs-operator-lock")
+	health.SetLeader()
 
 	r := ready.NewFileReady()
 	err = r.Set()
@@ -84,28 +123,100 @@ func main() {
 	}
 	defer r.Unset()
 
-	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{Namespace: namespace})
-	if err != nil {
-		log.Fatal(err)
+	// Create a new Cmd per watched namespace to provide shared dependencies
+	// and start components. Each one has its own cache and work queue, so a
+	// namespace that cannot be served does not stall the others
+	namespaces := getWatchNamespaces(watchNamespace)
+	managers := make([]manager.Manager, len(namespaces))
+	for i, namespace := range namespaces {
+		options := manager.Options{Namespace: namespace}
+		if i > 0 {
+			// Metrics are served by the first manager only
+			options.MetricsBindAddress = "0"
+		}
+
+		mgr, err := manager.New(cfg, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Registering Components for namespace %q", namespace)
+
+		// Setup Scheme for all resources
+		if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+			log.Fatal(err)
+		}
+
+		// Setup all Controllers
+		if err := controller.AddToManager(mgr); err != nil {
+			log.Fatal(err)
+		}
+		managers[i] = mgr
+		health.AddCache(namespace)
 	}
 
-	log.Print("Registering Components")
+	log.Print("Starting the Cmd")
 
-	// Setup Scheme for all resources
-	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+	// Start the Cmds
+	stop := signals.SetupSignalHandler()
+	err = runManagers(namespaces, func(i int) error {
+		go func() {
+			if managers[i].GetCache().WaitForCacheSync(stop) {
+				health.SetCacheSynced(namespaces[i])
+			}
+		}()
+		return managers[i].Start(stop)
+	})
+	if err != nil {
+		r.Unset()
 		log.Fatal(err)
 	}
+}
 
-	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
-		log.Fatal(err)
+// runManagers starts the manager of every namespace and waits until they all
+// exit. A manager that fails fails the liveness check, so that the kubelet
+// restarts the operator while the other managers keep running. It returns an
+// error if any of them failed, so that the operator is restarted instead of
+// exiting with status 0
+func runManagers(namespaces []string, start func(i int) error) error {
+	var mu sync.Mutex
+	var failed []string
+	var wg sync.WaitGroup
+	for i := range namespaces {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := start(i); err != nil {
+				log.Errorf("manager for namespace %q exited non-zero: %v", namespaces[i], err)
+				health.SetManagerFailed(namespaces[i], err)
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%q", namespaces[i]))
+				mu.Unlock()
+			}
+		}(i)
 	}
+	wg.Wait()
 
-	log.Print("Starting the Cmd")
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("managers failed for namespaces %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// getWatchNamespaces splits the WATCH_NAMESPACE value, a comma-separated list
+// of namespaces. An empty value watches all namespaces
+func getWatchNamespaces(watchNamespace string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(watchNamespace, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" && !util.ContainsString(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
 
-	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
-		log.Fatal(err, "manager exited non-zero")
+	if len(namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
 	}
+	return namespaces
 }
@@ -0,0 +1,58 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manager", func() {
+	Context("Watch namespaces", func() {
+		It("should watch all namespaces by default", func() {
+			Ω(getWatchNamespaces("")).Should(Equal([]string{metav1.NamespaceAll}))
+		})
+
+		It("should split and deduplicate the namespaces", func() {
+			Ω(getWatchNamespaces("a, b,,a")).Should(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Context("Run managers", func() {
+		namespaces := []string{"a", "b", "c"}
+
+		It("should succeed when every manager exits cleanly", func() {
+			err := runManagers(namespaces, func(i int) error { return nil })
+			Ω(err).Should(BeNil())
+		})
+
+		It("should fail when a manager fails", func() {
+			err := runManagers(namespaces, func(i int) error {
+				if namespaces[i] == "b" {
+					return fmt.Errorf("cache failed")
+				}
+				return nil
+			})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(`"b"`))
+		})
+
+		It("should report every failed manager", func() {
+			err := runManagers(namespaces, func(i int) error { return fmt.Errorf("cache failed") })
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(`"a", "b", "c"`))
+		})
+	})
+})
@@ -0,0 +1,245 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ecs/ecs-operator/pkg/apis"
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const yamlSeparator = "---\n"
+
+// runRender prints the resources the operator would create for an ECSCluster
+// manifest, without talking to an API server. With -diff, the output is
+// compared against a previous render and the command exits with status 1 if
+// they differ
+func runRender(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	file := flags.String("f", "", "ECSCluster manifest to render")
+	previous := flags.String("diff", "", "Previous render to compare against")
+	flags.BoolVar(&controllerconfig.TestMode, "test", false, "Render as in test mode")
+	flags.Parse(args)
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "usage: ecs-operator render -f cluster.yaml [-diff previous.yaml]")
+		os.Exit(2)
+	}
+
+	rendered, err := render(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *previous == "" {
+		fmt.Print(strings.Join(rendered, yamlSeparator))
+		return
+	}
+
+	data, err := ioutil.ReadFile(*previous)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to read previous render: %v\n", err)
+		os.Exit(1)
+	}
+
+	if diffRenders(os.Stdout, splitDocuments(string(data)), rendered) {
+		os.Exit(1)
+	}
+}
+
+// render returns every resource of the cluster as a YAML document
+func render(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	p := &v1alpha1.ECSCluster{}
+	err = yaml.Unmarshal(data, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	return renderCluster(p)
+}
+
+// renderCluster returns every resource of a parsed cluster as a YAML
+// document. Clusters that the operator would refuse to reconcile are not
+// rendered
+func renderCluster(p *v1alpha1.ECSCluster) ([]string, error) {
+	if p.Namespace == "" {
+		p.Namespace = metav1.NamespaceDefault
+	}
+	err := p.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster spec: %v", err)
+	}
+	p.WithDefaults()
+
+	scheme := runtime.NewScheme()
+	if err = apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	var documents []string
+	for _, object := range ecs.MakeClusterResources(p) {
+		if ownedByCluster(p, object) {
+			if err = controllerutil.SetControllerReference(p, object.(metav1.Object), scheme); err != nil {
+				return nil, fmt.Errorf("failed to set owner reference: %v", err)
+			}
+		}
+
+		out, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resource: %v", err)
+		}
+		documents = append(documents, string(out))
+	}
+	return documents, nil
+}
+
+// ownedByCluster returns false for the filesystem Tier 2 claim, which the
+// operator only makes owned by the cluster when DeleteClaimWithCluster is set
+func ownedByCluster(p *v1alpha1.ECSCluster, object runtime.Object) bool {
+	pvc, ok := object.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		return true
+	}
+	fs := p.Spec.ECS.Tier2.FileSystem
+	if fs == nil || fs.PersistentVolumeClaim == nil || pvc.Name != fs.PersistentVolumeClaim.ClaimName {
+		return true
+	}
+	return fs.DeleteClaimWithCluster
+}
+
+func splitDocuments(data string) []string {
+	var documents []string
+	for _, document := range strings.Split(data, yamlSeparator) {
+		if strings.TrimSpace(document) != "" {
+			documents = append(documents, document)
+		}
+	}
+	return documents
+}
+
+// documentKey identifies a rendered resource by kind, namespace and name.
+// Cluster-scoped resources have no namespace
+func documentKey(document string) string {
+	object := &struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+	}{}
+	if err := yaml.Unmarshal([]byte(document), object); err != nil {
+		return ""
+	}
+	if object.Namespace == "" {
+		return fmt.Sprintf("%s/%s", object.Kind, object.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", object.Kind, object.Namespace, object.Name)
+}
+
+// diffRenders prints the resources that were added, removed or changed
+// between two renders to w, and returns true if there is any difference
+func diffRenders(w io.Writer, old []string, new []string) bool {
+	oldDocuments := make(map[string]string)
+	for _, document := range old {
+		oldDocuments[documentKey(document)] = document
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, document := range new {
+		key := documentKey(document)
+		seen[key] = true
+
+		oldDocument, ok := oldDocuments[key]
+		if !ok {
+			changed = true
+			fmt.Fprintf(w, "+++ %s (added)\n", key)
+			continue
+		}
+		if oldDocument == document {
+			continue
+		}
+
+		changed = true
+		fmt.Fprintf(w, "~~~ %s (changed)\n", key)
+		for _, line := range diffLines(strings.Split(oldDocument, "\n"), strings.Split(document, "\n")) {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	for _, document := range old {
+		key := documentKey(document)
+		if !seen[key] {
+			changed = true
+			fmt.Fprintf(w, "--- %s (removed)\n", key)
+		}
+	}
+	return changed
+}
+
+// diffLines returns the added and removed lines between a and b, based on
+// their longest common subsequence
+func diffLines(a []string, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+	return lines
+}
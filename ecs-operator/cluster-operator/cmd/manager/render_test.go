@@ -0,0 +1,202 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	serviceA = `apiVersion: v1
+kind: Service
+metadata:
+  name: example-node
+  namespace: ns-a
+spec:
+  clusterIP: None
+`
+	serviceB = `apiVersion: v1
+kind: Service
+metadata:
+  name: example-node
+  namespace: ns-b
+spec:
+  clusterIP: None
+`
+	storageClass = `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: example
+`
+)
+
+var _ = Describe("Render", func() {
+	Context("Document key", func() {
+		It("should include the namespace", func() {
+			Ω(documentKey(serviceA)).Should(Equal("Service/ns-a/example-node"))
+			Ω(documentKey(serviceA)).ShouldNot(Equal(documentKey(serviceB)))
+		})
+
+		It("should omit the namespace of cluster-scoped resources", func() {
+			Ω(documentKey(storageClass)).Should(Equal("StorageClass/example"))
+		})
+
+		It("should be empty for invalid documents", func() {
+			Ω(documentKey("kind: [")).Should(BeEmpty())
+		})
+	})
+
+	Context("Split documents", func() {
+		It("should drop empty documents", func() {
+			documents := splitDocuments(yamlSeparator + serviceA + yamlSeparator + "\n" + yamlSeparator + storageClass)
+			Ω(documents).Should(Equal([]string{serviceA, storageClass}))
+		})
+	})
+
+	Context("Diff lines", func() {
+		It("should return nothing for equal lines", func() {
+			Ω(diffLines([]string{"a", "b"}, []string{"a", "b"})).Should(BeEmpty())
+		})
+
+		It("should return the removed and added lines", func() {
+			lines := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+			Ω(lines).Should(Equal([]string{"- b", "+ x", "+ d"}))
+		})
+
+		It("should handle empty inputs", func() {
+			Ω(diffLines(nil, []string{"a"})).Should(Equal([]string{"+ a"}))
+			Ω(diffLines([]string{"a"}, nil)).Should(Equal([]string{"- a"}))
+		})
+	})
+
+	Context("Diff renders", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+		})
+
+		It("should report no difference for the same render", func() {
+			Ω(diffRenders(out, []string{serviceA, storageClass}, []string{serviceA, storageClass})).Should(BeFalse())
+			Ω(out.String()).Should(BeEmpty())
+		})
+
+		It("should report added and removed resources", func() {
+			Ω(diffRenders(out, []string{serviceA}, []string{storageClass})).Should(BeTrue())
+			Ω(out.String()).Should(ContainSubstring("+++ StorageClass/example (added)"))
+			Ω(out.String()).Should(ContainSubstring("--- Service/ns-a/example-node (removed)"))
+		})
+
+		It("should not mix up resources with the same name in different namespaces", func() {
+			Ω(diffRenders(out, []string{serviceA, serviceB}, []string{serviceB, serviceA})).Should(BeFalse())
+		})
+
+		It("should print the changed lines", func() {
+			changed := `apiVersion: v1
+kind: Service
+metadata:
+  name: example-node
+  namespace: ns-a
+spec:
+  clusterIP: 10.0.0.1
+`
+			Ω(diffRenders(out, []string{serviceA}, []string{changed})).Should(BeTrue())
+			Ω(out.String()).Should(ContainSubstring("~~~ Service/ns-a/example-node (changed)"))
+			Ω(out.String()).Should(ContainSubstring("-   clusterIP: None"))
+			Ω(out.String()).Should(ContainSubstring("+   clusterIP: 10.0.0.1"))
+		})
+	})
+
+	Context("Render cluster", func() {
+		var p *v1alpha1.ECSCluster
+
+		BeforeEach(func() {
+			p = &v1alpha1.ECSCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "example",
+				},
+			}
+		})
+
+		It("should render a valid cluster in the default namespace", func() {
+			documents, err := renderCluster(p)
+			Ω(err).Should(BeNil())
+			Ω(documents).ShouldNot(BeEmpty())
+			Ω(p.Namespace).Should(Equal(metav1.NamespaceDefault))
+		})
+
+		It("should refuse a cluster with several tier2 backends", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				Tier2: &v1alpha1.Tier2Spec{
+					FileSystem: &v1alpha1.FileSystemSpec{},
+					S3:         &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"},
+				},
+			}
+			_, err := renderCluster(p)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("invalid cluster spec"))
+		})
+
+		It("should refuse the zookeeperUri of another ensemble with an embedded one", func() {
+			p.Spec.Zookeeper = &v1alpha1.ZookeeperSpec{}
+			p.Spec.ZookeeperUri = "zk-client:2181"
+			_, err := renderCluster(p)
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		Context("Tier2 claim", func() {
+			var claim string
+
+			BeforeEach(func() {
+				p.Spec.ECS = &v1alpha1.ECSSpec{
+					Tier2: &v1alpha1.Tier2Spec{
+						FileSystem: &v1alpha1.FileSystemSpec{
+							VolumeClaimTemplate: &corev1.PersistentVolumeClaimSpec{},
+						},
+					},
+				}
+			})
+
+			findClaim := func(documents []string) {
+				claim = ""
+				for _, document := range documents {
+					if strings.HasPrefix(documentKey(document), "PersistentVolumeClaim/") {
+						claim = document
+					}
+				}
+				Ω(claim).ShouldNot(BeEmpty())
+			}
+
+			It("should not make the cluster own the claim", func() {
+				documents, err := renderCluster(p)
+				Ω(err).Should(BeNil())
+				findClaim(documents)
+				Ω(claim).ShouldNot(ContainSubstring("ownerReferences"))
+			})
+
+			It("should make the cluster own the claim when it is deleted with it", func() {
+				p.Spec.ECS.Tier2.FileSystem.DeleteClaimWithCluster = true
+				documents, err := renderCluster(p)
+				Ω(err).Should(BeNil())
+				findClaim(documents)
+				Ω(claim).Should(ContainSubstring("ownerReferences"))
+			})
+		})
+	})
+})
@@ -0,0 +1,103 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ecs/ecs-operator/pkg/util"
+)
+
+// The backup and restore Jobs run these commands of the operator image, with
+// the exported metadata on a volume, since it may not fit in a ConfigMap
+const (
+	exportZookeeperCommand = "export-zookeeper"
+	importZookeeperCommand = "import-zookeeper"
+)
+
+// zookeeperFlags parses the flags shared by the export and import commands
+func zookeeperFlags(command string, args []string) (zookeeperUri string, clusterName string, file string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&zookeeperUri, "zookeeper-uri", "", "ZooKeeper of the cluster")
+	flags.StringVar(&clusterName, "cluster", "", "Name of the cluster")
+	flags.StringVar(&file, "f", "", "File holding the exported znodes")
+	flags.Parse(args)
+
+	if zookeeperUri == "" || clusterName == "" || file == "" {
+		fmt.Fprintf(os.Stderr, "usage: ecs-operator %s -zookeeper-uri zk:2181 -cluster example -f znodes.json\n", command)
+		os.Exit(2)
+	}
+	return zookeeperUri, clusterName, file
+}
+
+// runExportZookeeper writes the persistent znodes of a cluster to a file
+func runExportZookeeper(args []string) {
+	zookeeperUri, clusterName, file := zookeeperFlags(exportZookeeperCommand, args)
+
+	err := exportZookeeper(zookeeperUri, clusterName, file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runImportZookeeper creates the znodes of a file under the root znode of a
+// cluster
+func runImportZookeeper(args []string) {
+	zookeeperUri, clusterName, file := zookeeperFlags(importZookeeperCommand, args)
+
+	err := importZookeeper(zookeeperUri, clusterName, file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func exportZookeeper(zookeeperUri string, clusterName string, file string) error {
+	znodes, err := util.ExportZnodes(zookeeperUri, clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to export zookeeper metadata: %v", err)
+	}
+
+	data, err := json.Marshal(znodes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal zookeeper metadata: %v", err)
+	}
+
+	err = ioutil.WriteFile(file, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write zookeeper metadata (%s): %v", file, err)
+	}
+	return nil
+}
+
+func importZookeeper(zookeeperUri string, clusterName string, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read zookeeper metadata (%s): %v", file, err)
+	}
+
+	var znodes []util.Znode
+	err = json.Unmarshal(data, &znodes)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal zookeeper metadata: %v", err)
+	}
+
+	err = util.ImportZnodes(zookeeperUri, clusterName, znodes)
+	if err != nil {
+		return fmt.Errorf("failed to import zookeeper metadata: %v", err)
+	}
+	return nil
+}
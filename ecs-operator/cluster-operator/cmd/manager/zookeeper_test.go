@@ -0,0 +1,48 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZooKeeper import", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "zookeeper")
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should fail without an export", func() {
+		err := importZookeeper("zk-client:2181", "example", filepath.Join(dir, "znodes.json"))
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("failed to read zookeeper metadata"))
+	})
+
+	It("should fail on a corrupted export", func() {
+		file := filepath.Join(dir, "znodes.json")
+		Ω(ioutil.WriteFile(file, []byte("{"), 0644)).Should(Succeed())
+		err := importZookeeper("zk-client:2181", "example", file)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("failed to unmarshal zookeeper metadata"))
+	})
+})
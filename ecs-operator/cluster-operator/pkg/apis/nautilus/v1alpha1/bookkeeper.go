@@ -58,6 +58,23 @@ const (
 
 	// DefaultBookkeeperLimitMemory is the limit memory limit for BookKeeper
 	DefaultBookkeeperLimitMemory = "2Gi"
+
+	// DefaultAutoRecoveryReplicas is the default number of replicas of the
+	// standalone AutoRecovery Deployment. A standby replica takes over as
+	// auditor when the elected one fails
+	DefaultAutoRecoveryReplicas = 2
+
+	// DefaultAutoRecoveryRequestCPU is the default CPU request for AutoRecovery
+	DefaultAutoRecoveryRequestCPU = "100m"
+
+	// DefaultAutoRecoveryLimitCPU is the default CPU limit for AutoRecovery
+	DefaultAutoRecoveryLimitCPU = "500m"
+
+	// DefaultAutoRecoveryRequestMemory is the default memory request for AutoRecovery
+	DefaultAutoRecoveryRequestMemory = "256Mi"
+
+	// DefaultAutoRecoveryLimitMemory is the default memory limit for AutoRecovery
+	DefaultAutoRecoveryLimitMemory = "512Mi"
 )
 
 // BookkeeperSpec defines the configuration of BookKeeper
@@ -77,6 +94,12 @@ type BookkeeperSpec struct {
 	// Defaults to true.
 	AutoRecovery *bool `json:"autoRecovery"`
 
+	// AutoRecoveryDeployment runs AutoRecovery in a separate Deployment
+	// instead of inside every bookie. Only used when AutoRecovery is enabled.
+	// The operator updates the bookie ConfigMap, but running bookies keep
+	// running AutoRecovery until they are restarted
+	AutoRecoveryDeployment *AutoRecoverySpec `json:"autoRecoveryDeployment,omitempty"`
+
 	// ServiceAccountName configures the service account used on BookKeeper instances
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 
@@ -84,6 +107,24 @@ type BookkeeperSpec struct {
 	// BookieResources includes CPU and memory resources
 	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 
+	// JVMOptions overrides the JVM options of the bookies. By default, the
+	// heap, direct memory and GC thread counts are computed from the resources
+	JVMOptions *JVMOptions `json:"jvmOptions,omitempty"`
+
+	// Probes overrides the readiness, liveness and startup probes of the
+	// bookies
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// Security overrides the security settings of the bookies and of the
+	// standalone AutoRecovery pods
+	Security *SecuritySpec `json:"security,omitempty"`
+
+	// RestartedAt triggers a rolling restart of the bookies whenever it
+	// changes. Bookies are restarted one at a time, and only while no ledger
+	// is under-replicated. Usually set to the current time. Clearing it does
+	// not restart the bookies
+	RestartedAt string `json:"restartedAt,omitempty"`
+
 	// Options is the Bookkeeper configuration that is to override the bk_server.conf
 	// in bookkeeper. Some examples can be found here
 	// https://github.com/apache/bookkeeper/blob/master/docker/README.md
@@ -118,6 +159,10 @@ func (s *BookkeeperSpec) withDefaults() (changed bool) {
 		s.AutoRecovery = &boolTrue
 	}
 
+	if s.AutoRecoveryDeployment != nil && s.AutoRecoveryDeployment.withDefaults(s.Image) {
+		changed = true
+	}
+
 	if s.Resources == nil {
 		changed = true
 		s.Resources = &v1.ResourceRequirements{
@@ -132,6 +177,26 @@ func (s *BookkeeperSpec) withDefaults() (changed bool) {
 		}
 	}
 
+	if s.JVMOptions != nil && s.JVMOptions.withDefaults() {
+		changed = true
+	}
+
+	if s.Probes == nil {
+		changed = true
+		s.Probes = &ProbesSpec{}
+	}
+	if s.Probes.withDefaults(BookieProbeDefaults(), DefaultBookiePort) {
+		changed = true
+	}
+
+	if s.Security == nil {
+		changed = true
+		s.Security = &SecuritySpec{}
+	}
+	if s.Security.withDefaults(DefaultBookieRunAsUser) {
+		changed = true
+	}
+
 	if s.Options == nil {
 		s.Options = map[string]string{}
 	}
@@ -139,6 +204,59 @@ func (s *BookkeeperSpec) withDefaults() (changed bool) {
 	return changed
 }
 
+// StandaloneAutoRecovery reports whether AutoRecovery runs in its own
+// Deployment rather than inside the bookies
+func (s *BookkeeperSpec) StandaloneAutoRecovery() bool {
+	return s.AutoRecovery != nil && *s.AutoRecovery && s.AutoRecoveryDeployment != nil
+}
+
+// AutoRecoverySpec defines the configuration of the standalone BookKeeper
+// AutoRecovery Deployment
+type AutoRecoverySpec struct {
+	// Replicas defines the number of AutoRecovery replicas. One of them is
+	// elected as auditor. Defaults to 2.
+	Replicas int32 `json:"replicas"`
+
+	// Image defines the BookKeeper Docker image to use.
+	// Defaults to the bookie image.
+	Image *BookkeeperImageSpec `json:"image"`
+
+	// Resources specifies the request and limit of resources of each replica
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+func (s *AutoRecoverySpec) withDefaults(bookieImage *BookkeeperImageSpec) (changed bool) {
+	if s.Replicas < 1 {
+		changed = true
+		s.Replicas = DefaultAutoRecoveryReplicas
+	}
+
+	if s.Image == nil {
+		changed = true
+		image := *bookieImage
+		s.Image = &image
+	}
+	if s.Image.withDefaults() {
+		changed = true
+	}
+
+	if s.Resources == nil {
+		changed = true
+		s.Resources = &v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(DefaultAutoRecoveryRequestCPU),
+				v1.ResourceMemory: resource.MustParse(DefaultAutoRecoveryRequestMemory),
+			},
+			Limits: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(DefaultAutoRecoveryLimitCPU),
+				v1.ResourceMemory: resource.MustParse(DefaultAutoRecoveryLimitMemory),
+			},
+		}
+	}
+
+	return changed
+}
+
 // BookkeeperImageSpec defines the fields needed for a BookKeeper Docker image
 type BookkeeperImageSpec struct {
 	ImageSpec
@@ -170,20 +288,59 @@ func (s *BookkeeperImageSpec) withDefaults() (changed bool) {
 
 // BookkeeperStorageSpec is the configuration of the volumes used in BookKeeper
 type BookkeeperStorageSpec struct {
-	// LedgerVolumeClaimTemplate is the spec to describe PVC for the BookKeeper ledger
-	// This field is optional. If no PVC spec and there is no default storage class,
-	// stateful containers will use emptyDir as volume
+	// LedgerVolumeClaimTemplate is the spec to describe PVC for the BookKeeper ledger.
+	// This field is optional and defaults to a claim of the default storage class.
+	// With ephemeral storage, only its storage request is used, as size limit
 	LedgerVolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"ledgerVolumeClaimTemplate"`
 
-	// JournalVolumeClaimTemplate is the spec to describe PVC for the BookKeeper journal
-	// This field is optional. If no PVC spec and there is no default storage class,
-	// stateful containers will use emptyDir as volume
+	// JournalVolumeClaimTemplate is the spec to describe PVC for the BookKeeper journal.
+	// This field is optional and defaults to a claim of the default storage class.
+	// With ephemeral storage, only its storage request is used, as size limit
 	JournalVolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"journalVolumeClaimTemplate"`
 
-	// IndexVolumeClaimTemplate is the spec to describe PVC for the BookKeeper index
-	// This field is optional. If no PVC spec and there is no default storage class,
-	// stateful containers will use emptyDir as volume
+	// IndexVolumeClaimTemplate is the spec to describe PVC for the BookKeeper index.
+	// This field is optional and defaults to a claim of the default storage class.
+	// With ephemeral storage, only its storage request is used, as size limit
 	IndexVolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"indexVolumeClaimTemplate"`
+
+	// LedgerVolumeClaimTemplates describes one PVC per ledger directory, to
+	// stripe ledgers across several disks. When set, it is used instead of
+	// LedgerVolumeClaimTemplate
+	LedgerVolumeClaimTemplates []v1.PersistentVolumeClaimSpec `json:"ledgerVolumeClaimTemplates,omitempty"`
+
+	// JournalVolumeClaimTemplates describes one PVC per journal directory, to
+	// stripe journals across several disks. When set, it is used instead of
+	// JournalVolumeClaimTemplate
+	JournalVolumeClaimTemplates []v1.PersistentVolumeClaimSpec `json:"journalVolumeClaimTemplates,omitempty"`
+
+	// Ephemeral stores the journal, ledger and index in emptyDir volumes
+	// instead of PVCs, for development and CI clusters. Data is lost when a
+	// bookie pod is deleted. The storage requested by each claim template is
+	// used as the size limit of its volume
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// LegacyVolumeMounts mounts the "ledger" claim at the journal directory
+	// and the "journal" claim at the ledger directory, as the bookies of
+	// earlier operator versions did. The operator sets it on clusters whose
+	// bookie StatefulSet uses that layout, so that their data stays in place.
+	// It only applies to the first journal and ledger directories
+	LegacyVolumeMounts bool `json:"legacyVolumeMounts,omitempty"`
+}
+
+// LedgerTemplates returns the claim template of every ledger directory
+func (s *BookkeeperStorageSpec) LedgerTemplates() []v1.PersistentVolumeClaimSpec {
+	if len(s.LedgerVolumeClaimTemplates) > 0 {
+		return s.LedgerVolumeClaimTemplates
+	}
+	return []v1.PersistentVolumeClaimSpec{*s.LedgerVolumeClaimTemplate}
+}
+
+// JournalTemplates returns the claim template of every journal directory
+func (s *BookkeeperStorageSpec) JournalTemplates() []v1.PersistentVolumeClaimSpec {
+	if len(s.JournalVolumeClaimTemplates) > 0 {
+		return s.JournalVolumeClaimTemplates
+	}
+	return []v1.PersistentVolumeClaimSpec{*s.JournalVolumeClaimTemplate}
 }
 
 func (s *BookkeeperStorageSpec) withDefaults() (changed bool) {
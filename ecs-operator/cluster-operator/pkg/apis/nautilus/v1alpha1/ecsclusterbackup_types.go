@@ -0,0 +1,173 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultBackupQuiesceTimeoutSeconds is the default time the bookies and
+	// nodes may stay stopped while a backup is taken
+	DefaultBackupQuiesceTimeoutSeconds = 600
+
+	// DefaultBackupZookeeperStorage is the default size of the volume the
+	// ZooKeeper metadata is exported to
+	DefaultBackupZookeeperStorage = "1Gi"
+)
+
+func init() {
+	SchemeBuilder.Register(&ECSClusterBackup{}, &ECSClusterBackupList{})
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ECSClusterBackupList contains a list of ECSClusterBackup
+type ECSClusterBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ECSClusterBackup `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ECSClusterBackup is the Schema for the ecsclusterbackups API. A backup
+// exports the ZooKeeper metadata of a cluster and takes a CSI snapshot of
+// every bookie and node volume while they are stopped.
+// The cluster is unavailable while the backup is quiescing: every bookie and
+// node is stopped until the ZooKeeper metadata is exported and the snapshots
+// are cut. The backup does not include Tier 2
+// +k8s:openapi-gen=true
+type ECSClusterBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupSpec   `json:"spec,omitempty"`
+	Status BackupStatus `json:"status,omitempty"`
+}
+
+// WithDefaults set default values when not defined in the spec.
+func (b *ECSClusterBackup) WithDefaults() (changed bool) {
+	if b.Spec.QuiesceTimeoutSeconds < 1 {
+		changed = true
+		b.Spec.QuiesceTimeoutSeconds = DefaultBackupQuiesceTimeoutSeconds
+	}
+	if b.Spec.ZookeeperVolumeClaimTemplate == nil {
+		changed = true
+		b.Spec.ZookeeperVolumeClaimTemplate = &v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse(DefaultBackupZookeeperStorage),
+				},
+			},
+		}
+	}
+	return changed
+}
+
+// BackupSpec defines the cluster to back up
+type BackupSpec struct {
+	// ClusterName is the name of the ECSCluster to back up, in the namespace
+	// of the backup
+	ClusterName string `json:"clusterName"`
+
+	// VolumeSnapshotClassName is the class of the volume snapshots. The
+	// default class of the CSI driver is used when empty
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// QuiesceTimeoutSeconds is the time after which the backup fails if the
+	// bookies and nodes are not stopped and snapshotted. Defaults to 600
+	QuiesceTimeoutSeconds int64 `json:"quiesceTimeoutSeconds,omitempty"`
+
+	// ZookeeperVolumeClaimTemplate is the claim of the volume the ZooKeeper
+	// metadata is exported to. It is deleted along with the backup.
+	// Defaults to 1Gi
+	ZookeeperVolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"zookeeperVolumeClaimTemplate,omitempty"`
+}
+
+// BackupPhase is the progress of a backup
+type BackupPhase string
+
+const (
+	// BackupPhasePending is the phase of a backup that has not started
+	BackupPhasePending BackupPhase = ""
+
+	// BackupPhaseQuiescing is the phase during which the bookies and nodes
+	// are stopped and the ZooKeeper metadata is exported
+	BackupPhaseQuiescing BackupPhase = "Quiescing"
+
+	// BackupPhaseSnapshotting is the phase during which the volume snapshots
+	// are taken. The bookies and nodes are restarted once every snapshot
+	// has been cut
+	BackupPhaseSnapshotting BackupPhase = "Snapshotting"
+
+	// BackupPhaseCompleted is the phase of a backup that can be restored
+	BackupPhaseCompleted BackupPhase = "Completed"
+
+	// BackupPhaseFailed is the phase of a backup that cannot be restored
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+// BackupStatus defines the observed state of ECSClusterBackup
+type BackupStatus struct {
+	// Phase is the progress of the backup
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Message explains why the backup failed
+	Message string `json:"message,omitempty"`
+
+	// StartTime is the time the backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the backup completed or failed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// ClusterSpec is the spec of the cluster when the backup started. It is
+	// used to create the restored cluster
+	ClusterSpec *ClusterSpec `json:"clusterSpec,omitempty"`
+
+	// ZookeeperClaim is the name of the PersistentVolumeClaim holding the
+	// exported ZooKeeper metadata of the cluster
+	ZookeeperClaim string `json:"zookeeperClaim,omitempty"`
+
+	// VolumeSnapshots lists the snapshot of every bookie and node volume
+	VolumeSnapshots []BackupVolumeSnapshot `json:"volumeSnapshots,omitempty"`
+}
+
+// BackupVolumeSnapshot is the snapshot of a volume of a bookie or a node
+type BackupVolumeSnapshot struct {
+	// Name is the name of the VolumeSnapshot
+	Name string `json:"name"`
+
+	// Component is the StatefulSet the volume belongs to, either bookie or
+	// node
+	Component string `json:"component"`
+
+	// ClaimTemplate is the name of the volume claim template of the volume
+	ClaimTemplate string `json:"claimTemplate"`
+
+	// Ordinal is the ordinal of the pod the volume is mounted by
+	Ordinal int32 `json:"ordinal"`
+
+	// Claim is the spec of the snapshotted PersistentVolumeClaim
+	Claim v1.PersistentVolumeClaimSpec `json:"claim"`
+
+	// ReadyToUse is true once volumes can be provisioned from the snapshot
+	ReadyToUse bool `json:"readyToUse"`
+}
+
+// IsFinished returns true once the backup completed or failed
+func (s *BackupStatus) IsFinished() bool {
+	return s.Phase == BackupPhaseCompleted || s.Phase == BackupPhaseFailed
+}
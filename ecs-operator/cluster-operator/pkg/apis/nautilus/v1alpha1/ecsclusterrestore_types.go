@@ -0,0 +1,111 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&ECSClusterRestore{}, &ECSClusterRestoreList{})
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ECSClusterRestoreList contains a list of ECSClusterRestore
+type ECSClusterRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ECSClusterRestore `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ECSClusterRestore is the Schema for the ecsclusterrestores API. A restore
+// recreates a deleted ECSCluster from a completed ECSClusterBackup, under the
+// same name, since bookies are identified by their hostname
+// +k8s:openapi-gen=true
+type ECSClusterRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RestoreSpec   `json:"spec,omitempty"`
+	Status RestoreStatus `json:"status,omitempty"`
+}
+
+// RestoreSpec defines the backup to restore
+type RestoreSpec struct {
+	// BackupName is the name of the ECSClusterBackup to restore, in the
+	// namespace of the restore. The backup must be completed
+	BackupName string `json:"backupName"`
+
+	// ClusterName is the name of the ECSCluster to create. It must not exist.
+	// Defaults to the name of the backed up cluster, and cannot differ from
+	// it, since the hostnames of the bookies are recorded in their cookies and
+	// in the ledger metadata
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// Tier2 is the Tier 2 of the restored cluster. It must hold a copy of the
+	// Tier 2 of the backed up cluster taken after the backup completed, and
+	// must not be shared with any other cluster
+	Tier2 *Tier2Spec `json:"tier2"`
+}
+
+// RestorePhase is the progress of a restore
+type RestorePhase string
+
+const (
+	// RestorePhasePending is the phase of a restore that has not started
+	RestorePhasePending RestorePhase = ""
+
+	// RestorePhaseImporting is the phase during which the ZooKeeper metadata
+	// is imported from the backup
+	RestorePhaseImporting RestorePhase = "Importing"
+
+	// RestorePhaseRestoring is the phase during which the volumes and the
+	// cluster are created. The ZooKeeper metadata is already imported
+	RestorePhaseRestoring RestorePhase = "Restoring"
+
+	// RestorePhaseCompleted is the phase of a restore whose cluster was
+	// created
+	RestorePhaseCompleted RestorePhase = "Completed"
+
+	// RestorePhaseFailed is the phase of a restore whose cluster could not be
+	// created
+	RestorePhaseFailed RestorePhase = "Failed"
+)
+
+// RestoreStatus defines the observed state of ECSClusterRestore
+type RestoreStatus struct {
+	// Phase is the progress of the restore
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// Message explains why the restore failed
+	Message string `json:"message,omitempty"`
+
+	// CompletionTime is the time the restore completed or failed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// IsFinished returns true once the restore completed or failed
+func (s *RestoreStatus) IsFinished() bool {
+	return s.Phase == RestorePhaseCompleted || s.Phase == RestorePhaseFailed
+}
+
+// Validate returns an error if the restore cannot be run
+func (r *ECSClusterRestore) Validate() error {
+	if r.Spec.Tier2 == nil {
+		return fmt.Errorf("restore has no tier2, which must not be the tier2 of the backed up cluster")
+	}
+	return r.Spec.Tier2.validate()
+}
@@ -12,6 +12,8 @@ package v1alpha1
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/ecs/ecs-operator/pkg/controller/config"
 	"k8s.io/api/core/v1"
@@ -38,6 +40,25 @@ const (
 	// DefaultECSTier2ClaimName is the default volume claim name used as Tier 2
 	DefaultECSTier2ClaimName = "ecs-tier2"
 
+	// DefaultECSTier2MountPath is the default path where the filesystem
+	// Tier 2 volume is mounted
+	DefaultECSTier2MountPath = "/mnt/tier2"
+
+	// DefaultS3Region is the default region used by the S3 Tier 2 backend
+	DefaultS3Region = "us-east-1"
+
+	// DefaultS3CABundleKey is the default key of the CA bundle within the
+	// Secret referenced by the S3 Tier 2 backend
+	DefaultS3CABundleKey = "ca.crt"
+
+	// DefaultHDFSReplicationFactor is the default replication factor of the
+	// files written to the HDFS Tier 2 backend
+	DefaultHDFSReplicationFactor = 3
+
+	// DefaultHDFSKeytabKey is the default key of the Kerberos keytab within
+	// the Secret referenced by the HDFS Tier 2 backend
+	DefaultHDFSKeytabKey = "krb5.keytab"
+
 	// DefaultControllerReplicas is the default number of replicas for the ECS
 	// Controller component
 	DefaultControllerReplicas = 1
@@ -95,10 +116,15 @@ type ECSSpec struct {
 	Options map[string]string `json:"options"`
 
 	// CacheVolumeClaimTemplate is the spec to describe PVC for the ECS cache.
-	// This field is optional. If no PVC spec, stateful containers will use
-	// emptyDir as volume
+	// This field is optional and defaults to a claim of the default storage class.
+	// With an ephemeral cache, only its storage request is used, as size limit
 	CacheVolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"cacheVolumeClaimTemplate"`
 
+	// EphemeralCache stores the node cache in an emptyDir volume instead of a
+	// PVC, for development and CI clusters. The storage requested by
+	// CacheVolumeClaimTemplate is used as the size limit of the volume
+	EphemeralCache bool `json:"ephemeralCache,omitempty"`
+
 	// Tier2 is the configuration of ECS's tier 2 storage. If no configuration
 	// is provided, it will assume that a PersistentVolumeClaim called "ecs-tier2"
 	// is present and it will use it as Tier 2
@@ -119,6 +145,36 @@ type ECSSpec struct {
 	// NodeResources specifies the request and limit of resources that node can have.
 	// NodeResources includes CPU and memory resources
 	NodeResources *v1.ResourceRequirements `json:"nodeResources,omitempty"`
+
+	// ControllerJVMOptions overrides the JVM options of the controllers. By
+	// default, the heap and GC thread counts are computed from the resources
+	ControllerJVMOptions *JVMOptions `json:"controllerJvmOptions,omitempty"`
+
+	// NodeJVMOptions overrides the JVM options of the nodes. By default, the
+	// heap, direct memory and GC thread counts are computed from the resources
+	NodeJVMOptions *JVMOptions `json:"nodeJvmOptions,omitempty"`
+
+	// ControllerProbes overrides the readiness, liveness and startup probes
+	// of the controllers
+	ControllerProbes *ProbesSpec `json:"controllerProbes,omitempty"`
+
+	// NodeProbes overrides the readiness, liveness and startup probes of the
+	// nodes
+	NodeProbes *ProbesSpec `json:"nodeProbes,omitempty"`
+
+	// ControllerSecurity overrides the security settings of the controllers
+	ControllerSecurity *SecuritySpec `json:"controllerSecurity,omitempty"`
+
+	// NodeSecurity overrides the security settings of the nodes
+	NodeSecurity *SecuritySpec `json:"nodeSecurity,omitempty"`
+
+	// ControllerRestartedAt triggers a rolling restart of the controllers
+	// whenever it changes to a non-empty value
+	ControllerRestartedAt string `json:"controllerRestartedAt,omitempty"`
+
+	// NodeRestartedAt triggers a rolling restart of the nodes whenever it
+	// changes to a non-empty value. Nodes are restarted one at a time
+	NodeRestartedAt string `json:"nodeRestartedAt,omitempty"`
 }
 
 func (s *ECSSpec) withDefaults() (changed bool) {
@@ -194,6 +250,46 @@ func (s *ECSSpec) withDefaults() (changed bool) {
 		}
 	}
 
+	if s.ControllerJVMOptions != nil && s.ControllerJVMOptions.withDefaults() {
+		changed = true
+	}
+
+	if s.NodeJVMOptions != nil && s.NodeJVMOptions.withDefaults() {
+		changed = true
+	}
+
+	if s.ControllerProbes == nil {
+		changed = true
+		s.ControllerProbes = &ProbesSpec{}
+	}
+	if s.ControllerProbes.withDefaults(ControllerProbeDefaults(), DefaultControllerGRPCPort) {
+		changed = true
+	}
+
+	if s.NodeProbes == nil {
+		changed = true
+		s.NodeProbes = &ProbesSpec{}
+	}
+	if s.NodeProbes.withDefaults(NodeProbeDefaults(), DefaultNodePort) {
+		changed = true
+	}
+
+	if s.ControllerSecurity == nil {
+		changed = true
+		s.ControllerSecurity = &SecuritySpec{}
+	}
+	if s.ControllerSecurity.withDefaults(DefaultECSRunAsUser) {
+		changed = true
+	}
+
+	if s.NodeSecurity == nil {
+		changed = true
+		s.NodeSecurity = &SecuritySpec{}
+	}
+	if s.NodeSecurity.withDefaults(DefaultECSRunAsUser) {
+		changed = true
+	}
+
 	return changed
 }
 
@@ -230,8 +326,8 @@ func (s *ECSImageSpec) withDefaults() (changed bool) {
 // If not specified, Tier 2 will be configured in filesystem mode and will try
 // to use a PersistentVolumeClaim with the name "ecs-tier2"
 type Tier2Spec struct {
-	// FileSystem is used to configure a pre-created Persistent Volume Claim
-	// as Tier 2 backend.
+	// FileSystem is used to configure a Persistent Volume Claim or any other
+	// volume source as Tier 2 backend.
 	// It is default Tier 2 mode.
 	FileSystem *FileSystemSpec `json:"filesystem,omitempty"`
 
@@ -240,25 +336,116 @@ type Tier2Spec struct {
 
 	// Hdfs is used to configure an HDFS system as a Tier 2 backend
 	Hdfs *HDFSSpec `json:"hdfs,omitempty"`
+
+	// S3 is used to configure any S3-compatible system, such as AWS S3,
+	// MinIO or Dell EMC ECS, as a Tier 2 backend
+	S3 *S3Spec `json:"s3,omitempty"`
 }
 
 func (s *Tier2Spec) withDefaults() (changed bool) {
-	if s.FileSystem == nil && s.ECS == nil && s.Hdfs == nil {
+	if s.FileSystem == nil && s.ECS == nil && s.Hdfs == nil && s.S3 == nil {
+		changed = true
+		s.FileSystem = &FileSystemSpec{}
+	}
+
+	if s.FileSystem != nil && s.FileSystem.withDefaults() {
+		changed = true
+	}
+
+	if s.S3 != nil && s.S3.withDefaults() {
+		changed = true
+	}
+
+	if s.Hdfs != nil && s.Hdfs.withDefaults() {
 		changed = true
-		fs := &FileSystemSpec{
-			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-				ClaimName: DefaultECSTier2ClaimName,
-			},
-		}
-		s.FileSystem = fs
 	}
 
 	return changed
 }
 
-// FileSystemSpec contains the reference to a PVC.
+// validate rejects the specs with several backends, since only one of them
+// would be used
+func (s *Tier2Spec) validate() error {
+	var backends []string
+	if s.FileSystem != nil {
+		backends = append(backends, "filesystem")
+	}
+	if s.ECS != nil {
+		backends = append(backends, "ecs")
+	}
+	if s.Hdfs != nil {
+		backends = append(backends, "hdfs")
+	}
+	if s.S3 != nil {
+		backends = append(backends, "s3")
+	}
+	if len(backends) > 1 {
+		return fmt.Errorf("tier2 has several backends (%s), only one is allowed", strings.Join(backends, ", "))
+	}
+	return nil
+}
+
+// SameTarget returns true if both specs store Tier 2 in the same place, once
+// their defaults are applied
+func (s *Tier2Spec) SameTarget(other *Tier2Spec) bool {
+	a, b := s.DeepCopy(), other.DeepCopy()
+	a.withDefaults()
+	b.withDefaults()
+
+	switch {
+	case a.FileSystem != nil && b.FileSystem != nil:
+		return reflect.DeepEqual(a.FileSystem.VolumeSource, b.FileSystem.VolumeSource) &&
+			a.FileSystem.SubPath == b.FileSystem.SubPath
+	case a.ECS != nil && b.ECS != nil:
+		return a.ECS.Uri == b.ECS.Uri && a.ECS.Bucket == b.ECS.Bucket && a.ECS.Root == b.ECS.Root
+	case a.Hdfs != nil && b.Hdfs != nil:
+		return a.Hdfs.Uri == b.Hdfs.Uri && a.Hdfs.Root == b.Hdfs.Root
+	case a.S3 != nil && b.S3 != nil:
+		return a.S3.Endpoint == b.S3.Endpoint && a.S3.Bucket == b.S3.Bucket && a.S3.Prefix == b.S3.Prefix
+	}
+	return false
+}
+
+// FileSystemSpec contains the volume used as Tier 2.
+// Any volume source supported by Kubernetes can be used, such as a
+// PersistentVolumeClaim, an NFS server and path, or a CSI inline volume.
 type FileSystemSpec struct {
-	PersistentVolumeClaim *v1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim"`
+	v1.VolumeSource `json:",inline"`
+
+	// MountPath is the path where the Tier 2 volume is mounted in the nodes.
+	// Defaults to "/mnt/tier2"
+	MountPath string `json:"mountPath,omitempty"`
+
+	// SubPath is the path within the volume used as Tier 2 by this cluster.
+	// Defaults to the root of the volume
+	SubPath string `json:"subPath,omitempty"`
+
+	// VolumeClaimTemplate is used to create the PersistentVolumeClaim
+	// referenced by the volume source when it does not exist. The created claim
+	// outlives the cluster, unless DeleteClaimWithCluster is true.
+	// If not specified, the claim must be created beforehand
+	VolumeClaimTemplate *v1.PersistentVolumeClaimSpec `json:"volumeClaimTemplate,omitempty"`
+
+	// DeleteClaimWithCluster makes the cluster the owner of the claim created
+	// from VolumeClaimTemplate, so that the claim and the Tier 2 data are
+	// deleted along with the cluster. Defaults to false
+	DeleteClaimWithCluster bool `json:"deleteClaimWithCluster,omitempty"`
+}
+
+func (s *FileSystemSpec) withDefaults() (changed bool) {
+	if reflect.DeepEqual(s.VolumeSource, v1.VolumeSource{}) {
+		changed = true
+		s.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{
+			ClaimName: DefaultECSTier2ClaimName,
+		}
+	}
+
+	if s.MountPath == "" {
+		changed = true
+		s.MountPath = DefaultECSTier2MountPath
+	}
+
+	return changed
 }
 
 // ECSSpec contains the connection details to a Dell EMC ECS system
@@ -275,4 +462,91 @@ type HDFSSpec struct {
 	Uri               string `json:"uri"`
 	Root              string `json:"root"`
 	ReplicationFactor int32  `json:"replicationFactor"`
+
+	// ConfigMap is the name of an optional ConfigMap containing the Hadoop
+	// client configuration files, such as "hdfs-site.xml" and "core-site.xml"
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Kerberos configures Kerberos authentication against HDFS
+	Kerberos *HDFSKerberosSpec `json:"kerberos,omitempty"`
+}
+
+func (s *HDFSSpec) withDefaults() (changed bool) {
+	if s.ReplicationFactor < 1 {
+		changed = true
+		s.ReplicationFactor = DefaultHDFSReplicationFactor
+	}
+
+	if s.Kerberos != nil && s.Kerberos.withDefaults() {
+		changed = true
+	}
+
+	return changed
+}
+
+// HDFSKerberosSpec contains the Kerberos settings used to access HDFS
+type HDFSKerberosSpec struct {
+	// Principal is the Kerberos principal used by the ECS nodes,
+	// e.g. "ecs/_HOST@EXAMPLE.COM"
+	Principal string `json:"principal"`
+
+	// KeytabSecret is the name of the Secret containing the keytab of the principal
+	KeytabSecret string `json:"keytabSecret"`
+
+	// KeytabKey is the key of the keytab within KeytabSecret.
+	// Defaults to "krb5.keytab"
+	KeytabKey string `json:"keytabKey,omitempty"`
+
+	// Krb5ConfigMap is the name of the ConfigMap containing the "krb5.conf" file
+	Krb5ConfigMap string `json:"krb5ConfigMap"`
+}
+
+func (s *HDFSKerberosSpec) withDefaults() (changed bool) {
+	if s.KeytabKey == "" {
+		changed = true
+		s.KeytabKey = DefaultHDFSKeytabKey
+	}
+
+	return changed
+}
+
+// S3Spec contains the connection details to an S3-compatible system
+type S3Spec struct {
+	// Endpoint is the URI of the S3 service, e.g. "http://minio:9000".
+	// If empty, the AWS S3 endpoint of the region is used
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region is the region of the bucket. Defaults to "us-east-1"
+	Region string `json:"region,omitempty"`
+
+	// Bucket is the name of the bucket used as Tier 2
+	Bucket string `json:"bucket"`
+
+	// Prefix is the key prefix under which all Tier 2 objects are stored
+	Prefix string `json:"prefix,omitempty"`
+
+	// PathStyleAccess enables path-style requests ("endpoint/bucket/key")
+	// instead of virtual-hosted requests. Required by most MinIO and ECS setups
+	PathStyleAccess bool `json:"pathStyleAccess,omitempty"`
+
+	// CABundleSecret is the name of a Secret containing the CA bundle that
+	// signs the certificate of the endpoint, under the key "ca.crt"
+	CABundleSecret string `json:"caBundleSecret,omitempty"`
+
+	// Credentials is the name of a Secret containing the keys "ACCESS_KEY_ID"
+	// and "SECRET_KEY"
+	Credentials string `json:"credentials"`
+
+	// CreateBucket indicates whether or not the operator creates the bucket
+	// when it does not exist. Defaults to false.
+	CreateBucket bool `json:"createBucket,omitempty"`
+}
+
+func (s *S3Spec) withDefaults() (changed bool) {
+	if s.Region == "" {
+		changed = true
+		s.Region = DefaultS3Region
+	}
+
+	return changed
 }
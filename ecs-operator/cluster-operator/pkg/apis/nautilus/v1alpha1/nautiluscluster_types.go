@@ -11,6 +11,10 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -21,6 +25,11 @@ const (
 
 	// DefaultServiceType is the default service type for external access
 	DefaultServiceType = v1.ServiceTypeLoadBalancer
+
+	// MinNodePort and MaxNodePort bound the default NodePort range of the
+	// Kubernetes API server
+	MinNodePort = 30000
+	MaxNodePort = 32767
 )
 
 func init() {
@@ -50,11 +59,31 @@ type ECSCluster struct {
 
 // WithDefaults set default values when not defined in the spec.
 func (p *ECSCluster) WithDefaults() (changed bool) {
-	changed = p.Spec.withDefaults()
+	// The components of a cluster with an embedded ZooKeeper ensemble connect
+	// to its client Service. A zookeeperUri set by the user is kept, and
+	// rejected by Validate if it points elsewhere
+	if p.Spec.Zookeeper != nil && p.Spec.ZookeeperUri == "" {
+		changed = true
+		p.Spec.ZookeeperUri = p.EmbeddedZookeeperUri()
+	}
+
+	if p.Spec.withDefaults() {
+		changed = true
+	}
 
 	return changed
 }
 
+// Validate returns an error when the spec holds conflicting settings. Invalid
+// clusters are not reconciled until their spec is fixed
+func (p *ECSCluster) Validate() error {
+	if p.Spec.Zookeeper != nil && p.Spec.ZookeeperUri != "" && p.Spec.ZookeeperUri != p.EmbeddedZookeeperUri() {
+		return fmt.Errorf("zookeeperUri (%s) must be empty or %s with an embedded ZooKeeper ensemble",
+			p.Spec.ZookeeperUri, p.EmbeddedZookeeperUri())
+	}
+	return p.Spec.validate()
+}
+
 // ClusterSpec defines the desired state of ECSCluster
 type ClusterSpec struct {
 	// ZookeeperUri specifies the hostname/IP address and port in the format
@@ -62,8 +91,14 @@ type ClusterSpec struct {
 	// By default, the value "zk-client:2181" is used, that corresponds to the
 	// default Zookeeper service created by the ECS Zookkeeper operator
 	// available at: https://github.com/ecs/zookeeper-operator
+	// It is set by the operator when the zookeeper section is present, and
+	// must then be left empty
 	ZookeeperUri string `json:"zookeeperUri"`
 
+	// Zookeeper deploys a ZooKeeper ensemble managed by the operator for the
+	// cluster, instead of using an existing one
+	Zookeeper *ZookeeperSpec `json:"zookeeper,omitempty"`
+
 	// ExternalAccess specifies whether or not to allow external access
 	// to clients and the service type to use to achieve it
 	// By default, external access is not enabled
@@ -74,6 +109,87 @@ type ClusterSpec struct {
 
 	// ECS configuration
 	ECS *ECSSpec `json:"ecs"`
+
+	// Paused stops the reconciliation of the cluster, so that manual changes
+	// are not reverted. Only the status is updated. The "ecs.ecs.io/paused"
+	// annotation set to "true" has the same effect
+	Paused bool `json:"paused,omitempty"`
+
+	// Maintenance cordons bookies and nodes so that they can be worked on
+	Maintenance *MaintenanceSpec `json:"maintenance,omitempty"`
+
+	// NetworkPolicy restricts the traffic between the components of the
+	// cluster and from its clients.
+	// By default, no NetworkPolicy is created
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicySpec defines the NetworkPolicies of the bookies, the
+// controllers and the nodes. Bookies only accept traffic from the nodes and
+// the bookies, while the controllers and the nodes also accept it from the
+// clients. Egress is limited to the other components, ZooKeeper, Tier 2 and
+// DNS
+type NetworkPolicySpec struct {
+	// Enabled specifies whether or not the NetworkPolicies are created
+	Enabled bool `json:"enabled"`
+
+	// ClientNamespaceSelector selects the namespaces of the clients allowed
+	// to reach the controllers and the nodes. An empty selector selects all
+	// namespaces.
+	// By default, only clients in the namespace of the cluster are allowed
+	ClientNamespaceSelector *metav1.LabelSelector `json:"clientNamespaceSelector,omitempty"`
+
+	// ClientPodSelector restricts the clients to the pods with these labels.
+	// By default, all the pods of the client namespaces are allowed
+	ClientPodSelector *metav1.LabelSelector `json:"clientPodSelector,omitempty"`
+
+	// OperatorNamespaceSelector selects the namespace of the operator, which
+	// queries the REST API of the controllers.
+	// By default, the operator is expected in the namespace of the cluster
+	OperatorNamespaceSelector *metav1.LabelSelector `json:"operatorNamespaceSelector,omitempty"`
+
+	// OperatorPodSelector selects the operator pods.
+	// By default, the pods labeled name=ecs-operator are selected, as in
+	// deploy/operator.yaml
+	OperatorPodSelector *metav1.LabelSelector `json:"operatorPodSelector,omitempty"`
+}
+
+// IsEnabled returns true if the NetworkPolicies are to be created
+func (s *NetworkPolicySpec) IsEnabled() bool {
+	return s != nil && s.Enabled
+}
+
+// MaintenanceSpec lists the bookie and node ordinals under maintenance. A
+// cordoned pod is removed from the Services and from the budget of the
+// PodDisruptionBudget, and the operator does not scale its StatefulSet below
+// it
+type MaintenanceSpec struct {
+	// BookieOrdinals are the ordinals of the cordoned bookies
+	BookieOrdinals []int32 `json:"bookieOrdinals,omitempty"`
+
+	// NodeOrdinals are the ordinals of the cordoned nodes
+	NodeOrdinals []int32 `json:"nodeOrdinals,omitempty"`
+}
+
+// IsBookieCordoned returns true if the bookie with the given ordinal is under
+// maintenance
+func (s *MaintenanceSpec) IsBookieCordoned(ordinal int32) bool {
+	return s != nil && containsOrdinal(s.BookieOrdinals, ordinal)
+}
+
+// IsNodeCordoned returns true if the node with the given ordinal is under
+// maintenance
+func (s *MaintenanceSpec) IsNodeCordoned(ordinal int32) bool {
+	return s != nil && containsOrdinal(s.NodeOrdinals, ordinal)
+}
+
+func containsOrdinal(ordinals []int32, ordinal int32) bool {
+	for _, o := range ordinals {
+		if o == ordinal {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *ClusterSpec) withDefaults() (changed bool) {
@@ -82,6 +198,10 @@ func (s *ClusterSpec) withDefaults() (changed bool) {
 		s.ZookeeperUri = DefaultZookeeperUri
 	}
 
+	if s.Zookeeper != nil && s.Zookeeper.withDefaults() {
+		changed = true
+	}
+
 	if s.ExternalAccess == nil {
 		changed = true
 		s.ExternalAccess = &ExternalAccess{}
@@ -109,6 +229,39 @@ func (s *ClusterSpec) withDefaults() (changed bool) {
 	return changed
 }
 
+func (s *ClusterSpec) validate() error {
+	if s.ECS != nil && s.ECS.Tier2 != nil {
+		if err := s.ECS.Tier2.validate(); err != nil {
+			return err
+		}
+	}
+	if s.Bookkeeper != nil && s.Bookkeeper.Probes != nil {
+		if err := s.Bookkeeper.Probes.validate("bookie", BookieProbeDefaults(), DefaultBookiePort); err != nil {
+			return err
+		}
+	}
+	if s.ECS != nil && s.ECS.ControllerProbes != nil {
+		if err := s.ECS.ControllerProbes.validate("controller", ControllerProbeDefaults(), DefaultControllerGRPCPort); err != nil {
+			return err
+		}
+	}
+	if s.ECS != nil && s.ECS.NodeProbes != nil {
+		if err := s.ECS.NodeProbes.validate("node", NodeProbeDefaults(), DefaultNodePort); err != nil {
+			return err
+		}
+	}
+	if s.ExternalAccess != nil {
+		nodeReplicas := int32(DefaultNodeReplicas)
+		if s.ECS != nil && s.ECS.NodeReplicas > 0 {
+			nodeReplicas = s.ECS.NodeReplicas
+		}
+		if err := s.ExternalAccess.validate(nodeReplicas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ExternalAccess defines the configuration of the external access
 type ExternalAccess struct {
 	// Enabled specifies whether or not external access is enabled
@@ -119,6 +272,26 @@ type ExternalAccess struct {
 	// Options are "LoadBalancer" and "NodePort".
 	// By default, if external access is enabled, it will use "LoadBalancer"
 	Type v1.ServiceType `json:"type,omitempty"`
+
+	// Annotations are added to every external node service. They can be used
+	// to configure cloud load balancers or external-dns
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// NodePortBase is the NodePort assigned to the first node when the service
+	// type is "NodePort". Every other node gets the port NodePortBase + ordinal.
+	// The ports of all the nodes must be in the NodePort range (30000-32767).
+	// By default, Kubernetes assigns a random NodePort to each node
+	NodePortBase int32 `json:"nodePortBase,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the client IP ranges allowed to reach
+	// the nodes when the service type is "LoadBalancer"
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// HostnameTemplate is the external hostname advertised by each node.
+	// The placeholders "{cluster}" and "{ordinal}" are replaced with the cluster
+	// name and the node ordinal, e.g. "{cluster}-node-{ordinal}.example.com".
+	// The hostname is also published through the external-dns annotation
+	HostnameTemplate string `json:"hostnameTemplate,omitempty"`
 }
 
 func (e *ExternalAccess) withDefaults() (changed bool) {
@@ -133,6 +306,74 @@ func (e *ExternalAccess) withDefaults() (changed bool) {
 	return changed
 }
 
+// HostnameForNode returns the external hostname of the node with the given
+// ordinal, or an empty string if no hostname template is configured
+func (e *ExternalAccess) HostnameForNode(clusterName string, ordinal int32) string {
+	if e.HostnameTemplate == "" {
+		return ""
+	}
+	r := strings.NewReplacer("{cluster}", clusterName, "{ordinal}", strconv.Itoa(int(ordinal)))
+	return r.Replace(e.HostnameTemplate)
+}
+
+// NodePortForNode returns the fixed NodePort of the node with the given
+// ordinal, or 0 if NodePorts are assigned by Kubernetes
+func (e *ExternalAccess) NodePortForNode(ordinal int32) int32 {
+	if e.Type != v1.ServiceTypeNodePort || e.NodePortBase == 0 {
+		return 0
+	}
+	return e.NodePortBase + ordinal
+}
+
+// validate checks that the fixed NodePorts of every node, up to the highest
+// ordinal, are in the NodePort range, so that no node service fails to be
+// created after a scale-up
+func (e *ExternalAccess) validate(nodeReplicas int32) error {
+	first := e.NodePortForNode(0)
+	if first == 0 {
+		return nil
+	}
+	last := e.NodePortForNode(nodeReplicas - 1)
+	if first < MinNodePort || last > MaxNodePort {
+		return fmt.Errorf("externalAccess nodePortBase (%d) gives node ports %d-%d to %d nodes, outside of the NodePort range (%d-%d)",
+			e.NodePortBase, first, last, nodeReplicas, MinNodePort, MaxNodePort)
+	}
+	return nil
+}
+
+// JVMOptionsMode defines how the JVM options of a component are combined with
+// the options generated by the operator
+type JVMOptionsMode string
+
+const (
+	// JVMOptionsAppend adds the options after the generated ones. Since the
+	// JVM uses the last occurrence of a flag, they take precedence
+	JVMOptionsAppend JVMOptionsMode = "Append"
+
+	// JVMOptionsReplace uses the options instead of the generated heap,
+	// direct memory and GC options. The options exiting the JVM on
+	// OutOfMemoryError are kept
+	JVMOptionsReplace JVMOptionsMode = "Replace"
+)
+
+// JVMOptions overrides the JVM options of a component. By default, the heap,
+// direct memory and GC thread counts are computed from the resource limits
+type JVMOptions struct {
+	// Mode is either "Append" or "Replace". Defaults to "Append"
+	Mode JVMOptionsMode `json:"mode,omitempty"`
+
+	// Options is the list of JVM options, e.g. "-Xmx2g"
+	Options []string `json:"options,omitempty"`
+}
+
+func (o *JVMOptions) withDefaults() (changed bool) {
+	if o.Mode == "" {
+		changed = true
+		o.Mode = JVMOptionsAppend
+	}
+	return changed
+}
+
 // ImageSpec defines the fields needed for a Docker repository image
 type ImageSpec struct {
 	Repository string        `json:"repository"`
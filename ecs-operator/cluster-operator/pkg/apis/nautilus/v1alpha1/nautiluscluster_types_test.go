@@ -0,0 +1,134 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1_test
+
+import (
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECSCluster Validation", func() {
+
+	var p *v1alpha1.ECSCluster
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "example",
+			},
+		}
+	})
+
+	Context("Default spec", func() {
+		It("should be valid", func() {
+			Ω(p.Validate()).Should(BeNil())
+			p.WithDefaults()
+			Ω(p.Validate()).Should(BeNil())
+		})
+	})
+
+	Context("Tier 2", func() {
+		It("should accept a single backend", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				Tier2: &v1alpha1.Tier2Spec{
+					S3: &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"},
+				},
+			}
+			Ω(p.Validate()).Should(BeNil())
+		})
+
+		It("should reject several backends", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				Tier2: &v1alpha1.Tier2Spec{
+					FileSystem: &v1alpha1.FileSystemSpec{},
+					S3:         &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"},
+				},
+			}
+			err := p.Validate()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("filesystem, s3"))
+		})
+
+		It("should reject HDFS along with S3", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				Tier2: &v1alpha1.Tier2Spec{
+					Hdfs: &v1alpha1.HDFSSpec{Uri: "hdfs://namenode:8020"},
+					S3:   &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"},
+				},
+			}
+			Ω(p.Validate()).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Embedded ZooKeeper", func() {
+		BeforeEach(func() {
+			p.Namespace = "default"
+			p.Spec.Zookeeper = &v1alpha1.ZookeeperSpec{}
+		})
+
+		It("should connect to the client service", func() {
+			p.WithDefaults()
+			Ω(p.Spec.ZookeeperUri).Should(Equal("example-zookeeper-client.default:2181"))
+			Ω(p.Validate()).Should(BeNil())
+		})
+
+		It("should keep a zookeeperUri set by the user", func() {
+			p.Spec.ZookeeperUri = "zk-client:2181"
+			p.WithDefaults()
+			Ω(p.Spec.ZookeeperUri).Should(Equal("zk-client:2181"))
+		})
+
+		It("should reject a zookeeperUri of another ensemble", func() {
+			p.Spec.ZookeeperUri = "zk-client:2181"
+			err := p.Validate()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("zookeeperUri (zk-client:2181)"))
+		})
+
+		It("should accept the uri of the embedded ensemble", func() {
+			p.Spec.ZookeeperUri = p.EmbeddedZookeeperUri()
+			Ω(p.Validate()).Should(BeNil())
+		})
+	})
+
+	Context("Node ports", func() {
+		BeforeEach(func() {
+			p.Spec.ExternalAccess = &v1alpha1.ExternalAccess{Enabled: true, Type: v1.ServiceTypeNodePort, NodePortBase: 32760}
+			p.Spec.ECS = &v1alpha1.ECSSpec{NodeReplicas: 8}
+		})
+
+		It("should accept ports up to the end of the range", func() {
+			Ω(p.Validate()).Should(BeNil())
+		})
+
+		It("should reject a highest ordinal outside of the range", func() {
+			p.Spec.ECS.NodeReplicas = 9
+			err := p.Validate()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("32760-32768"))
+		})
+
+		It("should reject a base below the range", func() {
+			p.Spec.ExternalAccess.NodePortBase = 8080
+			Ω(p.Validate()).ShouldNot(BeNil())
+		})
+
+		It("should ignore the base of load balancers", func() {
+			p.Spec.ExternalAccess.Type = v1.ServiceTypeLoadBalancer
+			p.Spec.ExternalAccess.NodePortBase = 8080
+			Ω(p.Validate()).Should(BeNil())
+		})
+	})
+})
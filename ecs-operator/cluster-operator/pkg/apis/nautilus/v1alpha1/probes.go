@@ -0,0 +1,294 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import "fmt"
+
+// ProbeType is the kind of check performed by a probe
+type ProbeType string
+
+const (
+	// ProbeTypeExec runs a command in the container
+	ProbeTypeExec ProbeType = "exec"
+
+	// ProbeTypeTCPSocket checks that a port accepts connections
+	ProbeTypeTCPSocket ProbeType = "tcpSocket"
+
+	// ProbeTypeHTTPGet sends a GET request and expects a 2xx or 3xx response
+	ProbeTypeHTTPGet ProbeType = "httpGet"
+
+	// ProbeTypeGRPC calls the gRPC health checking service. It runs
+	// grpc_health_probe, which must be present in the image
+	ProbeTypeGRPC ProbeType = "grpc"
+)
+
+const (
+	// DefaultBookiePort is the port bookies listen on
+	DefaultBookiePort = 3181
+
+	// DefaultControllerGRPCPort is the port the controller serves clients on
+	DefaultControllerGRPCPort = 9090
+
+	// DefaultControllerRESTPort is the port of the controller REST API
+	DefaultControllerRESTPort = 10080
+
+	// DefaultNodePort is the port nodes serve clients on
+	DefaultNodePort = 12345
+
+	// ControllerHealthPath is the health endpoint of the controller REST API.
+	// It answers 200 when the controller is up and 503 otherwise
+	ControllerHealthPath = "/health"
+)
+
+// ProbesSpec defines the probes of a component. Unset probes and fields take
+// the defaults of the component
+type ProbesSpec struct {
+	// Readiness decides when the pod receives traffic
+	Readiness *ProbeSpec `json:"readiness,omitempty"`
+
+	// Liveness decides when the container is restarted
+	Liveness *ProbeSpec `json:"liveness,omitempty"`
+
+	// Startup bounds the time the container has to start. Liveness checks
+	// only begin once it has had the chance to succeed.
+	// The Kubernetes API this operator is built against has no startup
+	// probes, so the worst case startup time is added to the initial delay of
+	// the liveness probe instead. Only the initialDelaySeconds, periodSeconds
+	// and failureThreshold of the startup probe apply. Its type, command, port,
+	// path and timeoutSeconds are not used
+	Startup *ProbeSpec `json:"startup,omitempty"`
+}
+
+// ProbeSpec defines a single probe
+type ProbeSpec struct {
+	// Type is one of exec, tcpSocket, httpGet or grpc
+	Type ProbeType `json:"type,omitempty"`
+
+	// Command is the command run by exec probes
+	Command []string `json:"command,omitempty"`
+
+	// Port is the port checked by tcpSocket, httpGet and grpc probes
+	Port int32 `json:"port,omitempty"`
+
+	// Path is the path requested by httpGet probes
+	Path string `json:"path,omitempty"`
+
+	// InitialDelaySeconds is the time to wait after the container starts
+	// before the first check
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is the time between checks
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the time after which a check fails
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed checks after which
+	// the probe fails
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// BookieProbeDefaults returns the default probes of the bookies
+func BookieProbeDefaults() *ProbesSpec {
+	return &ProbesSpec{
+		// bookiesanity writes and reads back an entry
+		Readiness: &ProbeSpec{
+			Type:             ProbeTypeExec,
+			Command:          []string{"/bin/sh", "-c", "/opt/bookkeeper/bin/bookkeeper shell bookiesanity"},
+			PeriodSeconds:    10,
+			TimeoutSeconds:   10,
+			FailureThreshold: 3,
+		},
+		// If the bookie does not accept connections for 1 minute, it is
+		// restarted
+		Liveness: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultBookiePort,
+			PeriodSeconds:    15,
+			TimeoutSeconds:   5,
+			FailureThreshold: 4,
+		},
+		// Bookies replaying a large journal get up to 5 minutes to start
+		Startup: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultBookiePort,
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 30,
+		},
+	}
+}
+
+// ControllerProbeDefaults returns the default probes of the controllers
+func ControllerProbeDefaults() *ProbesSpec {
+	return &ProbesSpec{
+		Readiness: &ProbeSpec{
+			Type:             ProbeTypeHTTPGet,
+			Port:             DefaultControllerRESTPort,
+			Path:             ControllerHealthPath,
+			PeriodSeconds:    5,
+			TimeoutSeconds:   5,
+			FailureThreshold: 3,
+		},
+		Liveness: &ProbeSpec{
+			Type:             ProbeTypeHTTPGet,
+			Port:             DefaultControllerRESTPort,
+			Path:             ControllerHealthPath,
+			PeriodSeconds:    15,
+			TimeoutSeconds:   5,
+			FailureThreshold: 4,
+		},
+		// Controllers start fast. They get up to 2 minutes
+		Startup: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultControllerGRPCPort,
+			PeriodSeconds:    5,
+			TimeoutSeconds:   5,
+			FailureThreshold: 24,
+		},
+	}
+}
+
+// NodeProbeDefaults returns the default probes of the nodes
+func NodeProbeDefaults() *ProbesSpec {
+	return &ProbesSpec{
+		Readiness: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultNodePort,
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 3,
+		},
+		Liveness: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultNodePort,
+			PeriodSeconds:    15,
+			TimeoutSeconds:   5,
+			FailureThreshold: 4,
+		},
+		// Nodes wait for the allocation of their external address when
+		// external access is enabled. They get up to 5 minutes
+		Startup: &ProbeSpec{
+			Type:             ProbeTypeTCPSocket,
+			Port:             DefaultNodePort,
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 30,
+		},
+	}
+}
+
+// withDefaults fills the unset probes and fields from the defaults. Probes
+// whose type differs from the default check the given port of the component
+func (s *ProbesSpec) withDefaults(defaults *ProbesSpec, port int32) (changed bool) {
+	if s.Readiness == nil {
+		changed = true
+		s.Readiness = &ProbeSpec{}
+	}
+	if s.Readiness.withDefaults(defaults.Readiness, port) {
+		changed = true
+	}
+
+	if s.Liveness == nil {
+		changed = true
+		s.Liveness = &ProbeSpec{}
+	}
+	if s.Liveness.withDefaults(defaults.Liveness, port) {
+		changed = true
+	}
+
+	if s.Startup == nil {
+		changed = true
+		s.Startup = &ProbeSpec{}
+	}
+	if s.Startup.withDefaults(defaults.Startup, port) {
+		changed = true
+	}
+
+	return changed
+}
+
+func (s *ProbeSpec) withDefaults(defaults *ProbeSpec, port int32) (changed bool) {
+	if s.Type == "" {
+		changed = true
+		s.Type = defaults.Type
+	}
+
+	if s.Type == defaults.Type {
+		if s.Command == nil && defaults.Command != nil {
+			changed = true
+			s.Command = append([]string{}, defaults.Command...)
+		}
+		if s.Port == 0 && defaults.Port != 0 {
+			changed = true
+			s.Port = defaults.Port
+		}
+		if s.Path == "" && defaults.Path != "" {
+			changed = true
+			s.Path = defaults.Path
+		}
+	}
+
+	if s.Port == 0 && s.Type != ProbeTypeExec {
+		changed = true
+		s.Port = port
+	}
+
+	if s.Path == "" && s.Type == ProbeTypeHTTPGet {
+		changed = true
+		s.Path = "/"
+	}
+
+	if s.PeriodSeconds == 0 {
+		changed = true
+		s.PeriodSeconds = defaults.PeriodSeconds
+	}
+
+	if s.TimeoutSeconds == 0 {
+		changed = true
+		s.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+
+	if s.FailureThreshold == 0 {
+		changed = true
+		s.FailureThreshold = defaults.FailureThreshold
+	}
+
+	return changed
+}
+
+// validate checks the probes once the defaults of the component are applied,
+// since Validate runs before WithDefaults
+func (s *ProbesSpec) validate(component string, defaults *ProbesSpec, port int32) error {
+	probes := s.DeepCopy()
+	probes.withDefaults(defaults, port)
+
+	if err := probes.Readiness.validate(component, "readiness"); err != nil {
+		return err
+	}
+	if err := probes.Liveness.validate(component, "liveness"); err != nil {
+		return err
+	}
+	return probes.Startup.validate(component, "startup")
+}
+
+func (s *ProbeSpec) validate(component, probe string) error {
+	switch s.Type {
+	case ProbeTypeExec:
+		if len(s.Command) == 0 {
+			return fmt.Errorf("%s %s probe is of type exec but has no command", component, probe)
+		}
+	case ProbeTypeTCPSocket, ProbeTypeHTTPGet, ProbeTypeGRPC:
+	default:
+		return fmt.Errorf("%s %s probe has an unknown type (%s)", component, probe, s.Type)
+	}
+	return nil
+}
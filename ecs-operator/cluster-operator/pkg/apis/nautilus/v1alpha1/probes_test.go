@@ -0,0 +1,139 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1_test
+
+import (
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probes", func() {
+
+	var p *v1alpha1.ECSCluster
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "example",
+			},
+		}
+	})
+
+	Context("Defaults", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+		})
+
+		It("should use the component defaults", func() {
+			Ω(p.Spec.Bookkeeper.Probes).Should(Equal(v1alpha1.BookieProbeDefaults()))
+			Ω(p.Spec.ECS.ControllerProbes).Should(Equal(v1alpha1.ControllerProbeDefaults()))
+			Ω(p.Spec.ECS.NodeProbes).Should(Equal(v1alpha1.NodeProbeDefaults()))
+		})
+
+		It("should probe the controller health endpoint", func() {
+			probes := p.Spec.ECS.ControllerProbes
+			Ω(probes.Readiness.Path).Should(Equal(v1alpha1.ControllerHealthPath))
+			Ω(probes.Readiness.Port).Should(BeEquivalentTo(v1alpha1.DefaultControllerRESTPort))
+			Ω(probes.Liveness.Path).Should(Equal(v1alpha1.ControllerHealthPath))
+			Ω(probes.Liveness.Port).Should(BeEquivalentTo(v1alpha1.DefaultControllerRESTPort))
+		})
+	})
+
+	Context("Overrides", func() {
+		It("should keep the set fields and fill the others", func() {
+			p.Spec.Bookkeeper = &v1alpha1.BookkeeperSpec{
+				Probes: &v1alpha1.ProbesSpec{
+					Readiness: &v1alpha1.ProbeSpec{PeriodSeconds: 20},
+				},
+			}
+			p.WithDefaults()
+			readiness := p.Spec.Bookkeeper.Probes.Readiness
+			defaults := v1alpha1.BookieProbeDefaults().Readiness
+			Ω(readiness.PeriodSeconds).Should(BeEquivalentTo(20))
+			Ω(readiness.Type).Should(Equal(v1alpha1.ProbeTypeExec))
+			Ω(readiness.Command).Should(Equal(defaults.Command))
+			Ω(readiness.TimeoutSeconds).Should(Equal(defaults.TimeoutSeconds))
+			Ω(readiness.FailureThreshold).Should(Equal(defaults.FailureThreshold))
+		})
+
+		It("should check the component port when the type changes", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				ControllerProbes: &v1alpha1.ProbesSpec{
+					Liveness: &v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTypeGRPC},
+				},
+			}
+			p.WithDefaults()
+			liveness := p.Spec.ECS.ControllerProbes.Liveness
+			Ω(liveness.Port).Should(BeEquivalentTo(v1alpha1.DefaultControllerGRPCPort))
+			Ω(liveness.Path).Should(BeEmpty())
+		})
+
+		It("should request / on an httpGet probe without a path", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				NodeProbes: &v1alpha1.ProbesSpec{
+					Readiness: &v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTypeHTTPGet, Port: 8080},
+				},
+			}
+			p.WithDefaults()
+			readiness := p.Spec.ECS.NodeProbes.Readiness
+			Ω(readiness.Port).Should(BeEquivalentTo(8080))
+			Ω(readiness.Path).Should(Equal("/"))
+		})
+
+		It("should not give a port to an exec probe", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				NodeProbes: &v1alpha1.ProbesSpec{
+					Liveness: &v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTypeExec, Command: []string{"true"}},
+				},
+			}
+			p.WithDefaults()
+			liveness := p.Spec.ECS.NodeProbes.Liveness
+			Ω(liveness.Port).Should(BeZero())
+			Ω(liveness.Command).Should(Equal([]string{"true"}))
+		})
+	})
+
+	Context("Validation", func() {
+		It("should accept an exec probe inheriting the default command", func() {
+			p.Spec.Bookkeeper = &v1alpha1.BookkeeperSpec{
+				Probes: &v1alpha1.ProbesSpec{
+					Readiness: &v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTypeExec},
+				},
+			}
+			Ω(p.Validate()).Should(BeNil())
+		})
+
+		It("should reject an exec probe without a command", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				NodeProbes: &v1alpha1.ProbesSpec{
+					Liveness: &v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTypeExec},
+				},
+			}
+			err := p.Validate()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("node liveness probe"))
+		})
+
+		It("should reject an unknown type", func() {
+			p.Spec.ECS = &v1alpha1.ECSSpec{
+				ControllerProbes: &v1alpha1.ProbesSpec{
+					Startup: &v1alpha1.ProbeSpec{Type: "http"},
+				},
+			}
+			err := p.Validate()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("controller startup probe"))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultBookieRunAsUser is the bookkeeper user of the BookKeeper image,
+	// which the bookies and AutoRecovery run as by default
+	DefaultBookieRunAsUser int64 = 10000
+
+	// DefaultZookeeperRunAsUser is the zookeeper user of the ZooKeeper image
+	DefaultZookeeperRunAsUser int64 = 1000
+
+	// DefaultECSRunAsUser is the user the controllers and nodes run as by
+	// default. The ECS image has no user of its own and runs as root
+	DefaultECSRunAsUser int64 = 1000
+
+	// DefaultSeccompProfile is the seccomp profile of the container runtime
+	DefaultSeccompProfile = "runtime/default"
+)
+
+// SecuritySpec defines the security settings of the pods of a component.
+// Unset fields take hardened defaults that pass the restricted Pod Security
+// Standard
+type SecuritySpec struct {
+	// PodSecurityContext is the security context of the pods. It defaults to
+	// running as the non-root user of the image, with volumes owned by an
+	// fsGroup of the same id
+	PodSecurityContext *v1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is the security context of the containers. It defaults
+	// to no privilege escalation and no capabilities
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+
+	// SeccompProfile is the seccomp profile of the pods, e.g. runtime/default,
+	// unconfined or localhost/<profile>.
+	// The Kubernetes API this operator is built against has no seccomp field,
+	// so it is set with the seccomp.security.alpha.kubernetes.io/pod
+	// annotation
+	SeccompProfile string `json:"seccompProfile,omitempty"`
+
+	// ReadOnlyRootFilesystem mounts the root filesystem of the containers
+	// read-only. A writable emptyDir volume is then also mounted on /tmp, in
+	// addition to those always mounted on the conf and logs directories of
+	// the image
+	ReadOnlyRootFilesystem bool `json:"readOnlyRootFilesystem,omitempty"`
+}
+
+func (s *SecuritySpec) withDefaults(runAsUser int64) (changed bool) {
+	if s.PodSecurityContext == nil {
+		changed = true
+		s.PodSecurityContext = &v1.PodSecurityContext{}
+	}
+
+	if s.PodSecurityContext.RunAsNonRoot == nil {
+		changed = true
+		runAsNonRoot := true
+		s.PodSecurityContext.RunAsNonRoot = &runAsNonRoot
+	}
+
+	// Images that run as root by default need an explicit non-root user
+	if s.PodSecurityContext.RunAsUser == nil {
+		changed = true
+		s.PodSecurityContext.RunAsUser = &runAsUser
+	}
+
+	// The fsGroup lets the user write to its PVCs
+	if s.PodSecurityContext.FSGroup == nil {
+		changed = true
+		fsGroup := runAsUser
+		s.PodSecurityContext.FSGroup = &fsGroup
+	}
+
+	if s.SecurityContext == nil {
+		changed = true
+		s.SecurityContext = &v1.SecurityContext{}
+	}
+
+	if s.SecurityContext.AllowPrivilegeEscalation == nil {
+		changed = true
+		allowPrivilegeEscalation := false
+		s.SecurityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}
+
+	if s.SecurityContext.Capabilities == nil {
+		changed = true
+		s.SecurityContext.Capabilities = &v1.Capabilities{
+			Drop: []v1.Capability{"ALL"},
+		}
+	}
+
+	if s.SeccompProfile == "" {
+		changed = true
+		s.SeccompProfile = DefaultSeccompProfile
+	}
+
+	return changed
+}
@@ -0,0 +1,77 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1_test
+
+import (
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Security", func() {
+
+	var p *v1alpha1.ECSCluster
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "example",
+			},
+		}
+	})
+
+	Context("Defaults", func() {
+		BeforeEach(func() {
+			p.Spec.Zookeeper = &v1alpha1.ZookeeperSpec{}
+			p.WithDefaults()
+		})
+
+		It("should run the bookies as the user of the BookKeeper image", func() {
+			security := p.Spec.Bookkeeper.Security
+			Ω(*security.PodSecurityContext.RunAsUser).Should(Equal(v1alpha1.DefaultBookieRunAsUser))
+			Ω(*security.PodSecurityContext.FSGroup).Should(Equal(v1alpha1.DefaultBookieRunAsUser))
+			Ω(*security.PodSecurityContext.RunAsNonRoot).Should(BeTrue())
+		})
+
+		It("should run ZooKeeper as the user of the ZooKeeper image", func() {
+			security := p.Spec.Zookeeper.Security
+			Ω(*security.PodSecurityContext.RunAsUser).Should(Equal(v1alpha1.DefaultZookeeperRunAsUser))
+			Ω(*security.PodSecurityContext.FSGroup).Should(Equal(v1alpha1.DefaultZookeeperRunAsUser))
+		})
+
+		It("should run the controllers and nodes as a non-root user", func() {
+			for _, security := range []*v1alpha1.SecuritySpec{p.Spec.ECS.ControllerSecurity, p.Spec.ECS.NodeSecurity} {
+				Ω(*security.PodSecurityContext.RunAsUser).Should(Equal(v1alpha1.DefaultECSRunAsUser))
+				Ω(*security.PodSecurityContext.FSGroup).Should(Equal(v1alpha1.DefaultECSRunAsUser))
+				Ω(*security.SecurityContext.AllowPrivilegeEscalation).Should(BeFalse())
+				Ω(security.SeccompProfile).Should(Equal(v1alpha1.DefaultSeccompProfile))
+			}
+		})
+	})
+
+	Context("Overrides", func() {
+		It("should keep the fields that are set", func() {
+			runAsUser := int64(2000)
+			p.Spec.Bookkeeper = &v1alpha1.BookkeeperSpec{
+				Security: &v1alpha1.SecuritySpec{
+					PodSecurityContext: &v1.PodSecurityContext{RunAsUser: &runAsUser},
+				},
+			}
+			p.WithDefaults()
+			security := p.Spec.Bookkeeper.Security
+			Ω(*security.PodSecurityContext.RunAsUser).Should(BeEquivalentTo(2000))
+			Ω(*security.PodSecurityContext.FSGroup).Should(Equal(v1alpha1.DefaultBookieRunAsUser))
+		})
+	})
+})
@@ -19,7 +19,42 @@ import (
 type ClusterConditionType string
 
 const (
-	ClusterConditionPodsReady ClusterConditionType = "PodsReady"
+	ClusterConditionPodsReady      ClusterConditionType = "PodsReady"
+	ClusterConditionTier2Reachable ClusterConditionType = "Tier2Reachable"
+
+	// ClusterConditionEphemeralStorage is true when bookie or node data is
+	// stored in emptyDir volumes and is lost when pods are deleted
+	ClusterConditionEphemeralStorage ClusterConditionType = "EphemeralStorage"
+
+	// ClusterConditionAuditorElected is true when a BookKeeper auditor is
+	// elected to detect and replicate the ledgers of failed bookies
+	ClusterConditionAuditorElected ClusterConditionType = "AuditorElected"
+
+	// ClusterConditionReconciliationPaused is true while the reconciliation
+	// of the cluster is paused
+	ClusterConditionReconciliationPaused ClusterConditionType = "ReconciliationPaused"
+
+	// ClusterConditionZookeeperReachable is true when the operator can reach
+	// ZooKeeper and, once the bookies started, the metadata of the cluster
+	ClusterConditionZookeeperReachable ClusterConditionType = "ZookeeperReachable"
+
+	// ClusterConditionBookkeeperDegraded is true when the bookies registered
+	// in ZooKeeper do not match the ready bookie pods
+	ClusterConditionBookkeeperDegraded ClusterConditionType = "BookkeeperDegraded"
+
+	// ClusterConditionHealthy is true when the controllers report themselves
+	// healthy, every node is registered as a segment store and every segment
+	// container is assigned
+	ClusterConditionHealthy ClusterConditionType = "Healthy"
+
+	// ClusterConditionSpecValid is false when the spec holds conflicting
+	// settings, in which case the cluster is not reconciled
+	ClusterConditionSpecValid ClusterConditionType = "SpecValid"
+
+	// ClusterConditionJVMMemoryExceeded is true when the heap plus direct
+	// memory of a component exceed its memory limit, so that its pods may be
+	// OOM killed
+	ClusterConditionJVMMemoryExceeded ClusterConditionType = "JVMMemoryExceeded"
 )
 
 // ClusterStatus defines the observed state of ECSCluster
@@ -45,6 +80,35 @@ type ClusterStatus struct {
 
 	// Members is the ECS members in the cluster
 	Members MembersStatus `json:"members"`
+
+	// ExternalAddresses is the list of addresses that clients can use to reach
+	// each ECS node from outside Kubernetes.
+	// It is only populated when external access is enabled
+	ExternalAddresses []NodeExternalAddress `json:"externalAddresses,omitempty"`
+
+	// Bookkeeper is the state of the bookies as registered in ZooKeeper. It
+	// is not updated while ZooKeeper is unreachable
+	Bookkeeper *BookkeeperStatus `json:"bookkeeper,omitempty"`
+
+	// Controller is the state of the cluster as reported by the REST API of
+	// the controllers. It is not updated while they are unreachable
+	Controller *ControllerStatus `json:"controller,omitempty"`
+
+	// AutoRecovery is the status of the standalone BookKeeper AutoRecovery
+	// Deployment. It is only populated when AutoRecovery runs standalone
+	AutoRecovery *AutoRecoveryStatus `json:"autoRecovery,omitempty"`
+
+	// Restarts is the progress of the last rolling restart of each component
+	Restarts []RestartStatus `json:"restarts,omitempty"`
+
+	// Zookeeper is the result of the last ZooKeeper probe of the operator
+	Zookeeper *ZookeeperStatus `json:"zookeeper,omitempty"`
+
+	// Tier2 is the state of the last Tier 2 check of the operator
+	Tier2 *Tier2Status `json:"tier2,omitempty"`
+
+	// Auditor is the result of the last lookup of the BookKeeper auditor
+	Auditor *AuditorStatus `json:"auditor,omitempty"`
 }
 
 // MembersStatus is the status of the members of the cluster with both
@@ -54,6 +118,138 @@ type MembersStatus struct {
 	Unready []string `json:"unready"`
 }
 
+// BookkeeperStatus is the state of the bookies as registered in ZooKeeper.
+// Bookies are identified by their pod IP and port, or by their hostname and
+// port with useHostNameAsBookieID
+type BookkeeperStatus struct {
+	// RegisteredBookies are the IDs of the writable bookies
+	RegisteredBookies []string `json:"registeredBookies,omitempty"`
+
+	// ReadOnlyBookies are the IDs of the bookies in read-only mode, e.g.
+	// because their ledger disks are full
+	ReadOnlyBookies []string `json:"readOnlyBookies,omitempty"`
+
+	// MissingBookies are the ready bookie pods that are not registered
+	MissingBookies []string `json:"missingBookies,omitempty"`
+
+	// UnderReplicatedLedgers is the number of ledgers that are waiting to be
+	// replicated to other bookies
+	UnderReplicatedLedgers int32 `json:"underReplicatedLedgers"`
+
+	// LastLedgerCheckTime is when the under-replicated ledgers were last
+	// counted
+	LastLedgerCheckTime string `json:"lastLedgerCheckTime,omitempty"`
+}
+
+// ControllerStatus is the state of the cluster as reported by the REST API
+// of the controllers
+type ControllerStatus struct {
+	// Health is the health status of the controller that answered, e.g. UP
+	Health string `json:"health,omitempty"`
+
+	// SegmentStores are the segment stores registered with the controllers,
+	// as host:port
+	SegmentStores []string `json:"segmentStores,omitempty"`
+
+	// ContainerAssignment is the number of segment containers assigned to
+	// each segment store
+	ContainerAssignment []SegmentStoreContainers `json:"containerAssignment,omitempty"`
+
+	// LastCheckTime is when the controllers were last queried
+	LastCheckTime string `json:"lastCheckTime,omitempty"`
+}
+
+// SegmentStoreContainers is the number of segment containers assigned to a
+// segment store
+type SegmentStoreContainers struct {
+	SegmentStore string `json:"segmentStore"`
+	Containers   int32  `json:"containers"`
+}
+
+// AutoRecoveryStatus is the status of the standalone BookKeeper AutoRecovery
+// Deployment
+type AutoRecoveryStatus struct {
+	// Replicas is the number of desired AutoRecovery replicas
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of ready AutoRecovery replicas
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Auditor is the bookie ID of the elected auditor, if any
+	Auditor string `json:"auditor,omitempty"`
+}
+
+// ZookeeperStatus is the result of the last ZooKeeper probe of the operator
+type ZookeeperStatus struct {
+	// ZookeeperUri is the URI that was probed
+	ZookeeperUri string `json:"zookeeperUri"`
+
+	// LastProbeTime is when ZooKeeper was last probed
+	LastProbeTime string `json:"lastProbeTime"`
+
+	// LatencyMilliseconds is the round trip time of the last probe. It is
+	// unset when ZooKeeper is unreachable
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+}
+
+// Tier2Status is the state of the last Tier 2 check of the operator
+type Tier2Status struct {
+	// ObservedGeneration is the generation of the spec that was checked
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// LastCheckTime is when Tier 2 was last checked
+	LastCheckTime string `json:"lastCheckTime"`
+}
+
+// AuditorStatus is the result of the last lookup of the BookKeeper auditor
+// in ZooKeeper
+type AuditorStatus struct {
+	// BookieID is the bookie ID of the elected auditor, if any
+	BookieID string `json:"bookieId,omitempty"`
+
+	// LastCheckTime is when the auditor was last looked up
+	LastCheckTime string `json:"lastCheckTime"`
+}
+
+// RestartPhase is the phase of a rolling restart
+type RestartPhase string
+
+const (
+	RestartPhaseInProgress RestartPhase = "InProgress"
+	RestartPhaseCompleted  RestartPhase = "Completed"
+)
+
+// RestartStatus is the progress of the rolling restart of a component
+type RestartStatus struct {
+	// Component is one of bookie, controller or node
+	Component string `json:"component"`
+
+	// RestartedAt is the value of the restartedAt field that triggered the
+	// restart
+	RestartedAt string `json:"restartedAt"`
+
+	Phase RestartPhase `json:"phase"`
+
+	// Replicas is the number of replicas to restart
+	Replicas int32 `json:"replicas"`
+
+	// RestartedReplicas is the number of replicas already restarted
+	RestartedReplicas int32 `json:"restartedReplicas"`
+
+	// Message tells what the restart is waiting for, if anything
+	Message string `json:"message,omitempty"`
+}
+
+// NodeExternalAddress is the external endpoint advertised for a single ECS node
+type NodeExternalAddress struct {
+	// Node is the name of the ECS node pod
+	Node string `json:"node"`
+
+	// Address is the external endpoint in the form of "host:port".
+	// It is empty until the external IP address or hostname is allocated
+	Address string `json:"address,omitempty"`
+}
+
 // ClusterCondition shows the current condition of a ECS cluster.
 // Comply with k8s API conventions
 type ClusterCondition struct {
@@ -86,6 +282,129 @@ func (ps *ClusterStatus) SetPodsReadyConditionFalse() {
 	ps.setClusterCondition(*c)
 }
 
+func (ps *ClusterStatus) SetTier2ReachableConditionTrue() {
+	c := newClusterCondition(ClusterConditionTier2Reachable, corev1.ConditionTrue, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetTier2ReachableConditionFalse(reason, message string) {
+	c := newClusterCondition(ClusterConditionTier2Reachable, corev1.ConditionFalse, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetAuditorElectedConditionTrue(auditor string) {
+	c := newClusterCondition(ClusterConditionAuditorElected, corev1.ConditionTrue, "", "auditor is "+auditor)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetAuditorElectedConditionFalse(reason, message string) {
+	c := newClusterCondition(ClusterConditionAuditorElected, corev1.ConditionFalse, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetEphemeralStorageConditionTrue(message string) {
+	c := newClusterCondition(ClusterConditionEphemeralStorage, corev1.ConditionTrue, "DataLossOnRestart", message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetEphemeralStorageConditionFalse() {
+	c := newClusterCondition(ClusterConditionEphemeralStorage, corev1.ConditionFalse, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetZookeeperReachableConditionTrue() {
+	c := newClusterCondition(ClusterConditionZookeeperReachable, corev1.ConditionTrue, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetZookeeperReachableConditionFalse(reason, message string) {
+	c := newClusterCondition(ClusterConditionZookeeperReachable, corev1.ConditionFalse, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetBookkeeperDegradedConditionTrue(reason, message string) {
+	c := newClusterCondition(ClusterConditionBookkeeperDegraded, corev1.ConditionTrue, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetBookkeeperDegradedConditionFalse() {
+	c := newClusterCondition(ClusterConditionBookkeeperDegraded, corev1.ConditionFalse, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetBookkeeperDegradedConditionUnknown(reason, message string) {
+	c := newClusterCondition(ClusterConditionBookkeeperDegraded, corev1.ConditionUnknown, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetHealthyConditionTrue() {
+	c := newClusterCondition(ClusterConditionHealthy, corev1.ConditionTrue, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetHealthyConditionFalse(reason, message string) {
+	c := newClusterCondition(ClusterConditionHealthy, corev1.ConditionFalse, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetReconciliationPausedConditionTrue(reason, message string) {
+	c := newClusterCondition(ClusterConditionReconciliationPaused, corev1.ConditionTrue, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetReconciliationPausedConditionFalse() {
+	c := newClusterCondition(ClusterConditionReconciliationPaused, corev1.ConditionFalse, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetSpecValidConditionTrue() {
+	c := newClusterCondition(ClusterConditionSpecValid, corev1.ConditionTrue, "", "")
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetSpecValidConditionFalse(message string) {
+	c := newClusterCondition(ClusterConditionSpecValid, corev1.ConditionFalse, "InvalidSpec", message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetJVMMemoryExceededConditionTrue(message string) {
+	c := newClusterCondition(ClusterConditionJVMMemoryExceeded, corev1.ConditionTrue, "MemoryLimitExceeded", message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetJVMMemoryExceededConditionFalse() {
+	c := newClusterCondition(ClusterConditionJVMMemoryExceeded, corev1.ConditionFalse, "", "")
+	ps.setClusterCondition(*c)
+}
+
+// SetRestartStatus replaces the restart status of the same component
+func (ps *ClusterStatus) SetRestartStatus(restart RestartStatus) {
+	for i := range ps.Restarts {
+		if ps.Restarts[i].Component == restart.Component {
+			ps.Restarts[i] = restart
+			return
+		}
+	}
+	ps.Restarts = append(ps.Restarts, restart)
+}
+
+// GetRestartStatus returns the restart status of a component, or nil if it
+// was never restarted
+func (ps *ClusterStatus) GetRestartStatus(component string) *RestartStatus {
+	for i := range ps.Restarts {
+		if ps.Restarts[i].Component == component {
+			return &ps.Restarts[i]
+		}
+	}
+	return nil
+}
+
+// IsClusterConditionTrue reports whether the given condition is present and true
+func (ps *ClusterStatus) IsClusterConditionTrue(t ClusterConditionType) bool {
+	_, c := ps.GetClusterCondition(t)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
 func newClusterCondition(condType ClusterConditionType, status corev1.ConditionStatus, reason, message string) *ClusterCondition {
 	return &ClusterCondition{
 		Type:               condType,
@@ -88,4 +88,23 @@ var _ = Describe("ECSCluster Status", func() {
 			})
 		})
 	})
+
+	Context("set restart status", func() {
+		BeforeEach(func() {
+			p.Status.SetRestartStatus(v1alpha1.RestartStatus{Component: "bookie", RestartedAt: "1", Phase: v1alpha1.RestartPhaseCompleted})
+			p.Status.SetRestartStatus(v1alpha1.RestartStatus{Component: "node", RestartedAt: "1", Phase: v1alpha1.RestartPhaseCompleted})
+			p.Status.SetRestartStatus(v1alpha1.RestartStatus{Component: "bookie", RestartedAt: "2", Phase: v1alpha1.RestartPhaseInProgress})
+		})
+
+		It("should replace the status of the same component", func() {
+			Ω(p.Status.Restarts).To(HaveLen(2))
+			restart := p.Status.GetRestartStatus("bookie")
+			Ω(restart.RestartedAt).To(Equal("2"))
+			Ω(restart.Phase).To(Equal(v1alpha1.RestartPhaseInProgress))
+		})
+
+		It("should not have the status of other components", func() {
+			Ω(p.Status.GetRestartStatus("controller")).To(BeNil())
+		})
+	})
 })
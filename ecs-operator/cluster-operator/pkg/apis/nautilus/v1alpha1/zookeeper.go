@@ -0,0 +1,172 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// DefaultZookeeperImageRepository is the default Docker repository for
+	// the ZooKeeper image
+	DefaultZookeeperImageRepository = "zookeeper"
+
+	// DefaultZookeeperImageTag is the default tag used for the ZooKeeper
+	// Docker image
+	DefaultZookeeperImageTag = "3.4.13"
+
+	// DefaultZookeeperImagePullPolicy is the default image pull policy used
+	// for the ZooKeeper Docker image
+	DefaultZookeeperImagePullPolicy = v1.PullIfNotPresent
+
+	// DefaultZookeeperReplicas is the default size of the ZooKeeper ensemble
+	DefaultZookeeperReplicas = 3
+
+	// DefaultZookeeperVolumeSize is the default volume size for the ZooKeeper
+	// data volume
+	DefaultZookeeperVolumeSize = "10Gi"
+
+	// DefaultZookeeperRequestCPU is the default CPU request for ZooKeeper
+	DefaultZookeeperRequestCPU = "200m"
+
+	// DefaultZookeeperLimitCPU is the default CPU limit for ZooKeeper
+	DefaultZookeeperLimitCPU = "1"
+
+	// DefaultZookeeperRequestMemory is the default memory request for ZooKeeper
+	DefaultZookeeperRequestMemory = "256Mi"
+
+	// DefaultZookeeperLimitMemory is the default memory limit for ZooKeeper
+	DefaultZookeeperLimitMemory = "1Gi"
+
+	// DefaultZookeeperClientPort is the port ZooKeeper serves clients on
+	DefaultZookeeperClientPort = 2181
+)
+
+// ZookeeperSpec defines the ZooKeeper ensemble deployed by the operator for
+// the cluster. When it is set, zookeeperUri is set to the client Service of
+// the ensemble
+type ZookeeperSpec struct {
+	// Replicas is the number of ZooKeeper servers. An odd number tolerates
+	// as many failures as the next even one
+	Replicas int32 `json:"replicas"`
+
+	// Image defines the ZooKeeper Docker image to use. It must be compatible
+	// with the official ZooKeeper image
+	Image *ZookeeperImageSpec `json:"image"`
+
+	// Storage is the spec of the PVC of each ZooKeeper server
+	Storage *v1.PersistentVolumeClaimSpec `json:"storage"`
+
+	// Resources specifies the request and limit of resources of ZooKeeper
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Security overrides the security settings of the ZooKeeper pods
+	Security *SecuritySpec `json:"security,omitempty"`
+}
+
+// Quorum returns the number of servers that must be ready for the ensemble
+// to serve requests
+func (s *ZookeeperSpec) Quorum() int32 {
+	return s.Replicas/2 + 1
+}
+
+func (s *ZookeeperSpec) withDefaults() (changed bool) {
+	if s.Replicas < 1 {
+		changed = true
+		s.Replicas = DefaultZookeeperReplicas
+	}
+
+	if s.Image == nil {
+		changed = true
+		s.Image = &ZookeeperImageSpec{}
+	}
+	if s.Image.withDefaults() {
+		changed = true
+	}
+
+	if s.Storage == nil {
+		changed = true
+		s.Storage = &v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse(DefaultZookeeperVolumeSize),
+				},
+			},
+		}
+	}
+
+	if s.Resources == nil {
+		changed = true
+		s.Resources = &v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(DefaultZookeeperRequestCPU),
+				v1.ResourceMemory: resource.MustParse(DefaultZookeeperRequestMemory),
+			},
+			Limits: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(DefaultZookeeperLimitCPU),
+				v1.ResourceMemory: resource.MustParse(DefaultZookeeperLimitMemory),
+			},
+		}
+	}
+
+	if s.Security == nil {
+		changed = true
+		s.Security = &SecuritySpec{}
+	}
+	if s.Security.withDefaults(DefaultZookeeperRunAsUser) {
+		changed = true
+	}
+
+	return changed
+}
+
+// ZookeeperImageSpec defines the fields needed for a ZooKeeper Docker image
+type ZookeeperImageSpec struct {
+	ImageSpec
+}
+
+// String formats a container image struct as a Docker compatible repository string
+func (s *ZookeeperImageSpec) String() string {
+	return fmt.Sprintf("%s:%s", s.Repository, s.Tag)
+}
+
+func (s *ZookeeperImageSpec) withDefaults() (changed bool) {
+	if s.Repository == "" {
+		changed = true
+		s.Repository = DefaultZookeeperImageRepository
+	}
+
+	if s.Tag == "" {
+		changed = true
+		s.Tag = DefaultZookeeperImageTag
+	}
+
+	if s.PullPolicy == "" {
+		changed = true
+		s.PullPolicy = DefaultZookeeperImagePullPolicy
+	}
+
+	return changed
+}
+
+// EmbeddedZookeeperUri returns the address of the client Service of the
+// ZooKeeper ensemble deployed for the cluster. The namespace is included so
+// that the operator can reach it from its own namespace
+func (p *ECSCluster) EmbeddedZookeeperUri() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s-zookeeper-client:%d", p.Name, DefaultZookeeperClientPort)
+	}
+	return fmt.Sprintf("%s-zookeeper-client.%s:%d", p.Name, p.Namespace, DefaultZookeeperClientPort)
+}
@@ -22,9 +22,142 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRecoverySpec) DeepCopyInto(out *AutoRecoverySpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(BookkeeperImageSpec)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRecoverySpec.
+func (in *AutoRecoverySpec) DeepCopy() *AutoRecoverySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRecoverySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditorStatus) DeepCopyInto(out *AuditorStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditorStatus.
+func (in *AuditorStatus) DeepCopy() *AuditorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRecoveryStatus) DeepCopyInto(out *AutoRecoveryStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRecoveryStatus.
+func (in *AutoRecoveryStatus) DeepCopy() *AutoRecoveryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRecoveryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
+	*out = *in
+	if in.ZookeeperVolumeClaimTemplate != nil {
+		in, out := &in.ZookeeperVolumeClaimTemplate, &out.ZookeeperVolumeClaimTemplate
+		*out = new(v1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSpec.
+func (in *BackupSpec) DeepCopy() *BackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ClusterSpec != nil {
+		in, out := &in.ClusterSpec, &out.ClusterSpec
+		*out = new(ClusterSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeSnapshots != nil {
+		in, out := &in.VolumeSnapshots, &out.VolumeSnapshots
+		*out = make([]BackupVolumeSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatus.
+func (in *BackupStatus) DeepCopy() *BackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupVolumeSnapshot) DeepCopyInto(out *BackupVolumeSnapshot) {
+	*out = *in
+	in.Claim.DeepCopyInto(&out.Claim)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVolumeSnapshot.
+func (in *BackupVolumeSnapshot) DeepCopy() *BackupVolumeSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupVolumeSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BookkeeperImageSpec) DeepCopyInto(out *BookkeeperImageSpec) {
 	*out = *in
@@ -60,11 +193,31 @@ func (in *BookkeeperSpec) DeepCopyInto(out *BookkeeperSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.AutoRecoveryDeployment != nil {
+		in, out := &in.AutoRecoveryDeployment, &out.AutoRecoveryDeployment
+		*out = new(AutoRecoverySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
 		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.JVMOptions != nil {
+		in, out := &in.JVMOptions, &out.JVMOptions
+		*out = new(JVMOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Security != nil {
+		in, out := &in.Security, &out.Security
+		*out = new(SecuritySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Options != nil {
 		in, out := &in.Options, &out.Options
 		*out = make(map[string]string, len(*in))
@@ -85,6 +238,37 @@ func (in *BookkeeperSpec) DeepCopy() *BookkeeperSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookkeeperStatus) DeepCopyInto(out *BookkeeperStatus) {
+	*out = *in
+	if in.RegisteredBookies != nil {
+		in, out := &in.RegisteredBookies, &out.RegisteredBookies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReadOnlyBookies != nil {
+		in, out := &in.ReadOnlyBookies, &out.ReadOnlyBookies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MissingBookies != nil {
+		in, out := &in.MissingBookies, &out.MissingBookies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookkeeperStatus.
+func (in *BookkeeperStatus) DeepCopy() *BookkeeperStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BookkeeperStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BookkeeperStorageSpec) DeepCopyInto(out *BookkeeperStorageSpec) {
 	*out = *in
@@ -103,6 +287,20 @@ func (in *BookkeeperStorageSpec) DeepCopyInto(out *BookkeeperStorageSpec) {
 		*out = new(v1.PersistentVolumeClaimSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LedgerVolumeClaimTemplates != nil {
+		in, out := &in.LedgerVolumeClaimTemplates, &out.LedgerVolumeClaimTemplates
+		*out = make([]v1.PersistentVolumeClaimSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.JournalVolumeClaimTemplates != nil {
+		in, out := &in.JournalVolumeClaimTemplates, &out.JournalVolumeClaimTemplates
+		*out = make([]v1.PersistentVolumeClaimSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -135,10 +333,15 @@ func (in *ClusterCondition) DeepCopy() *ClusterCondition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 	*out = *in
+	if in.Zookeeper != nil {
+		in, out := &in.Zookeeper, &out.Zookeeper
+		*out = new(ZookeeperSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExternalAccess != nil {
 		in, out := &in.ExternalAccess, &out.ExternalAccess
 		*out = new(ExternalAccess)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Bookkeeper != nil {
 		in, out := &in.Bookkeeper, &out.Bookkeeper
@@ -150,6 +353,16 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(ECSSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Maintenance != nil {
+		in, out := &in.Maintenance, &out.Maintenance
+		*out = new(MaintenanceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -172,6 +385,46 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		copy(*out, *in)
 	}
 	in.Members.DeepCopyInto(&out.Members)
+	if in.ExternalAddresses != nil {
+		in, out := &in.ExternalAddresses, &out.ExternalAddresses
+		*out = make([]NodeExternalAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.Bookkeeper != nil {
+		in, out := &in.Bookkeeper, &out.Bookkeeper
+		*out = new(BookkeeperStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Controller != nil {
+		in, out := &in.Controller, &out.Controller
+		*out = new(ControllerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AutoRecovery != nil {
+		in, out := &in.AutoRecovery, &out.AutoRecovery
+		*out = new(AutoRecoveryStatus)
+		**out = **in
+	}
+	if in.Restarts != nil {
+		in, out := &in.Restarts, &out.Restarts
+		*out = make([]RestartStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Zookeeper != nil {
+		in, out := &in.Zookeeper, &out.Zookeeper
+		*out = new(ZookeeperStatus)
+		**out = **in
+	}
+	if in.Tier2 != nil {
+		in, out := &in.Tier2, &out.Tier2
+		*out = new(Tier2Status)
+		**out = **in
+	}
+	if in.Auditor != nil {
+		in, out := &in.Auditor, &out.Auditor
+		*out = new(AuditorStatus)
+		**out = **in
+	}
 	return
 }
 
@@ -185,6 +438,32 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerStatus) DeepCopyInto(out *ControllerStatus) {
+	*out = *in
+	if in.SegmentStores != nil {
+		in, out := &in.SegmentStores, &out.SegmentStores
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ContainerAssignment != nil {
+		in, out := &in.ContainerAssignment, &out.ContainerAssignment
+		*out = make([]SegmentStoreContainers, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerStatus.
+func (in *ControllerStatus) DeepCopy() *ControllerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECSSpec) DeepCopyInto(out *ECSSpec) {
 	*out = *in
@@ -204,6 +483,18 @@ func (in *ECSSpec) DeepCopy() *ECSSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalAccess) DeepCopyInto(out *ExternalAccess) {
 	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -220,10 +511,11 @@ func (in *ExternalAccess) DeepCopy() *ExternalAccess {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileSystemSpec) DeepCopyInto(out *FileSystemSpec) {
 	*out = *in
-	if in.PersistentVolumeClaim != nil {
-		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
-		*out = new(v1.PersistentVolumeClaimVolumeSource)
-		**out = **in
+	in.VolumeSource.DeepCopyInto(&out.VolumeSource)
+	if in.VolumeClaimTemplate != nil {
+		in, out := &in.VolumeClaimTemplate, &out.VolumeClaimTemplate
+		*out = new(v1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -238,9 +530,30 @@ func (in *FileSystemSpec) DeepCopy() *FileSystemSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HDFSKerberosSpec) DeepCopyInto(out *HDFSKerberosSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HDFSKerberosSpec.
+func (in *HDFSKerberosSpec) DeepCopy() *HDFSKerberosSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HDFSKerberosSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HDFSSpec) DeepCopyInto(out *HDFSSpec) {
 	*out = *in
+	if in.Kerberos != nil {
+		in, out := &in.Kerberos, &out.Kerberos
+		*out = new(HDFSKerberosSpec)
+		**out = **in
+	}
 	return
 }
 
@@ -270,6 +583,53 @@ func (in *ImageSpec) DeepCopy() *ImageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVMOptions) DeepCopyInto(out *JVMOptions) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVMOptions.
+func (in *JVMOptions) DeepCopy() *JVMOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(JVMOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceSpec) DeepCopyInto(out *MaintenanceSpec) {
+	*out = *in
+	if in.BookieOrdinals != nil {
+		in, out := &in.BookieOrdinals, &out.BookieOrdinals
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeOrdinals != nil {
+		in, out := &in.NodeOrdinals, &out.NodeOrdinals
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceSpec.
+func (in *MaintenanceSpec) DeepCopy() *MaintenanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MembersStatus) DeepCopyInto(out *MembersStatus) {
 	*out = *in
@@ -297,30 +657,143 @@ func (in *MembersStatus) DeepCopy() *MembersStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ECSCluster) DeepCopyInto(out *ECSCluster) {
+func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.ClientNamespaceSelector != nil {
+		in, out := &in.ClientNamespaceSelector, &out.ClientNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientPodSelector != nil {
+		in, out := &in.ClientPodSelector, &out.ClientPodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OperatorNamespaceSelector != nil {
+		in, out := &in.OperatorNamespaceSelector, &out.OperatorNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OperatorPodSelector != nil {
+		in, out := &in.OperatorPodSelector, &out.OperatorPodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSCluster.
-func (in *ECSCluster) DeepCopy() *ECSCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicySpec.
+func (in *NetworkPolicySpec) DeepCopy() *NetworkPolicySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ECSCluster)
+	out := new(NetworkPolicySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ECSCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeExternalAddress) DeepCopyInto(out *NodeExternalAddress) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeExternalAddress.
+func (in *NodeExternalAddress) DeepCopy() *NodeExternalAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeExternalAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECSCluster) DeepCopyInto(out *ECSCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSCluster.
+func (in *ECSCluster) DeepCopy() *ECSCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(ECSCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECSCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECSClusterBackup) DeepCopyInto(out *ECSClusterBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSClusterBackup.
+func (in *ECSClusterBackup) DeepCopy() *ECSClusterBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(ECSClusterBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECSClusterBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECSClusterBackupList) DeepCopyInto(out *ECSClusterBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ECSClusterBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSClusterBackupList.
+func (in *ECSClusterBackupList) DeepCopy() *ECSClusterBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ECSClusterBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECSClusterBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
 	return nil
 }
 
@@ -357,6 +830,67 @@ func (in *ECSClusterList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECSClusterRestore) DeepCopyInto(out *ECSClusterRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSClusterRestore.
+func (in *ECSClusterRestore) DeepCopy() *ECSClusterRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(ECSClusterRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECSClusterRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECSClusterRestoreList) DeepCopyInto(out *ECSClusterRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ECSClusterRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECSClusterRestoreList.
+func (in *ECSClusterRestoreList) DeepCopy() *ECSClusterRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(ECSClusterRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECSClusterRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECSImageSpec) DeepCopyInto(out *ECSImageSpec) {
 	*out = *in
@@ -409,6 +943,36 @@ func (in *ECSSpec) DeepCopyInto(out *ECSSpec) {
 		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ControllerJVMOptions != nil {
+		in, out := &in.ControllerJVMOptions, &out.ControllerJVMOptions
+		*out = new(JVMOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeJVMOptions != nil {
+		in, out := &in.NodeJVMOptions, &out.NodeJVMOptions
+		*out = new(JVMOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControllerProbes != nil {
+		in, out := &in.ControllerProbes, &out.ControllerProbes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeProbes != nil {
+		in, out := &in.NodeProbes, &out.NodeProbes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControllerSecurity != nil {
+		in, out := &in.ControllerSecurity, &out.ControllerSecurity
+		*out = new(SecuritySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSecurity != nil {
+		in, out := &in.NodeSecurity, &out.NodeSecurity
+		*out = new(SecuritySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -422,6 +986,173 @@ func (in *ECSSpec) DeepCopy() *ECSSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSpec.
+func (in *ProbeSpec) DeepCopy() *ProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesSpec) DeepCopyInto(out *ProbesSpec) {
+	*out = *in
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(ProbeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesSpec.
+func (in *ProbesSpec) DeepCopy() *ProbesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartStatus) DeepCopyInto(out *RestartStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartStatus.
+func (in *RestartStatus) DeepCopy() *RestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreSpec) DeepCopyInto(out *RestoreSpec) {
+	*out = *in
+	if in.Tier2 != nil {
+		in, out := &in.Tier2, &out.Tier2
+		*out = new(Tier2Spec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreSpec.
+func (in *RestoreSpec) DeepCopy() *RestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreStatus) DeepCopyInto(out *RestoreStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreStatus.
+func (in *RestoreStatus) DeepCopy() *RestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3Spec) DeepCopyInto(out *S3Spec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Spec.
+func (in *S3Spec) DeepCopy() *S3Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(S3Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecuritySpec) DeepCopyInto(out *SecuritySpec) {
+	*out = *in
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecuritySpec.
+func (in *SecuritySpec) DeepCopy() *SecuritySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SecuritySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SegmentStoreContainers) DeepCopyInto(out *SegmentStoreContainers) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SegmentStoreContainers.
+func (in *SegmentStoreContainers) DeepCopy() *SegmentStoreContainers {
+	if in == nil {
+		return nil
+	}
+	out := new(SegmentStoreContainers)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Tier2Spec) DeepCopyInto(out *Tier2Spec) {
 	*out = *in
@@ -438,6 +1169,11 @@ func (in *Tier2Spec) DeepCopyInto(out *Tier2Spec) {
 	if in.Hdfs != nil {
 		in, out := &in.Hdfs, &out.Hdfs
 		*out = new(HDFSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3Spec)
 		**out = **in
 	}
 	return
@@ -452,3 +1188,88 @@ func (in *Tier2Spec) DeepCopy() *Tier2Spec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Tier2Status) DeepCopyInto(out *Tier2Status) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Tier2Status.
+func (in *Tier2Status) DeepCopy() *Tier2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(Tier2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZookeeperImageSpec) DeepCopyInto(out *ZookeeperImageSpec) {
+	*out = *in
+	out.ImageSpec = in.ImageSpec
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperImageSpec.
+func (in *ZookeeperImageSpec) DeepCopy() *ZookeeperImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ZookeeperImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZookeeperSpec) DeepCopyInto(out *ZookeeperSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ZookeeperImageSpec)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(v1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Security != nil {
+		in, out := &in.Security, &out.Security
+		*out = new(SecuritySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperSpec.
+func (in *ZookeeperSpec) DeepCopy() *ZookeeperSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ZookeeperSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZookeeperStatus) DeepCopyInto(out *ZookeeperStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperStatus.
+func (in *ZookeeperStatus) DeepCopy() *ZookeeperStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZookeeperStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,16 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+// Package v1alpha1 contains the subset of the CSI snapshot v1alpha1 API used
+// by the operator to back up and restore clusters. The CSI external
+// snapshotter must be installed in the Kubernetes cluster
+// +k8s:deepcopy-gen=package,register
+// +groupName=snapshot.storage.k8s.io
+package v1alpha1
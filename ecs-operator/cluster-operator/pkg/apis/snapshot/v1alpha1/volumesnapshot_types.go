@@ -0,0 +1,71 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1alpha1
+
+import (
+	"k8s.io/api/core/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&VolumeSnapshot{}, &VolumeSnapshotList{})
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VolumeSnapshotList contains a list of VolumeSnapshot
+type VolumeSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeSnapshot `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VolumeSnapshot is a snapshot of a persistent volume taken by a CSI driver
+type VolumeSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolumeSnapshotSpec    `json:"spec"`
+	Status *VolumeSnapshotStatus `json:"status,omitempty"`
+}
+
+// VolumeSnapshotSpec defines the volume to snapshot
+type VolumeSnapshotSpec struct {
+	// Source is the PersistentVolumeClaim to snapshot
+	Source *v1.TypedLocalObjectReference `json:"source"`
+
+	// SnapshotContentName binds the snapshot to an existing snapshot content
+	SnapshotContentName string `json:"snapshotContentName,omitempty"`
+
+	// VolumeSnapshotClassName is the class used to take the snapshot. The
+	// default class of the CSI driver is used when empty
+	VolumeSnapshotClassName *string `json:"snapshotClassName,omitempty"`
+}
+
+// VolumeSnapshotStatus is the state of a snapshot
+type VolumeSnapshotStatus struct {
+	// CreationTime is the point in time the snapshot was cut. It is set
+	// before the snapshot is ready to use
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// RestoreSize is the minimum size of a volume restored from the snapshot
+	RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+
+	// ReadyToUse is true once volumes can be provisioned from the snapshot
+	ReadyToUse bool `json:"readyToUse"`
+
+	// Error is the last error taking the snapshot
+	Error *storagev1beta1.VolumeError `json:"error,omitempty"`
+}
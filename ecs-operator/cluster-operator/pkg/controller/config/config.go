@@ -10,9 +10,23 @@
 
 package config
 
+import "time"
+
 // TestMode enables test mode in the operator and applies
 // the following changes:
 // - Disables BookKeeper minimum number of replicas
 // - Disables ECS Controller minimum number of replicas
 // - Disables Segment Store minimum number of replicas
 var TestMode bool
+
+// MaxConcurrentReconciles is the number of ECSClusters that can be reconciled
+// at the same time
+var MaxConcurrentReconciles = 1
+
+// ResyncPeriod is the delay between periodic reconciliations of an ECSCluster
+// when nothing changes
+var ResyncPeriod = 10 * time.Minute
+
+// OperatorImage is the image of the operator. Backups and restores run it in
+// Jobs to export and import the ZooKeeper metadata of a cluster
+var OperatorImage string
@@ -0,0 +1,539 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecsclusterbackup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	snapshotv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/snapshot/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PollTime is the delay between reconciliations while waiting for the pods to
+// stop and the snapshots to be taken. Volume snapshots are not watched, as
+// their CRD is only installed along with a CSI snapshotter
+const PollTime = 5 * time.Second
+
+// ZnodesPath is the file holding the exported znodes in the ZooKeeper Jobs
+const ZnodesPath = zookeeperMountPath + "/znodes.json"
+
+const zookeeperMountPath = "/backup"
+
+// Add creates a new ECSClusterBackup Controller and adds it to the Manager
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileECSClusterBackup{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("ecsclusterbackup-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: controllerconfig.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{Type: &ecsv1alpha1.ECSClusterBackup{}}, &handler.EnqueueRequestForObject{})
+}
+
+var _ reconcile.Reconciler = &ReconcileECSClusterBackup{}
+
+// ReconcileECSClusterBackup reconciles a ECSClusterBackup object
+type ReconcileECSClusterBackup struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile moves a backup through its phases. The bookies and nodes are
+// stopped, the ZooKeeper metadata is exported, every volume is snapshotted
+// and the cluster is scaled back up as soon as the snapshots are cut
+func (r *ReconcileECSClusterBackup) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	log.Printf("Reconciling ECSClusterBackup %s/%s\n", request.Namespace, request.Name)
+
+	backup := &ecsv1alpha1.ECSClusterBackup{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, backup)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get ECSClusterBackup: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	if backup.Status.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+
+	if backup.WithDefaults() {
+		if err = r.client.Update(context.TODO(), backup); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{Requeue: true}, nil
+	}
+
+	p := &ecsv1alpha1.ECSCluster{}
+	name := types.NamespacedName{Name: backup.Spec.ClusterName, Namespace: backup.Namespace}
+	err = r.client.Get(context.TODO(), name, p)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.fail(backup, nil, fmt.Sprintf("ecs cluster (%s) not found", name.Name))
+		}
+		return reconcile.Result{}, err
+	}
+
+	err = r.run(backup, p)
+	if err != nil {
+		log.Printf("failed to reconcile ecs cluster backup (%s): %v", backup.Name, err)
+		return reconcile.Result{}, err
+	}
+
+	if backup.Status.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: PollTime}, nil
+}
+
+func (r *ReconcileECSClusterBackup) run(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) error {
+	if start := backup.Status.StartTime; start != nil {
+		timeout := time.Duration(backup.Spec.QuiesceTimeoutSeconds) * time.Second
+		if time.Since(start.Time) > timeout {
+			return r.fail(backup, p, fmt.Sprintf("backup did not complete within %v", timeout))
+		}
+	}
+
+	switch backup.Status.Phase {
+	case ecsv1alpha1.BackupPhasePending:
+		return r.start(backup, p)
+	case ecsv1alpha1.BackupPhaseQuiescing:
+		return r.quiesce(backup, p)
+	case ecsv1alpha1.BackupPhaseSnapshotting:
+		return r.snapshot(backup, p)
+	}
+	return nil
+}
+
+// start marks the cluster as quiesced by the backup, so that the cluster
+// controller does not scale the bookies and nodes back up
+func (r *ReconcileECSClusterBackup) start(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	if p.Spec.Bookkeeper.Storage.Ephemeral {
+		return r.fail(backup, p, "bookies with ephemeral storage cannot be backed up")
+	}
+
+	// Bookie IDs are recorded in the cookies and the ledger metadata. Pod IPs
+	// change when the cluster is restored, hostnames do not
+	if !util.UsesHostNameAsBookieID(p) {
+		return r.fail(backup, p, "bookies register with their pod IP, which restored bookies do not get back: set the bookkeeper option useHostNameAsBookieID to true")
+	}
+
+	if controllerconfig.OperatorImage == "" {
+		return r.fail(backup, p, "the image of the operator is unknown: set OPERATOR_IMAGE in the operator deployment")
+	}
+
+	if owner := p.Annotations[util.QuiesceAnnotation]; owner != "" && owner != backup.Name {
+		log.Printf("waiting for backup (%s) of ecs cluster (%s) to complete", owner, p.Name)
+		return nil
+	}
+
+	if p.Annotations == nil {
+		p.Annotations = map[string]string{}
+	}
+	p.Annotations[util.QuiesceAnnotation] = backup.Name
+	err = r.client.Update(context.TODO(), p)
+	if err != nil {
+		return fmt.Errorf("failed to quiesce ecs cluster (%s): %v", p.Name, err)
+	}
+
+	now := metav1.Now()
+	backup.Status.StartTime = &now
+	backup.Status.ClusterSpec = p.Spec.DeepCopy()
+	backup.Status.Phase = ecsv1alpha1.BackupPhaseQuiescing
+	return r.updateStatus(backup)
+}
+
+// quiesce stops the bookies and nodes, which makes the cluster unavailable.
+// Once every pod is gone, the ZooKeeper metadata is exported and the volumes
+// are snapshotted
+func (r *ReconcileECSClusterBackup) quiesce(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	for _, name := range []string{util.StatefulSetNameForBookie(p.Name), util.StatefulSetNameForNode(p.Name)} {
+		err = r.scaleDown(p.Namespace, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	running, err := r.countPods(p.Namespace, util.LabelsForBookie(p))
+	if err != nil {
+		return err
+	}
+	nodes, err := r.countPods(p.Namespace, util.LabelsForNode(p))
+	if err != nil {
+		return err
+	}
+	if running+nodes > 0 {
+		log.Printf("waiting for %d pods of ecs cluster (%s) to stop", running+nodes, p.Name)
+		return nil
+	}
+
+	exported, err := r.exportZookeeper(backup, p)
+	if err != nil {
+		return r.fail(backup, p, err.Error())
+	}
+	if !exported {
+		log.Printf("waiting for the zookeeper metadata of ecs cluster (%s) to be exported", p.Name)
+		return r.updateStatus(backup)
+	}
+
+	err = r.createSnapshots(backup, p)
+	if err != nil {
+		return r.fail(backup, p, err.Error())
+	}
+
+	backup.Status.Phase = ecsv1alpha1.BackupPhaseSnapshotting
+	return r.updateStatus(backup)
+}
+
+func (r *ReconcileECSClusterBackup) scaleDown(namespace string, name string) (err error) {
+	sts := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, sts)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get stateful-set (%s): %v", name, err)
+	}
+
+	if sts.Spec.Replicas != nil && *sts.Spec.Replicas == 0 {
+		return nil
+	}
+
+	var replicas int32
+	sts.Spec.Replicas = &replicas
+	err = r.client.Update(context.TODO(), sts)
+	if err != nil {
+		return fmt.Errorf("failed to scale down stateful-set (%s): %v", name, err)
+	}
+	return nil
+}
+
+func (r *ReconcileECSClusterBackup) countPods(namespace string, podLabels map[string]string) (int, error) {
+	podList := &corev1.PodList{}
+	listOps := &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.SelectorFromSet(podLabels),
+	}
+	err := r.client.List(context.TODO(), listOps, podList)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods: %v", err)
+	}
+	return len(podList.Items), nil
+}
+
+// exportZookeeper runs a Job writing the persistent znodes of the cluster to
+// a volume owned by the backup, since they may not fit in a ConfigMap. It
+// returns true once the Job succeeded
+func (r *ReconcileECSClusterBackup) exportZookeeper(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (exported bool, err error) {
+	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.PvcNameForBackup(backup.Name),
+			Namespace: backup.Namespace,
+		},
+		Spec: *backup.Spec.ZookeeperVolumeClaimTemplate.DeepCopy(),
+	}
+	controllerutil.SetControllerReference(backup, pvc, r.scheme)
+	err = r.client.Create(context.TODO(), pvc)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("failed to create pvc (%s): %v", pvc.Name, err)
+	}
+	backup.Status.ZookeeperClaim = pvc.Name
+
+	args := []string{"export-zookeeper", "-zookeeper-uri", p.Spec.ZookeeperUri, "-cluster", p.Name, "-f", ZnodesPath}
+	// Exporting again overwrites the file, so a failed export can be retried
+	job := MakeZookeeperJob(util.JobNameForBackup(backup.Name), backup.Namespace, pvc.Name, 2, args)
+	controllerutil.SetControllerReference(backup, job, r.scheme)
+
+	succeeded, failed, err := RunZookeeperJob(r.client, job)
+	if err != nil {
+		return false, err
+	}
+	if failed {
+		return false, fmt.Errorf("failed to export zookeeper metadata: job (%s) failed", job.Name)
+	}
+	return succeeded, nil
+}
+
+// MakeZookeeperJob returns a Job running a ZooKeeper command of the operator
+// image, with the volume of a backup mounted
+func MakeZookeeperJob(name string, namespace string, claimName string, backoffLimit int32, args []string) *batchv1.Job {
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "zookeeper",
+							Image:   controllerconfig.OperatorImage,
+							Command: append([]string{"ecs-operator"}, args...),
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "backup",
+									MountPath: zookeeperMountPath,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "backup",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: claimName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// RunZookeeperJob creates the Job if it does not exist yet and returns whether
+// it succeeded or failed
+func RunZookeeperJob(c client.Client, job *batchv1.Job) (succeeded bool, failed bool, err error) {
+	current := &batchv1.Job{}
+	err = c.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, current)
+	if errors.IsNotFound(err) {
+		err = c.Create(context.TODO(), job)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to create job (%s): %v", job.Name, err)
+		}
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get job (%s): %v", job.Name, err)
+	}
+
+	for _, condition := range current.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return false, true, nil
+		}
+	}
+	return current.Status.Succeeded > 0, false, nil
+}
+
+// createSnapshots takes a snapshot of every bookie and node volume. Volumes
+// left behind by scaled down pods are skipped
+func (r *ReconcileECSClusterBackup) createSnapshots(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	components := []struct {
+		name     string
+		sts      string
+		labels   map[string]string
+		replicas int32
+		skip     bool
+	}{
+		{"bookie", util.StatefulSetNameForBookie(p.Name), util.LabelsForBookie(p), p.Spec.Bookkeeper.Replicas, false},
+		// An ephemeral cache is rebuilt from Tier 2
+		{"node", util.StatefulSetNameForNode(p.Name), util.LabelsForNode(p), p.Spec.ECS.NodeReplicas, p.Spec.ECS.EphemeralCache},
+	}
+
+	backup.Status.VolumeSnapshots = nil
+	for _, component := range components {
+		if component.skip {
+			continue
+		}
+
+		pvcList := &corev1.PersistentVolumeClaimList{}
+		listOps := &client.ListOptions{
+			Namespace:     p.Namespace,
+			LabelSelector: labels.SelectorFromSet(component.labels),
+		}
+		err = r.client.List(context.TODO(), listOps, pvcList)
+		if err != nil {
+			return fmt.Errorf("failed to list pvcs: %v", err)
+		}
+
+		for _, pvc := range pvcList.Items {
+			claimTemplate, ordinal, ok := util.ClaimTemplateForPvc(pvc.Name, component.sts)
+			if !ok || ordinal >= component.replicas {
+				continue
+			}
+
+			snapshot, err := r.createSnapshot(backup, &pvc)
+			if err != nil {
+				return err
+			}
+
+			claim := pvc.Spec.DeepCopy()
+			claim.VolumeName = ""
+			claim.DataSource = nil
+			backup.Status.VolumeSnapshots = append(backup.Status.VolumeSnapshots, ecsv1alpha1.BackupVolumeSnapshot{
+				Name:          snapshot.Name,
+				Component:     component.name,
+				ClaimTemplate: claimTemplate,
+				Ordinal:       ordinal,
+				Claim:         *claim,
+			})
+		}
+	}
+	return nil
+}
+
+func (r *ReconcileECSClusterBackup) createSnapshot(backup *ecsv1alpha1.ECSClusterBackup, pvc *corev1.PersistentVolumeClaim) (*snapshotv1alpha1.VolumeSnapshot, error) {
+	snapshot := &snapshotv1alpha1.VolumeSnapshot{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "VolumeSnapshot",
+			APIVersion: "snapshot.storage.k8s.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.VolumeSnapshotNameForBackup(backup.Name, pvc.Name),
+			Namespace: backup.Namespace,
+		},
+		Spec: snapshotv1alpha1.VolumeSnapshotSpec{
+			Source: &corev1.TypedLocalObjectReference{
+				Kind: "PersistentVolumeClaim",
+				Name: pvc.Name,
+			},
+		},
+	}
+	if backup.Spec.VolumeSnapshotClassName != "" {
+		className := backup.Spec.VolumeSnapshotClassName
+		snapshot.Spec.VolumeSnapshotClassName = &className
+	}
+
+	controllerutil.SetControllerReference(backup, snapshot, r.scheme)
+	err := r.client.Create(context.TODO(), snapshot)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create volume snapshot (%s): %v", snapshot.Name, err)
+	}
+	return snapshot, nil
+}
+
+// snapshot waits for the snapshots. The cluster is scaled back up once they
+// are all cut, without waiting for the CSI driver to upload them
+func (r *ReconcileECSClusterBackup) snapshot(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	cut, ready := true, true
+	for i := range backup.Status.VolumeSnapshots {
+		volumeSnapshot := &backup.Status.VolumeSnapshots[i]
+
+		snapshot := &snapshotv1alpha1.VolumeSnapshot{}
+		name := types.NamespacedName{Name: volumeSnapshot.Name, Namespace: backup.Namespace}
+		err = r.client.Get(context.TODO(), name, snapshot)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return r.fail(backup, p, fmt.Sprintf("volume snapshot (%s) was deleted", name.Name))
+			}
+			return fmt.Errorf("failed to get volume snapshot (%s): %v", name.Name, err)
+		}
+
+		status := snapshot.Status
+		if status != nil && status.Error != nil {
+			return r.fail(backup, p, fmt.Sprintf("failed to snapshot volume (%s): %s", snapshot.Spec.Source.Name, status.Error.Message))
+		}
+
+		volumeSnapshot.ReadyToUse = status != nil && status.ReadyToUse
+		cut = cut && status != nil && status.CreationTime != nil
+		ready = ready && volumeSnapshot.ReadyToUse
+	}
+
+	if cut {
+		err = r.unquiesce(backup, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ready {
+		now := metav1.Now()
+		backup.Status.Phase = ecsv1alpha1.BackupPhaseCompleted
+		backup.Status.CompletionTime = &now
+		log.Printf("backup (%s) of ecs cluster (%s) completed", backup.Name, p.Name)
+	}
+	return r.updateStatus(backup)
+}
+
+// unquiesce lets the cluster controller scale the bookies and nodes back up
+func (r *ReconcileECSClusterBackup) unquiesce(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	if p == nil || p.Annotations[util.QuiesceAnnotation] != backup.Name {
+		return nil
+	}
+
+	delete(p.Annotations, util.QuiesceAnnotation)
+	err = r.client.Update(context.TODO(), p)
+	if err != nil {
+		return fmt.Errorf("failed to unquiesce ecs cluster (%s): %v", p.Name, err)
+	}
+	return nil
+}
+
+func (r *ReconcileECSClusterBackup) fail(backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster, message string) (err error) {
+	log.Printf("backup (%s) failed: %s", backup.Name, message)
+
+	err = r.unquiesce(backup, p)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	backup.Status.Phase = ecsv1alpha1.BackupPhaseFailed
+	backup.Status.Message = message
+	backup.Status.CompletionTime = &now
+	return r.updateStatus(backup)
+}
+
+func (r *ReconcileECSClusterBackup) updateStatus(backup *ecsv1alpha1.ECSClusterBackup) error {
+	err := r.client.Status().Update(context.TODO(), backup)
+	if err != nil {
+		return fmt.Errorf("failed to update backup status: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecsclusterbackup
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	snapshotv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/snapshot/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECSClusterBackup controller", func() {
+	var (
+		s       = scheme.Scheme
+		p       *v1alpha1.ECSCluster
+		backup  *v1alpha1.ECSClusterBackup
+		objects []runtime.Object
+		r       *ReconcileECSClusterBackup
+		client  client.Client
+	)
+
+	BeforeEach(func() {
+		controllerconfig.OperatorImage = "ecs/ecs-operator:test"
+
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+		p.Spec.Bookkeeper.Options = map[string]string{"useHostNameAsBookieID": "true"}
+
+		backup = &v1alpha1.ECSClusterBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example-backup",
+				Namespace: "default",
+				UID:       "backup-uid",
+			},
+			Spec: v1alpha1.BackupSpec{
+				ClusterName: p.Name,
+			},
+		}
+		backup.WithDefaults()
+
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p, backup)
+		s.AddKnownTypes(snapshotv1alpha1.SchemeGroupVersion, &snapshotv1alpha1.VolumeSnapshot{}, &snapshotv1alpha1.VolumeSnapshotList{})
+		objects = nil
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(append(objects, p, backup)...)
+		r = &ReconcileECSClusterBackup{client: client, scheme: s}
+	})
+
+	AfterEach(func() {
+		controllerconfig.OperatorImage = ""
+	})
+
+	reconcileBackup := func() {
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: backup.Name, Namespace: backup.Namespace}}
+		_, err := r.Reconcile(req)
+		Ω(err).Should(BeNil())
+	}
+
+	getBackup := func() *v1alpha1.ECSClusterBackup {
+		found := &v1alpha1.ECSClusterBackup{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: backup.Name, Namespace: backup.Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	getCluster := func() *v1alpha1.ECSCluster {
+		found := &v1alpha1.ECSCluster{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	getJob := func() (*batchv1.Job, error) {
+		job := &batchv1.Job{}
+		err := client.Get(context.TODO(), types.NamespacedName{Name: util.JobNameForBackup(backup.Name), Namespace: backup.Namespace}, job)
+		return job, err
+	}
+
+	// quiesced moves the backup past its start
+	quiesced := func() {
+		p.Annotations = map[string]string{util.QuiesceAnnotation: backup.Name}
+		now := metav1.Now()
+		backup.Status.StartTime = &now
+		backup.Status.ClusterSpec = p.Spec.DeepCopy()
+		backup.Status.Phase = v1alpha1.BackupPhaseQuiescing
+	}
+
+	Context("Start", func() {
+		It("should quiesce the cluster", func() {
+			reconcileBackup()
+			found := getBackup()
+			Ω(found.Status.Phase).Should(Equal(v1alpha1.BackupPhaseQuiescing))
+			Ω(found.Status.ClusterSpec).ShouldNot(BeNil())
+			Ω(getCluster().Annotations[util.QuiesceAnnotation]).Should(Equal(backup.Name))
+		})
+
+		It("should refuse bookies registered with their pod IP", func() {
+			p.Spec.Bookkeeper.Options = nil
+			reconcileBackup()
+			found := getBackup()
+			Ω(found.Status.Phase).Should(Equal(v1alpha1.BackupPhaseFailed))
+			Ω(found.Status.Message).Should(ContainSubstring("useHostNameAsBookieID"))
+			Ω(getCluster().Annotations[util.QuiesceAnnotation]).Should(BeEmpty())
+		})
+
+		It("should refuse ephemeral bookies", func() {
+			p.Spec.Bookkeeper.Storage.Ephemeral = true
+			reconcileBackup()
+			Ω(getBackup().Status.Phase).Should(Equal(v1alpha1.BackupPhaseFailed))
+		})
+
+		It("should wait for another backup of the cluster", func() {
+			p.Annotations = map[string]string{util.QuiesceAnnotation: "other-backup"}
+			reconcileBackup()
+			Ω(getBackup().Status.Phase).Should(Equal(v1alpha1.BackupPhasePending))
+		})
+	})
+
+	Context("Quiescing", func() {
+		var replicas int32 = 3
+
+		BeforeEach(func() {
+			quiesced()
+			for _, name := range []string{util.StatefulSetNameForBookie(p.Name), util.StatefulSetNameForNode(p.Name)} {
+				objects = append(objects, &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: p.Namespace},
+					Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				})
+			}
+		})
+
+		Context("Running bookies", func() {
+			BeforeEach(func() {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      util.StatefulSetNameForBookie(p.Name) + "-0",
+						Namespace: p.Namespace,
+						Labels:    util.LabelsForBookie(p),
+					},
+				})
+			})
+
+			It("should scale down and wait for the pods to stop", func() {
+				reconcileBackup()
+				sts := &appsv1.StatefulSet{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: util.StatefulSetNameForBookie(p.Name), Namespace: p.Namespace}, sts)).Should(Succeed())
+				Ω(*sts.Spec.Replicas).Should(BeZero())
+				_, err := getJob()
+				Ω(err).ShouldNot(BeNil())
+			})
+		})
+
+		Context("Stopped pods", func() {
+			It("should export the metadata to a volume owned by the backup", func() {
+				reconcileBackup()
+				pvc := &corev1.PersistentVolumeClaim{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: util.PvcNameForBackup(backup.Name), Namespace: backup.Namespace}, pvc)).Should(Succeed())
+				Ω(pvc.OwnerReferences).Should(HaveLen(1))
+				Ω(pvc.OwnerReferences[0].UID).Should(Equal(backup.UID))
+
+				job, err := getJob()
+				Ω(err).Should(BeNil())
+				container := job.Spec.Template.Spec.Containers[0]
+				Ω(container.Image).Should(Equal(controllerconfig.OperatorImage))
+				Ω(container.Command).Should(ContainElement("export-zookeeper"))
+				Ω(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).Should(Equal(pvc.Name))
+
+				found := getBackup()
+				Ω(found.Status.Phase).Should(Equal(v1alpha1.BackupPhaseQuiescing))
+				Ω(found.Status.ZookeeperClaim).Should(Equal(pvc.Name))
+			})
+		})
+
+		Context("Exported metadata", func() {
+			var pvcName string
+
+			BeforeEach(func() {
+				job := MakeZookeeperJob(util.JobNameForBackup(backup.Name), backup.Namespace, util.PvcNameForBackup(backup.Name), 2, nil)
+				job.Status.Succeeded = 1
+				pvcName = util.PvcNameForStatefulSet("journal", util.StatefulSetNameForBookie(p.Name), 0)
+				objects = append(objects, job, &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      pvcName,
+						Namespace: p.Namespace,
+						Labels:    util.LabelsForBookie(p),
+					},
+				})
+			})
+
+			It("should snapshot the volumes", func() {
+				reconcileBackup()
+				found := getBackup()
+				Ω(found.Status.Phase).Should(Equal(v1alpha1.BackupPhaseSnapshotting))
+				Ω(found.Status.VolumeSnapshots).Should(HaveLen(1))
+				Ω(found.Status.VolumeSnapshots[0].ClaimTemplate).Should(Equal("journal"))
+
+				snapshot := &snapshotv1alpha1.VolumeSnapshot{}
+				name := types.NamespacedName{Name: util.VolumeSnapshotNameForBackup(backup.Name, pvcName), Namespace: backup.Namespace}
+				Ω(client.Get(context.TODO(), name, snapshot)).Should(Succeed())
+				Ω(snapshot.Spec.Source.Name).Should(Equal(pvcName))
+			})
+		})
+
+		Context("Failed export", func() {
+			BeforeEach(func() {
+				job := MakeZookeeperJob(util.JobNameForBackup(backup.Name), backup.Namespace, util.PvcNameForBackup(backup.Name), 2, nil)
+				job.Status.Conditions = []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+				}
+				objects = append(objects, job)
+			})
+
+			It("should fail the backup and release the cluster", func() {
+				reconcileBackup()
+				Ω(getBackup().Status.Phase).Should(Equal(v1alpha1.BackupPhaseFailed))
+				Ω(getCluster().Annotations[util.QuiesceAnnotation]).Should(BeEmpty())
+			})
+		})
+	})
+
+	Context("Snapshotting", func() {
+		var snapshot *snapshotv1alpha1.VolumeSnapshot
+
+		BeforeEach(func() {
+			quiesced()
+			backup.Status.Phase = v1alpha1.BackupPhaseSnapshotting
+			snapshot = &snapshotv1alpha1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-backup-journal-example-bookie-0", Namespace: backup.Namespace},
+				Spec: snapshotv1alpha1.VolumeSnapshotSpec{
+					Source: &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "journal-example-bookie-0"},
+				},
+			}
+			backup.Status.VolumeSnapshots = []v1alpha1.BackupVolumeSnapshot{
+				{Name: snapshot.Name, Component: "bookie", ClaimTemplate: "journal"},
+			}
+		})
+
+		It("should keep the cluster stopped until the snapshots are cut", func() {
+			objects = append(objects, snapshot)
+			reconcileBackup()
+			Ω(getCluster().Annotations[util.QuiesceAnnotation]).Should(Equal(backup.Name))
+		})
+
+		It("should release the cluster once the snapshots are cut", func() {
+			now := metav1.Now()
+			snapshot.Status = &snapshotv1alpha1.VolumeSnapshotStatus{CreationTime: &now}
+			objects = append(objects, snapshot)
+			reconcileBackup()
+			Ω(getCluster().Annotations[util.QuiesceAnnotation]).Should(BeEmpty())
+			Ω(getBackup().Status.Phase).Should(Equal(v1alpha1.BackupPhaseSnapshotting))
+		})
+
+		It("should complete once the snapshots are ready", func() {
+			now := metav1.Now()
+			snapshot.Status = &snapshotv1alpha1.VolumeSnapshotStatus{CreationTime: &now, ReadyToUse: true}
+			objects = append(objects, snapshot)
+			reconcileBackup()
+			Ω(getBackup().Status.Phase).Should(Equal(v1alpha1.BackupPhaseCompleted))
+		})
+	})
+})
@@ -0,0 +1,311 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecsclusterrestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	snapshotv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/snapshot/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/controller/ecsclusterbackup"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PollTime is the delay between reconciliations while waiting for the backup
+// to complete and the ZooKeeper metadata to be imported
+const PollTime = 10 * time.Second
+
+// Add creates a new ECSClusterRestore Controller and adds it to the Manager
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileECSClusterRestore{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("ecsclusterrestore-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: controllerconfig.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{Type: &ecsv1alpha1.ECSClusterRestore{}}, &handler.EnqueueRequestForObject{})
+}
+
+var _ reconcile.Reconciler = &ReconcileECSClusterRestore{}
+
+// ReconcileECSClusterRestore reconciles a ECSClusterRestore object
+type ReconcileECSClusterRestore struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile recreates a cluster from a completed backup. The ZooKeeper
+// metadata is imported by a Job, then the bookie and node PVCs are provisioned
+// from the snapshots before the cluster is created, so that its StatefulSets
+// adopt them
+func (r *ReconcileECSClusterRestore) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	log.Printf("Reconciling ECSClusterRestore %s/%s\n", request.Namespace, request.Name)
+
+	restore := &ecsv1alpha1.ECSClusterRestore{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, restore)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get ECSClusterRestore: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	if restore.Status.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+
+	backup := &ecsv1alpha1.ECSClusterBackup{}
+	name := types.NamespacedName{Name: restore.Spec.BackupName, Namespace: restore.Namespace}
+	err = r.client.Get(context.TODO(), name, backup)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.fail(restore, fmt.Sprintf("backup (%s) not found", name.Name))
+		}
+		return reconcile.Result{}, err
+	}
+
+	switch backup.Status.Phase {
+	case ecsv1alpha1.BackupPhaseCompleted:
+	case ecsv1alpha1.BackupPhaseFailed:
+		return reconcile.Result{}, r.fail(restore, fmt.Sprintf("backup (%s) failed: %s", backup.Name, backup.Status.Message))
+	default:
+		log.Printf("waiting for backup (%s) to complete", backup.Name)
+		return reconcile.Result{RequeueAfter: PollTime}, nil
+	}
+
+	err = r.run(restore, backup)
+	if err != nil {
+		log.Printf("failed to reconcile ecs cluster restore (%s): %v", restore.Name, err)
+		return reconcile.Result{}, err
+	}
+
+	if restore.Status.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: PollTime}, nil
+}
+
+func (r *ReconcileECSClusterRestore) run(restore *ecsv1alpha1.ECSClusterRestore, backup *ecsv1alpha1.ECSClusterBackup) (err error) {
+	p := &ecsv1alpha1.ECSCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ECSCluster",
+			APIVersion: "ecs.ecs.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backup.Spec.ClusterName,
+			Namespace: restore.Namespace,
+		},
+		Spec: *backup.Status.ClusterSpec.DeepCopy(),
+	}
+
+	switch restore.Status.Phase {
+	case ecsv1alpha1.RestorePhasePending:
+		return r.start(restore, backup, p)
+	case ecsv1alpha1.RestorePhaseImporting:
+		return r.importZookeeper(restore, backup, p)
+	case ecsv1alpha1.RestorePhaseRestoring:
+		return r.restore(restore, backup, p)
+	}
+	return nil
+}
+
+// start checks that the cluster can be restored and starts the import of its
+// ZooKeeper metadata
+func (r *ReconcileECSClusterRestore) start(restore *ecsv1alpha1.ECSClusterRestore, backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	// The metadata is imported before the cluster and its ensemble exist
+	if p.Spec.Zookeeper != nil {
+		return r.fail(restore, "clusters with an embedded zookeeper ensemble cannot be restored")
+	}
+
+	// Bookies are identified by their hostname, which contains the name of
+	// the cluster
+	if restore.Spec.ClusterName != "" && restore.Spec.ClusterName != p.Name {
+		return r.fail(restore, fmt.Sprintf("backup of ecs cluster (%s) cannot be restored under another name (%s)", p.Name, restore.Spec.ClusterName))
+	}
+
+	err = restore.Validate()
+	if err != nil {
+		return r.fail(restore, err.Error())
+	}
+
+	// The backed up cluster may still be running, or its Tier 2 may have been
+	// written to since the backup
+	if restore.Spec.Tier2.SameTarget(p.Spec.ECS.Tier2) {
+		return r.fail(restore, "restore tier2 is the tier2 of the backed up cluster")
+	}
+
+	if controllerconfig.OperatorImage == "" {
+		return r.fail(restore, "the image of the operator is unknown: set OPERATOR_IMAGE in the operator deployment")
+	}
+
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, &ecsv1alpha1.ECSCluster{})
+	if err == nil {
+		return r.fail(restore, fmt.Sprintf("ecs cluster (%s) already exists", p.Name))
+	}
+	if !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get ecs cluster (%s): %v", p.Name, err)
+	}
+
+	// The StatefulSets of the deleted cluster leave their PVCs behind. The
+	// restored cluster would adopt them instead of the snapshots
+	for _, volumeSnapshot := range backup.Status.VolumeSnapshots {
+		pvc := makePvc(p, volumeSnapshot)
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &corev1.PersistentVolumeClaim{})
+		if err == nil {
+			return r.fail(restore, fmt.Sprintf("pvc (%s) of the deleted ecs cluster still exists", pvc.Name))
+		}
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get pvc (%s): %v", pvc.Name, err)
+		}
+	}
+
+	restore.Status.Phase = ecsv1alpha1.RestorePhaseImporting
+	return r.importZookeeper(restore, backup, p)
+}
+
+// importZookeeper runs a Job creating the znodes exported by the backup. It
+// is not retried, since the znodes of a failed import are left behind
+func (r *ReconcileECSClusterRestore) importZookeeper(restore *ecsv1alpha1.ECSClusterRestore, backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	args := []string{"import-zookeeper", "-zookeeper-uri", p.Spec.ZookeeperUri, "-cluster", p.Name, "-f", ecsclusterbackup.ZnodesPath}
+	job := ecsclusterbackup.MakeZookeeperJob(util.JobNameForRestore(restore.Name), restore.Namespace, backup.Status.ZookeeperClaim, 0, args)
+	controllerutil.SetControllerReference(restore, job, r.scheme)
+
+	succeeded, failed, err := ecsclusterbackup.RunZookeeperJob(r.client, job)
+	if err != nil {
+		return err
+	}
+	if failed {
+		return r.fail(restore, fmt.Sprintf("failed to import zookeeper metadata: job (%s) failed", job.Name))
+	}
+	if !succeeded {
+		log.Printf("waiting for the zookeeper metadata of ecs cluster (%s) to be imported", p.Name)
+		return r.updateStatus(restore)
+	}
+
+	restore.Status.Phase = ecsv1alpha1.RestorePhaseRestoring
+	err = r.updateStatus(restore)
+	if err != nil {
+		return err
+	}
+	return r.restore(restore, backup, p)
+}
+
+// restore creates the volumes and the cluster, with the Tier 2 of the restore
+func (r *ReconcileECSClusterRestore) restore(restore *ecsv1alpha1.ECSClusterRestore, backup *ecsv1alpha1.ECSClusterBackup, p *ecsv1alpha1.ECSCluster) (err error) {
+	for _, volumeSnapshot := range backup.Status.VolumeSnapshots {
+		err = r.createPvc(p, volumeSnapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.Spec.ECS.Tier2 = restore.Spec.Tier2.DeepCopy()
+	err = r.client.Create(context.TODO(), p)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create ecs cluster (%s): %v", p.Name, err)
+	}
+
+	log.Printf("restored backup (%s) to ecs cluster (%s)", backup.Name, p.Name)
+	now := metav1.Now()
+	restore.Status.Phase = ecsv1alpha1.RestorePhaseCompleted
+	restore.Status.CompletionTime = &now
+	return r.updateStatus(restore)
+}
+
+// makePvc returns the PVC of a bookie or node ordinal provisioned from its
+// snapshot, with the name and labels its StatefulSet expects. The PVC is not
+// owned by the restore, so that it outlives it
+func makePvc(p *ecsv1alpha1.ECSCluster, volumeSnapshot ecsv1alpha1.BackupVolumeSnapshot) *corev1.PersistentVolumeClaim {
+	stsName := util.StatefulSetNameForBookie(p.Name)
+	pvcLabels := util.LabelsForBookie(p)
+	if volumeSnapshot.Component == "node" {
+		stsName = util.StatefulSetNameForNode(p.Name)
+		pvcLabels = util.LabelsForNode(p)
+	}
+
+	apiGroup := snapshotv1alpha1.SchemeGroupVersion.Group
+	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.PvcNameForStatefulSet(volumeSnapshot.ClaimTemplate, stsName, volumeSnapshot.Ordinal),
+			Namespace: p.Namespace,
+			Labels:    pvcLabels,
+		},
+		Spec: *volumeSnapshot.Claim.DeepCopy(),
+	}
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     volumeSnapshot.Name,
+	}
+	return pvc
+}
+
+func (r *ReconcileECSClusterRestore) createPvc(p *ecsv1alpha1.ECSCluster, volumeSnapshot ecsv1alpha1.BackupVolumeSnapshot) (err error) {
+	pvc := makePvc(p, volumeSnapshot)
+	err = r.client.Create(context.TODO(), pvc)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create pvc (%s): %v", pvc.Name, err)
+	}
+	return nil
+}
+
+func (r *ReconcileECSClusterRestore) fail(restore *ecsv1alpha1.ECSClusterRestore, message string) error {
+	log.Printf("restore (%s) failed: %s", restore.Name, message)
+
+	now := metav1.Now()
+	restore.Status.Phase = ecsv1alpha1.RestorePhaseFailed
+	restore.Status.Message = message
+	restore.Status.CompletionTime = &now
+	return r.updateStatus(restore)
+}
+
+func (r *ReconcileECSClusterRestore) updateStatus(restore *ecsv1alpha1.ECSClusterRestore) error {
+	err := r.client.Status().Update(context.TODO(), restore)
+	if err != nil {
+		return fmt.Errorf("failed to update restore status: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecsclusterrestore
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
+	"github.com/ecs/ecs-operator/pkg/controller/ecsclusterbackup"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECSClusterRestore controller", func() {
+	var (
+		s       = scheme.Scheme
+		p       *v1alpha1.ECSCluster
+		backup  *v1alpha1.ECSClusterBackup
+		restore *v1alpha1.ECSClusterRestore
+		objects []runtime.Object
+		r       *ReconcileECSClusterRestore
+		client  client.Client
+	)
+
+	BeforeEach(func() {
+		controllerconfig.OperatorImage = "ecs/ecs-operator:test"
+
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+
+		backup = &v1alpha1.ECSClusterBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example-backup",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.BackupSpec{
+				ClusterName: p.Name,
+			},
+			Status: v1alpha1.BackupStatus{
+				Phase:          v1alpha1.BackupPhaseCompleted,
+				ClusterSpec:    p.Spec.DeepCopy(),
+				ZookeeperClaim: util.PvcNameForBackup("example-backup"),
+				VolumeSnapshots: []v1alpha1.BackupVolumeSnapshot{
+					{Name: "example-backup-journal-example-bookie-0", Component: "bookie", ClaimTemplate: "journal"},
+				},
+			},
+		}
+
+		restore = &v1alpha1.ECSClusterRestore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example-restore",
+				Namespace: "default",
+				UID:       "restore-uid",
+			},
+			Spec: v1alpha1.RestoreSpec{
+				BackupName: backup.Name,
+				Tier2: &v1alpha1.Tier2Spec{
+					FileSystem: &v1alpha1.FileSystemSpec{
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "ecs-tier2-restored"},
+						},
+					},
+				},
+			},
+		}
+
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p, backup, restore)
+		objects = nil
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(append(objects, backup, restore)...)
+		r = &ReconcileECSClusterRestore{client: client, scheme: s}
+	})
+
+	AfterEach(func() {
+		controllerconfig.OperatorImage = ""
+	})
+
+	reconcileRestore := func() {
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}}
+		_, err := r.Reconcile(req)
+		Ω(err).Should(BeNil())
+	}
+
+	getRestore := func() *v1alpha1.ECSClusterRestore {
+		found := &v1alpha1.ECSClusterRestore{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	getJob := func() *batchv1.Job {
+		job := &batchv1.Job{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: util.JobNameForRestore(restore.Name), Namespace: restore.Namespace}, job)).Should(Succeed())
+		return job
+	}
+
+	importJob := func() *batchv1.Job {
+		return ecsclusterbackup.MakeZookeeperJob(util.JobNameForRestore(restore.Name), restore.Namespace, backup.Status.ZookeeperClaim, 0, nil)
+	}
+
+	Context("Start", func() {
+		It("should import the metadata from the volume of the backup", func() {
+			reconcileRestore()
+			Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseImporting))
+
+			job := getJob()
+			Ω(job.Spec.Template.Spec.Containers[0].Command).Should(ContainElement("import-zookeeper"))
+			Ω(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).Should(Equal(backup.Status.ZookeeperClaim))
+			Ω(*job.Spec.BackoffLimit).Should(BeZero())
+			Ω(job.OwnerReferences[0].UID).Should(Equal(restore.UID))
+		})
+
+		It("should require a tier2", func() {
+			restore.Spec.Tier2 = nil
+			reconcileRestore()
+			Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseFailed))
+		})
+
+		It("should refuse the tier2 of the backed up cluster", func() {
+			// The default claim of the backed up cluster
+			restore.Spec.Tier2 = &v1alpha1.Tier2Spec{}
+			reconcileRestore()
+			found := getRestore()
+			Ω(found.Status.Phase).Should(Equal(v1alpha1.RestorePhaseFailed))
+			Ω(found.Status.Message).Should(ContainSubstring("tier2"))
+		})
+
+		It("should refuse another cluster name", func() {
+			restore.Spec.ClusterName = "example-restored"
+			reconcileRestore()
+			Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseFailed))
+		})
+
+		Context("Existing cluster", func() {
+			BeforeEach(func() {
+				objects = append(objects, p)
+			})
+
+			It("should fail", func() {
+				reconcileRestore()
+				Ω(getRestore().Status.Message).Should(ContainSubstring("already exists"))
+			})
+		})
+
+		Context("Volumes of the deleted cluster", func() {
+			BeforeEach(func() {
+				objects = append(objects, &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "journal-example-bookie-0", Namespace: p.Namespace},
+				})
+			})
+
+			It("should fail", func() {
+				reconcileRestore()
+				found := getRestore()
+				Ω(found.Status.Phase).Should(Equal(v1alpha1.RestorePhaseFailed))
+				Ω(found.Status.Message).Should(ContainSubstring("journal-example-bookie-0"))
+			})
+		})
+
+		Context("Incomplete backup", func() {
+			BeforeEach(func() {
+				backup.Status.Phase = v1alpha1.BackupPhaseSnapshotting
+			})
+
+			It("should wait for the backup", func() {
+				reconcileRestore()
+				Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhasePending))
+			})
+		})
+	})
+
+	Context("Importing", func() {
+		BeforeEach(func() {
+			restore.Status.Phase = v1alpha1.RestorePhaseImporting
+		})
+
+		Context("Running import", func() {
+			BeforeEach(func() {
+				objects = append(objects, importJob())
+			})
+
+			It("should wait for the import", func() {
+				reconcileRestore()
+				Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseImporting))
+			})
+		})
+
+		Context("Failed import", func() {
+			BeforeEach(func() {
+				job := importJob()
+				job.Status.Conditions = []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+				}
+				objects = append(objects, job)
+			})
+
+			It("should fail the restore", func() {
+				reconcileRestore()
+				Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseFailed))
+			})
+		})
+
+		Context("Imported metadata", func() {
+			BeforeEach(func() {
+				job := importJob()
+				job.Status.Succeeded = 1
+				objects = append(objects, job)
+			})
+
+			It("should create the volumes and the cluster on the tier2 of the restore", func() {
+				reconcileRestore()
+				Ω(getRestore().Status.Phase).Should(Equal(v1alpha1.RestorePhaseCompleted))
+
+				pvc := &corev1.PersistentVolumeClaim{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: "journal-example-bookie-0", Namespace: p.Namespace}, pvc)).Should(Succeed())
+				Ω(pvc.Spec.DataSource.Name).Should(Equal(backup.Status.VolumeSnapshots[0].Name))
+				Ω(pvc.OwnerReferences).Should(BeEmpty())
+
+				found := &v1alpha1.ECSCluster{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, found)).Should(Succeed())
+				Ω(found.Spec.ECS.Tier2).Should(Equal(restore.Spec.Tier2))
+			})
+		})
+	})
+})
@@ -0,0 +1,125 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"strings"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// MakeAutoRecoveryDeployment returns the Deployment running BookKeeper
+// AutoRecovery apart from the bookies. It shares the bookie ConfigMap so that
+// both use the same ZooKeeper ledgers path
+func MakeAutoRecoveryDeployment(ecsCluster *v1alpha1.ECSCluster) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.DeploymentNameForAutoRecovery(ecsCluster.Name),
+			Namespace: ecsCluster.Namespace,
+			Labels:    util.LabelsForAutoRecovery(ecsCluster),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &ecsCluster.Spec.Bookkeeper.AutoRecoveryDeployment.Replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      util.LabelsForAutoRecovery(ecsCluster),
+					Annotations: makePodAnnotations("", ecsCluster.Spec.Bookkeeper.Security),
+				},
+				Spec: *makeAutoRecoveryPodSpec(ecsCluster.Name, ecsCluster.Spec.Bookkeeper),
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: util.LabelsForAutoRecovery(ecsCluster),
+			},
+		},
+	}
+}
+
+func makeAutoRecoveryPodSpec(clusterName string, bookkeeperSpec *v1alpha1.BookkeeperSpec) *corev1.PodSpec {
+	autoRecoverySpec := bookkeeperSpec.AutoRecoveryDeployment
+
+	// The bookie ConfigMap sizes the heap for a bookie, so it is overridden
+	// with options sized for the AutoRecovery container. The JVM options of
+	// the bookies are not applied
+	jvmOpts := makeJVMOpts(autoRecoverySpec.Resources, autoRecoveryJVMProfile, nil)
+
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:            "autorecovery",
+				Image:           autoRecoverySpec.Image.String(),
+				ImagePullPolicy: autoRecoverySpec.Image.PullPolicy,
+				Args: []string{
+					"/opt/bookkeeper/bin/bookkeeper",
+					"autorecovery",
+				},
+				EnvFrom: []corev1.EnvFromSource{
+					{
+						ConfigMapRef: &corev1.ConfigMapEnvSource{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: util.ConfigMapNameForBookie(clusterName),
+							},
+						},
+					},
+				},
+				Env: []corev1.EnvVar{
+					{
+						Name:  "BOOKIE_MEM_OPTS",
+						Value: strings.Join(oomOpts, " "),
+					},
+					{
+						Name:  "BOOKIE_GC_OPTS",
+						Value: strings.Join(jvmOpts, " "),
+					},
+				},
+				Resources: *autoRecoverySpec.Resources,
+			},
+		},
+		Affinity: util.PodAntiAffinity("bookie-autorecovery", clusterName),
+	}
+
+	if bookkeeperSpec.ServiceAccountName != "" {
+		podSpec.ServiceAccountName = bookkeeperSpec.ServiceAccountName
+	}
+
+	configureSecurity(podSpec, bookkeeperSpec.Security, bookieConfMountPath, bookieLogsMountPath)
+
+	return podSpec
+}
+
+func MakeAutoRecoveryPodDisruptionBudget(ecsCluster *v1alpha1.ECSCluster) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.PdbNameForAutoRecovery(ecsCluster.Name),
+			Namespace: ecsCluster.Namespace,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: util.LabelsForAutoRecovery(ecsCluster),
+			},
+		},
+	}
+}
@@ -27,6 +27,14 @@ const (
 	LedgerDiskName  = "ledger"
 	JournalDiskName = "journal"
 	IndexDiskName   = "index"
+
+	ledgerMountPath  = "/bk/ledgers"
+	journalMountPath = "/bk/journal"
+	indexMountPath   = "/bk/index"
+
+	// BookieAutoRecoveryKey is the bookie ConfigMap key that runs
+	// AutoRecovery inside the bookies
+	BookieAutoRecoveryKey = "BK_AUTORECOVERY"
 )
 
 func MakeBookieHeadlessService(ecsCluster *v1alpha1.ECSCluster) *corev1.Service {
@@ -47,6 +55,8 @@ func MakeBookieHeadlessService(ecsCluster *v1alpha1.ECSCluster) *corev1.Service
 					Port: 3181,
 				},
 			},
+			// The governing Service selects cordoned bookies too, so that
+			// they keep their DNS names
 			Selector:  util.LabelsForBookie(ecsCluster),
 			ClusterIP: corev1.ClusterIPNone,
 		},
@@ -54,7 +64,7 @@ func MakeBookieHeadlessService(ecsCluster *v1alpha1.ECSCluster) *corev1.Service
 }
 
 func MakeBookieStatefulSet(ecsCluster *v1alpha1.ECSCluster) *appsv1.StatefulSet {
-	return &appsv1.StatefulSet{
+	statefulSet := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
 			APIVersion: "apps/v1",
@@ -72,21 +82,29 @@ func MakeBookieStatefulSet(ecsCluster *v1alpha1.ECSCluster) *appsv1.StatefulSet
 			Selector: &metav1.LabelSelector{
 				MatchLabels: util.LabelsForBookie(ecsCluster),
 			},
-			VolumeClaimTemplates: makeBookieVolumeClaimTemplates(ecsCluster.Spec.Bookkeeper),
 		},
 	}
+
+	// Ephemeral bookies use emptyDir volumes set in the pod spec instead
+	if !ecsCluster.Spec.Bookkeeper.Storage.Ephemeral {
+		statefulSet.Spec.VolumeClaimTemplates = makeBookieVolumeClaimTemplates(ecsCluster.Spec.Bookkeeper)
+	}
+	return statefulSet
 }
 
 func makeBookieStatefulTemplate(ecsCluster *v1alpha1.ECSCluster) corev1.PodTemplateSpec {
 	return corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Labels: util.LabelsForBookie(ecsCluster),
+			Labels:      util.ServiceLabelsForBookie(ecsCluster),
+			Annotations: makePodAnnotations(ecsCluster.Spec.Bookkeeper.RestartedAt, ecsCluster.Spec.Bookkeeper.Security),
 		},
 		Spec: *makeBookiePodSpec(ecsCluster.Name, ecsCluster.Spec.Bookkeeper),
 	}
 }
 
 func makeBookiePodSpec(clusterName string, bookkeeperSpec *v1alpha1.BookkeeperSpec) *corev1.PodSpec {
+	readinessProbe, livenessProbe := makeProbes(bookkeeperSpec.Probes)
+
 	podSpec := &corev1.PodSpec{
 		Containers: []corev1.Container{
 			{
@@ -108,46 +126,10 @@ func makeBookiePodSpec(clusterName string, bookkeeperSpec *v1alpha1.BookkeeperSp
 						},
 					},
 				},
-				VolumeMounts: []corev1.VolumeMount{
-					{
-						Name:      LedgerDiskName,
-						MountPath: "/bk/journal",
-					},
-					{
-						Name:      JournalDiskName,
-						MountPath: "/bk/ledgers",
-					},
-					{
-						Name:      IndexDiskName,
-						MountPath: "/bk/index",
-					},
-				},
-				Resources: *bookkeeperSpec.Resources,
-				ReadinessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: []string{"/bin/sh", "-c", "/opt/bookkeeper/bin/bookkeeper shell bookiesanity"},
-						},
-					},
-					// Bookie pods should start fast. We give it up to 1.5 minute to become ready.
-					InitialDelaySeconds: 20,
-					PeriodSeconds:       10,
-					FailureThreshold:    9,
-				},
-				LivenessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: util.HealthcheckCommand(3181),
-						},
-					},
-					// We start the liveness probe from the maximum time the pod can take
-					// before becoming ready.
-					// If the pod fails the health check during 1 minute, Kubernetes
-					// will restart it.
-					InitialDelaySeconds: 60,
-					PeriodSeconds:       15,
-					FailureThreshold:    4,
-				},
+				VolumeMounts:   makeBookieVolumeMounts(bookkeeperSpec.Storage),
+				Resources:      *bookkeeperSpec.Resources,
+				ReadinessProbe: readinessProbe,
+				LivenessProbe:  livenessProbe,
 			},
 		},
 		Affinity: util.PodAntiAffinity("bookie", clusterName),
@@ -157,56 +139,141 @@ func makeBookiePodSpec(clusterName string, bookkeeperSpec *v1alpha1.BookkeeperSp
 		podSpec.ServiceAccountName = bookkeeperSpec.ServiceAccountName
 	}
 
+	if bookkeeperSpec.Storage.Ephemeral {
+		podSpec.Volumes = makeEphemeralVolumes(makeBookieVolumeClaimTemplates(bookkeeperSpec))
+	}
+
+	configureSecurity(podSpec, bookkeeperSpec.Security, bookieConfMountPath, bookieLogsMountPath)
+
 	return podSpec
 }
 
+// bookieDirectory is a journal or ledger directory of a bookie, backed by its
+// own volume
+type bookieDirectory struct {
+	diskName  string
+	mountPath string
+}
+
+// bookieDirectories returns one directory per claim template. The first one
+// keeps the name and path used by single-directory bookies
+func bookieDirectories(diskName string, mountPath string, count int) []bookieDirectory {
+	dirs := make([]bookieDirectory, count)
+	for i := range dirs {
+		dirs[i] = bookieDirectory{diskName: diskName, mountPath: mountPath}
+		if i > 0 {
+			dirs[i].diskName = fmt.Sprintf("%s-%d", diskName, i)
+			dirs[i].mountPath = fmt.Sprintf("%s-%d", mountPath, i)
+		}
+	}
+	return dirs
+}
+
+func journalDirectories(storage *v1alpha1.BookkeeperStorageSpec) []bookieDirectory {
+	return bookieDirectories(JournalDiskName, journalMountPath, len(storage.JournalTemplates()))
+}
+
+func ledgerDirectories(storage *v1alpha1.BookkeeperStorageSpec) []bookieDirectory {
+	return bookieDirectories(LedgerDiskName, ledgerMountPath, len(storage.LedgerTemplates()))
+}
+
+func joinMountPaths(dirs []bookieDirectory) string {
+	paths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		paths[i] = dir.mountPath
+	}
+	return strings.Join(paths, ",")
+}
+
+func makeBookieVolumeMounts(storage *v1alpha1.BookkeeperStorageSpec) []corev1.VolumeMount {
+	journals := journalDirectories(storage)
+	ledgers := ledgerDirectories(storage)
+
+	// Bookies created by earlier operator versions keep their journal in the
+	// "ledger" claim and their ledgers in the "journal" claim
+	if storage.LegacyVolumeMounts {
+		journals[0].diskName, ledgers[0].diskName = ledgers[0].diskName, journals[0].diskName
+	}
+
+	var mounts []corev1.VolumeMount
+	for _, dir := range append(journals, ledgers...) {
+		mounts = append(mounts, corev1.VolumeMount{Name: dir.diskName, MountPath: dir.mountPath})
+	}
+	return append(mounts, corev1.VolumeMount{Name: IndexDiskName, MountPath: indexMountPath})
+}
+
+// HasLegacyBookieVolumeMounts returns true if the bookies of the StatefulSet
+// mount the "ledger" claim at the journal directory
+func HasLegacyBookieVolumeMounts(sts *appsv1.StatefulSet) bool {
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name != "bookie" {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == LedgerDiskName && mount.MountPath == journalMountPath {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func makeBookieVolumeClaimTemplates(spec *v1alpha1.BookkeeperSpec) []corev1.PersistentVolumeClaim {
-	return []corev1.PersistentVolumeClaim{
-		{
+	var templates []corev1.PersistentVolumeClaim
+
+	journals := spec.Storage.JournalTemplates()
+	for i, dir := range journalDirectories(spec.Storage) {
+		templates = append(templates, corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: JournalDiskName,
+				Name: dir.diskName,
 			},
-			Spec: *spec.Storage.JournalVolumeClaimTemplate,
-		},
-		{
+			Spec: journals[i],
+		})
+	}
+
+	ledgers := spec.Storage.LedgerTemplates()
+	for i, dir := range ledgerDirectories(spec.Storage) {
+		templates = append(templates, corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: LedgerDiskName,
+				Name: dir.diskName,
 			},
-			Spec: *spec.Storage.LedgerVolumeClaimTemplate,
+			Spec: ledgers[i],
+		})
+	}
+
+	return append(templates, corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: IndexDiskName,
 		},
-		{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: IndexDiskName,
+		Spec: *spec.Storage.IndexVolumeClaimTemplate,
+	})
+}
+
+// makeEphemeralVolumes returns an emptyDir volume in place of each claim
+// template, limited to the storage requested by the template
+func makeEphemeralVolumes(claims []corev1.PersistentVolumeClaim) []corev1.Volume {
+	volumes := make([]corev1.Volume, len(claims))
+	for i, claim := range claims {
+		volumes[i] = corev1.Volume{
+			Name: claim.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
 			},
-			Spec: *spec.Storage.IndexVolumeClaimTemplate,
-		},
+		}
+		if size, ok := claim.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			volumes[i].EmptyDir.SizeLimit = &size
+		}
 	}
+	return volumes
 }
 
 func MakeBookieConfigMap(ecsCluster *v1alpha1.ECSCluster) *corev1.ConfigMap {
-	memoryOpts := []string{
-		"-Xms1g",
-		"-XX:+UnlockExperimentalVMOptions",
-		"-XX:+UseCGroupMemoryLimitForHeap",
-		"-XX:MaxRAMFraction=2",
-		"-XX:MaxDirectMemorySize=1g",
-		"-XX:+ExitOnOutOfMemoryError",
-		"-XX:+CrashOnOutOfMemoryError",
-		"-XX:+HeapDumpOnOutOfMemoryError",
-	}
+	bookkeeperSpec := ecsCluster.Spec.Bookkeeper
 
-	gcOpts := []string{
-		"-XX:+UseG1GC",
-		"-XX:MaxGCPauseMillis=10",
-		"-XX:+ParallelRefProcEnabled",
-		"-XX:+AggressiveOpts",
-		"-XX:+DoEscapeAnalysis",
-		"-XX:ParallelGCThreads=32",
-		"-XX:ConcGCThreads=32",
-		"-XX:G1NewSizePercent=50",
-		"-XX:+DisableExplicitGC",
-		"-XX:-ResizePLAB",
-	}
+	// BOOKIE_GC_OPTS follows BOOKIE_MEM_OPTS on the bookie command line, so
+	// every option goes there for the override to take precedence. The
+	// variables are never empty, since the image then uses its own defaults
+	jvmOpts := makeJVMOpts(bookkeeperSpec.Resources, bookieJVMProfile, bookkeeperSpec.JVMOptions)
 
 	gcLoggingOpts := []string{
 		"-XX:+PrintGCDetails",
@@ -218,8 +285,8 @@ func MakeBookieConfigMap(ecsCluster *v1alpha1.ECSCluster) *corev1.ConfigMap {
 	}
 
 	configData := map[string]string{
-		"BOOKIE_MEM_OPTS":        strings.Join(memoryOpts, " "),
-		"BOOKIE_GC_OPTS":         strings.Join(gcOpts, " "),
+		"BOOKIE_MEM_OPTS":        strings.Join(oomOpts, " "),
+		"BOOKIE_GC_OPTS":         strings.Join(jvmOpts, " "),
 		"BOOKIE_GC_LOGGING_OPTS": strings.Join(gcLoggingOpts, " "),
 		"ZK_URL":                 ecsCluster.Spec.ZookeeperUri,
 		// Set useHostNameAsBookieID to false until BookKeeper Docker
@@ -227,12 +294,16 @@ func MakeBookieConfigMap(ecsCluster *v1alpha1.ECSCluster) *corev1.ConfigMap {
 		// This value can be explicitly overridden when using the operator
 		// with images based on BookKeeper 4.7 or newer
 		"BK_useHostNameAsBookieID": "false",
-		"ECS_CLUSTER_NAME":     ecsCluster.ObjectMeta.Name,
+		"ECS_CLUSTER_NAME":         ecsCluster.ObjectMeta.Name,
 		"WAIT_FOR":                 ecsCluster.Spec.ZookeeperUri,
+		"BK_journalDirectories":    joinMountPaths(journalDirectories(bookkeeperSpec.Storage)),
+		"BK_ledgerDirectories":     joinMountPaths(ledgerDirectories(bookkeeperSpec.Storage)),
+		"BK_indexDirectories":      indexMountPath,
 	}
 
-	if *ecsCluster.Spec.Bookkeeper.AutoRecovery {
-		configData["BK_AUTORECOVERY"] = "true"
+	// Standalone AutoRecovery runs in its own Deployment instead
+	if *ecsCluster.Spec.Bookkeeper.AutoRecovery && !ecsCluster.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		configData[BookieAutoRecoveryKey] = "true"
 	}
 
 	for k, v := range ecsCluster.Spec.Bookkeeper.Options {
@@ -267,7 +338,7 @@ func MakeBookiePodDisruptionBudget(ecsCluster *v1alpha1.ECSCluster) *policyv1bet
 		Spec: policyv1beta1.PodDisruptionBudgetSpec{
 			MaxUnavailable: &maxUnavailable,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: util.LabelsForBookie(ecsCluster),
+				MatchLabels: util.ServiceLabelsForBookie(ecsCluster),
 			},
 		},
 	}
@@ -0,0 +1,228 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func claimSpec(size string) corev1.PersistentVolumeClaimSpec {
+	return corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func claimNames(claims []corev1.PersistentVolumeClaim) []string {
+	names := make([]string, len(claims))
+	for i, claim := range claims {
+		names[i] = claim.Name
+	}
+	return names
+}
+
+var _ = Describe("Bookie storage", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+	})
+
+	Context("Single directories", func() {
+		It("should create a claim per template", func() {
+			journal := claimSpec("5Gi")
+			p.Spec.Bookkeeper.Storage.JournalVolumeClaimTemplate = &journal
+			claims := MakeBookieStatefulSet(p).Spec.VolumeClaimTemplates
+			Ω(claimNames(claims)).Should(Equal([]string{JournalDiskName, LedgerDiskName, IndexDiskName}))
+			Ω(claims[0].Spec).Should(Equal(claimSpec("5Gi")))
+			Ω(claims[1].Spec).Should(Equal(*p.Spec.Bookkeeper.Storage.LedgerVolumeClaimTemplate))
+		})
+
+		It("should mount each claim at its own directory", func() {
+			Ω(makeBookieVolumeMounts(p.Spec.Bookkeeper.Storage)).Should(Equal([]corev1.VolumeMount{
+				{Name: JournalDiskName, MountPath: journalMountPath},
+				{Name: LedgerDiskName, MountPath: ledgerMountPath},
+				{Name: IndexDiskName, MountPath: indexMountPath},
+			}))
+		})
+
+		It("should configure the bookie directories", func() {
+			data := MakeBookieConfigMap(p).Data
+			Ω(data["BK_journalDirectories"]).Should(Equal(journalMountPath))
+			Ω(data["BK_ledgerDirectories"]).Should(Equal(ledgerMountPath))
+			Ω(data["BK_indexDirectories"]).Should(Equal(indexMountPath))
+		})
+	})
+
+	Context("Striped directories", func() {
+		BeforeEach(func() {
+			p.Spec.Bookkeeper.Storage.JournalVolumeClaimTemplates = []corev1.PersistentVolumeClaimSpec{claimSpec("5Gi"), claimSpec("6Gi")}
+			p.Spec.Bookkeeper.Storage.LedgerVolumeClaimTemplates = []corev1.PersistentVolumeClaimSpec{claimSpec("50Gi"), claimSpec("60Gi"), claimSpec("70Gi")}
+		})
+
+		It("should create a claim per directory", func() {
+			claims := MakeBookieStatefulSet(p).Spec.VolumeClaimTemplates
+			Ω(claimNames(claims)).Should(Equal([]string{"journal", "journal-1", "ledger", "ledger-1", "ledger-2", "index"}))
+			Ω(claims[1].Spec).Should(Equal(claimSpec("6Gi")))
+			Ω(claims[4].Spec).Should(Equal(claimSpec("70Gi")))
+		})
+
+		It("should mount every directory", func() {
+			Ω(makeBookieVolumeMounts(p.Spec.Bookkeeper.Storage)).Should(Equal([]corev1.VolumeMount{
+				{Name: "journal", MountPath: "/bk/journal"},
+				{Name: "journal-1", MountPath: "/bk/journal-1"},
+				{Name: "ledger", MountPath: "/bk/ledgers"},
+				{Name: "ledger-1", MountPath: "/bk/ledgers-1"},
+				{Name: "ledger-2", MountPath: "/bk/ledgers-2"},
+				{Name: "index", MountPath: "/bk/index"},
+			}))
+		})
+
+		It("should list every directory", func() {
+			data := MakeBookieConfigMap(p).Data
+			Ω(data["BK_journalDirectories"]).Should(Equal("/bk/journal,/bk/journal-1"))
+			Ω(data["BK_ledgerDirectories"]).Should(Equal("/bk/ledgers,/bk/ledgers-1,/bk/ledgers-2"))
+			Ω(data["BK_indexDirectories"]).Should(Equal("/bk/index"))
+		})
+	})
+
+	Context("Legacy volume mounts", func() {
+		BeforeEach(func() {
+			p.Spec.Bookkeeper.Storage.LegacyVolumeMounts = true
+		})
+
+		It("should keep the mounts of earlier versions", func() {
+			Ω(makeBookieVolumeMounts(p.Spec.Bookkeeper.Storage)).Should(Equal([]corev1.VolumeMount{
+				{Name: LedgerDiskName, MountPath: journalMountPath},
+				{Name: JournalDiskName, MountPath: ledgerMountPath},
+				{Name: IndexDiskName, MountPath: indexMountPath},
+			}))
+		})
+
+		It("should only swap the first directories", func() {
+			p.Spec.Bookkeeper.Storage.LedgerVolumeClaimTemplates = []corev1.PersistentVolumeClaimSpec{claimSpec("50Gi"), claimSpec("60Gi")}
+			mounts := makeBookieVolumeMounts(p.Spec.Bookkeeper.Storage)
+			Ω(mounts).Should(ContainElement(corev1.VolumeMount{Name: "ledger-1", MountPath: "/bk/ledgers-1"}))
+			Ω(mounts).Should(ContainElement(corev1.VolumeMount{Name: JournalDiskName, MountPath: ledgerMountPath}))
+		})
+
+		It("should keep the claim templates", func() {
+			claims := MakeBookieStatefulSet(p).Spec.VolumeClaimTemplates
+			Ω(claimNames(claims)).Should(Equal([]string{JournalDiskName, LedgerDiskName, IndexDiskName}))
+			Ω(claims[1].Spec).Should(Equal(*p.Spec.Bookkeeper.Storage.LedgerVolumeClaimTemplate))
+		})
+
+		It("should be detected on a StatefulSet", func() {
+			Ω(HasLegacyBookieVolumeMounts(MakeBookieStatefulSet(p))).Should(BeTrue())
+			p.Spec.Bookkeeper.Storage.LegacyVolumeMounts = false
+			Ω(HasLegacyBookieVolumeMounts(MakeBookieStatefulSet(p))).Should(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("Ephemeral storage", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+	})
+
+	Context("EmptyDir volumes", func() {
+		It("should limit each volume to the storage of its claim", func() {
+			volumes := makeEphemeralVolumes([]corev1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "journal"}, Spec: claimSpec("5Gi")},
+				{ObjectMeta: metav1.ObjectMeta{Name: "ledger"}, Spec: claimSpec("50Gi")},
+			})
+			Ω(volumes).Should(HaveLen(2))
+			Ω(volumes[0].Name).Should(Equal("journal"))
+			Ω(volumes[0].EmptyDir).ShouldNot(BeNil())
+			Ω(volumes[0].EmptyDir.SizeLimit.String()).Should(Equal("5Gi"))
+			Ω(volumes[1].EmptyDir.SizeLimit.String()).Should(Equal("50Gi"))
+		})
+
+		It("should not limit a volume without a storage request", func() {
+			volumes := makeEphemeralVolumes([]corev1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "cache"}},
+			})
+			Ω(volumes[0].EmptyDir.SizeLimit).Should(BeNil())
+		})
+	})
+
+	Context("Bookies", func() {
+		It("should use emptyDir volumes instead of claims", func() {
+			p.Spec.Bookkeeper.Storage.Ephemeral = true
+			sts := MakeBookieStatefulSet(p)
+			Ω(sts.Spec.VolumeClaimTemplates).Should(BeEmpty())
+
+			var names []string
+			for _, volume := range sts.Spec.Template.Spec.Volumes {
+				if volume.EmptyDir != nil {
+					names = append(names, volume.Name)
+				}
+			}
+			Ω(names).Should(ContainElement(JournalDiskName))
+			Ω(names).Should(ContainElement(LedgerDiskName))
+			Ω(names).Should(ContainElement(IndexDiskName))
+		})
+
+		It("should use claims by default", func() {
+			sts := MakeBookieStatefulSet(p)
+			Ω(sts.Spec.VolumeClaimTemplates).Should(HaveLen(3))
+			for _, volume := range sts.Spec.Template.Spec.Volumes {
+				Ω(volume.Name).ShouldNot(Equal(JournalDiskName))
+			}
+		})
+	})
+
+	Context("Nodes", func() {
+		It("should use an emptyDir cache instead of a claim", func() {
+			p.Spec.ECS.EphemeralCache = true
+			sts := MakeNodeStatefulSet(p)
+			Ω(sts.Spec.VolumeClaimTemplates).Should(BeEmpty())
+
+			var cache *corev1.Volume
+			for i, volume := range sts.Spec.Template.Spec.Volumes {
+				if volume.Name == cacheVolumeName {
+					cache = &sts.Spec.Template.Spec.Volumes[i]
+				}
+			}
+			Ω(cache).ShouldNot(BeNil())
+			Ω(cache.EmptyDir).ShouldNot(BeNil())
+		})
+
+		It("should use a claim by default", func() {
+			sts := MakeNodeStatefulSet(p)
+			Ω(sts.Spec.VolumeClaimTemplates).Should(HaveLen(1))
+			Ω(sts.Spec.VolumeClaimTemplates[0].Name).Should(Equal(cacheVolumeName))
+		})
+	})
+})
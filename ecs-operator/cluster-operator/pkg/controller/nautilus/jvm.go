@@ -0,0 +1,223 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	mebibyte = 1024 * 1024
+
+	maxHeapOption         = "-Xmx"
+	maxDirectMemoryOption = "-XX:MaxDirectMemorySize="
+)
+
+// jvmProfile is the share of the container memory given to the heap and to
+// direct memory, in percent, and the GC options of a component. The rest of
+// the memory is left for metaspace, thread stacks and the page cache
+type jvmProfile struct {
+	heapPercent   int64
+	directPercent int64
+	gcOpts        []string
+}
+
+// BookKeeper runs with G1 tuned for short pauses
+var bookieGCOpts = []string{
+	"-XX:+UseG1GC",
+	"-XX:MaxGCPauseMillis=10",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:+DoEscapeAnalysis",
+	"-XX:G1NewSizePercent=50",
+	"-XX:+DisableExplicitGC",
+	"-XX:-ResizePLAB",
+}
+
+var (
+	// Bookies and nodes buffer entries in direct memory
+	bookieJVMProfile     = jvmProfile{heapPercent: 25, directPercent: 50, gcOpts: bookieGCOpts}
+	nodeJVMProfile       = jvmProfile{heapPercent: 25, directPercent: 50}
+	controllerJVMProfile = jvmProfile{heapPercent: 50}
+
+	// AutoRecovery only reads and rewrites ledger entries in flight
+	autoRecoveryJVMProfile = jvmProfile{heapPercent: 50, gcOpts: bookieGCOpts}
+)
+
+var oomOpts = []string{
+	"-XX:+ExitOnOutOfMemoryError",
+	"-XX:+CrashOnOutOfMemoryError",
+	"-XX:+HeapDumpOnOutOfMemoryError",
+}
+
+// memoryLimit returns the memory limit of the container in bytes, falling
+// back to the memory request. It returns 0 if neither is set
+func memoryLimit(resources *corev1.ResourceRequirements) int64 {
+	if resources == nil {
+		return 0
+	}
+	if limit, ok := resources.Limits[corev1.ResourceMemory]; ok {
+		return limit.Value()
+	}
+	if request, ok := resources.Requests[corev1.ResourceMemory]; ok {
+		return request.Value()
+	}
+	return 0
+}
+
+// cpuLimit returns the number of CPUs available to the container, rounded
+// up, falling back to the CPU request. It returns 0 if neither is set
+func cpuLimit(resources *corev1.ResourceRequirements) int64 {
+	if resources == nil {
+		return 0
+	}
+	if limit, ok := resources.Limits[corev1.ResourceCPU]; ok {
+		return (limit.MilliValue() + 999) / 1000
+	}
+	if request, ok := resources.Requests[corev1.ResourceCPU]; ok {
+		return (request.MilliValue() + 999) / 1000
+	}
+	return 0
+}
+
+// makeMemoryOpts returns the heap and direct memory options sized from the
+// memory limit. Without a limit, the JVM defaults are used
+func makeMemoryOpts(resources *corev1.ResourceRequirements, profile jvmProfile) []string {
+	limit := memoryLimit(resources)
+	if limit == 0 {
+		return nil
+	}
+
+	heap := limit * profile.heapPercent / 100 / mebibyte
+	opts := []string{
+		fmt.Sprintf("-Xms%dm", heap),
+		fmt.Sprintf("%s%dm", maxHeapOption, heap),
+	}
+
+	if profile.directPercent > 0 {
+		direct := limit * profile.directPercent / 100 / mebibyte
+		opts = append(opts, fmt.Sprintf("%s%dm", maxDirectMemoryOption, direct))
+	}
+	return opts
+}
+
+// makeGCThreadOpts returns the GC thread counts sized from the CPU limit.
+// Without a limit, the JVM defaults are used
+func makeGCThreadOpts(resources *corev1.ResourceRequirements) []string {
+	cpus := cpuLimit(resources)
+	if cpus == 0 {
+		return nil
+	}
+
+	concurrent := (cpus + 3) / 4
+	return []string{
+		fmt.Sprintf("-XX:ParallelGCThreads=%d", cpus),
+		fmt.Sprintf("-XX:ConcGCThreads=%d", concurrent),
+	}
+}
+
+// makeJVMOpts returns the JVM options of a component: the options generated
+// from its resources and profile, combined with the override. The OOM options
+// are kept in both modes, so that the JVM never survives an OutOfMemoryError
+func makeJVMOpts(resources *corev1.ResourceRequirements, profile jvmProfile, override *api.JVMOptions) []string {
+	if override != nil && override.Mode == api.JVMOptionsReplace {
+		return append(append([]string{}, oomOpts...), override.Options...)
+	}
+
+	opts := makeMemoryOpts(resources, profile)
+	opts = append(opts, profile.gcOpts...)
+	opts = append(opts, makeGCThreadOpts(resources)...)
+	opts = append(opts, oomOpts...)
+	if override != nil {
+		opts = append(opts, override.Options...)
+	}
+	return opts
+}
+
+// parseJVMSize parses a JVM memory size such as "512m" or "2g" into bytes
+func parseJVMSize(size string) (int64, bool) {
+	if size == "" {
+		return 0, false
+	}
+
+	multiplier := int64(1)
+	switch size[len(size)-1] {
+	case 'k', 'K':
+		multiplier = 1024
+	case 'm', 'M':
+		multiplier = mebibyte
+	case 'g', 'G':
+		multiplier = 1024 * mebibyte
+	case 't', 'T':
+		multiplier = 1024 * 1024 * mebibyte
+	}
+	if multiplier != 1 {
+		size = size[:len(size)-1]
+	}
+
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value * multiplier, true
+}
+
+// jvmOptionSize returns the size set by the last occurrence of the option
+func jvmOptionSize(opts []string, prefix string) int64 {
+	var size int64
+	for _, opt := range opts {
+		if strings.HasPrefix(opt, prefix) {
+			if value, ok := parseJVMSize(strings.TrimPrefix(opt, prefix)); ok {
+				size = value
+			}
+		}
+	}
+	return size
+}
+
+// checkJVMMemory returns an error if the maximum heap plus direct memory set
+// in the options exceed the memory limit of the container
+func checkJVMMemory(component string, opts []string, resources *corev1.ResourceRequirements) error {
+	limit := memoryLimit(resources)
+	if limit == 0 {
+		return nil
+	}
+
+	heap := jvmOptionSize(opts, maxHeapOption)
+	direct := jvmOptionSize(opts, maxDirectMemoryOption)
+	if heap+direct > limit {
+		return fmt.Errorf("%s heap (%dMi) plus direct memory (%dMi) exceed the memory limit (%dMi)",
+			component, heap/mebibyte, direct/mebibyte, limit/mebibyte)
+	}
+	return nil
+}
+
+// ValidateJVMOptions checks the JVM options of every component against its
+// memory limit, returning one warning per component that may be OOM killed
+func ValidateJVMOptions(p *api.ECSCluster) (warnings []string) {
+	bk := p.Spec.Bookkeeper
+	checks := []error{
+		checkJVMMemory("bookie", makeJVMOpts(bk.Resources, bookieJVMProfile, bk.JVMOptions), bk.Resources),
+		checkJVMMemory("controller", makeJVMOpts(p.Spec.ECS.ControllerResources, controllerJVMProfile, p.Spec.ECS.ControllerJVMOptions), p.Spec.ECS.ControllerResources),
+		checkJVMMemory("node", makeJVMOpts(p.Spec.ECS.NodeResources, nodeJVMProfile, p.Spec.ECS.NodeJVMOptions), p.Spec.ECS.NodeResources),
+	}
+
+	for _, err := range checks {
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,172 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"strings"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func resourcesWithLimits(cpu, memory string) *corev1.ResourceRequirements {
+	return &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+var _ = Describe("JVM options", func() {
+	Context("Size parsing", func() {
+		It("should parse the units", func() {
+			for size, bytes := range map[string]int64{
+				"512":  512,
+				"64k":  64 * 1024,
+				"512m": 512 * mebibyte,
+				"2G":   2048 * mebibyte,
+				"1t":   1024 * 1024 * mebibyte,
+			} {
+				value, ok := parseJVMSize(size)
+				Ω(ok).Should(BeTrue(), size)
+				Ω(value).Should(Equal(bytes), size)
+			}
+		})
+
+		It("should reject invalid sizes", func() {
+			for _, size := range []string{"", "m", "2x", "1.5g"} {
+				_, ok := parseJVMSize(size)
+				Ω(ok).Should(BeFalse(), size)
+			}
+		})
+	})
+
+	Context("Memory options", func() {
+		It("should size the heap and direct memory from the limit", func() {
+			opts := makeMemoryOpts(resourcesWithLimits("2", "4Gi"), bookieJVMProfile)
+			Ω(opts).Should(Equal([]string{"-Xms1024m", "-Xmx1024m", "-XX:MaxDirectMemorySize=2048m"}))
+		})
+
+		It("should not set direct memory without a share", func() {
+			opts := makeMemoryOpts(resourcesWithLimits("2", "4Gi"), controllerJVMProfile)
+			Ω(opts).Should(Equal([]string{"-Xms2048m", "-Xmx2048m"}))
+		})
+
+		It("should fall back to the request", func() {
+			resources := &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+			}
+			Ω(makeMemoryOpts(resources, controllerJVMProfile)).Should(ContainElement("-Xmx1024m"))
+		})
+
+		It("should use the JVM defaults without resources", func() {
+			Ω(makeMemoryOpts(nil, bookieJVMProfile)).Should(BeNil())
+			Ω(makeMemoryOpts(&corev1.ResourceRequirements{}, bookieJVMProfile)).Should(BeNil())
+		})
+	})
+
+	Context("Combined options", func() {
+		var resources *corev1.ResourceRequirements
+
+		BeforeEach(func() {
+			resources = resourcesWithLimits("2", "4Gi")
+		})
+
+		It("should generate the memory, GC and OOM options", func() {
+			opts := makeJVMOpts(resources, bookieJVMProfile, nil)
+			Ω(opts[:3]).Should(Equal([]string{"-Xms1024m", "-Xmx1024m", "-XX:MaxDirectMemorySize=2048m"}))
+			Ω(opts).Should(ContainElement("-XX:+UseG1GC"))
+			Ω(opts).Should(ContainElement("-XX:ParallelGCThreads=2"))
+			Ω(opts).Should(ContainElement("-XX:ConcGCThreads=1"))
+			Ω(opts).ShouldNot(ContainElement("-XX:+AggressiveOpts"))
+			Ω(opts[len(opts)-len(oomOpts):]).Should(Equal(oomOpts))
+		})
+
+		It("should append the override last", func() {
+			override := &api.JVMOptions{Mode: api.JVMOptionsAppend, Options: []string{"-Xmx512m"}}
+			opts := makeJVMOpts(resources, nodeJVMProfile, override)
+			Ω(opts[len(opts)-1]).Should(Equal("-Xmx512m"))
+			Ω(opts).Should(ContainElement("-Xmx1024m"))
+		})
+
+		It("should keep the OOM options when replacing", func() {
+			override := &api.JVMOptions{Mode: api.JVMOptionsReplace, Options: []string{"-Xmx512m"}}
+			for _, profile := range []jvmProfile{bookieJVMProfile, controllerJVMProfile, nodeJVMProfile} {
+				Ω(makeJVMOpts(resources, profile, override)).Should(Equal(append(append([]string{}, oomOpts...), "-Xmx512m")))
+			}
+		})
+	})
+
+	Context("Memory check", func() {
+		var resources *corev1.ResourceRequirements
+
+		BeforeEach(func() {
+			resources = resourcesWithLimits("2", "4Gi")
+		})
+
+		It("should accept the generated options", func() {
+			Ω(checkJVMMemory("bookie", makeJVMOpts(resources, bookieJVMProfile, nil), resources)).Should(Succeed())
+		})
+
+		It("should use the last occurrence of an option", func() {
+			opts := []string{"-Xmx1g", "-XX:MaxDirectMemorySize=1g", "-Xmx4g"}
+			err := checkJVMMemory("node", opts, resources)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("node heap (4096Mi) plus direct memory (1024Mi)"))
+		})
+
+		It("should accept any options without a limit", func() {
+			Ω(checkJVMMemory("node", []string{"-Xmx64g"}, nil)).Should(Succeed())
+		})
+
+		It("should warn for the components exceeding their limit", func() {
+			p := &api.ECSCluster{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+			p.WithDefaults()
+			p.Spec.Bookkeeper.Resources = resources
+			p.Spec.Bookkeeper.JVMOptions = &api.JVMOptions{Mode: api.JVMOptionsAppend, Options: []string{"-Xmx8g"}}
+			warnings := ValidateJVMOptions(p)
+			Ω(warnings).Should(HaveLen(1))
+			Ω(warnings[0]).Should(HavePrefix("bookie"))
+		})
+	})
+
+	Context("Bookie ConfigMap", func() {
+		var p *api.ECSCluster
+
+		BeforeEach(func() {
+			p = &api.ECSCluster{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+			p.WithDefaults()
+			p.Spec.Bookkeeper.Resources = resourcesWithLimits("2", "4Gi")
+		})
+
+		It("should put every option in BOOKIE_GC_OPTS", func() {
+			p.Spec.Bookkeeper.JVMOptions = &api.JVMOptions{Mode: api.JVMOptionsAppend, Options: []string{"-XX:MaxGCPauseMillis=20"}}
+			data := MakeBookieConfigMap(p).Data
+			Ω(data["BOOKIE_MEM_OPTS"]).Should(Equal(strings.Join(oomOpts, " ")))
+			Ω(data["BOOKIE_GC_OPTS"]).Should(HavePrefix("-Xms1024m -Xmx1024m"))
+			Ω(data["BOOKIE_GC_OPTS"]).Should(HaveSuffix("-XX:MaxGCPauseMillis=10 -XX:+ParallelRefProcEnabled -XX:+DoEscapeAnalysis -XX:G1NewSizePercent=50 -XX:+DisableExplicitGC -XX:-ResizePLAB -XX:ParallelGCThreads=2 -XX:ConcGCThreads=1 " +
+				strings.Join(oomOpts, " ") + " -XX:MaxGCPauseMillis=20"))
+		})
+
+		It("should keep the OOM options when replacing", func() {
+			p.Spec.Bookkeeper.JVMOptions = &api.JVMOptions{Mode: api.JVMOptionsReplace, Options: []string{"-Xmx2g"}}
+			data := MakeBookieConfigMap(p).Data
+			Ω(data["BOOKIE_GC_OPTS"]).Should(Equal(strings.Join(oomOpts, " ") + " -Xmx2g"))
+		})
+	})
+})
@@ -36,9 +36,11 @@ func MakeControllerDeployment(p *api.ECSCluster) *appsv1.Deployment {
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &p.Spec.ECS.ControllerReplicas,
+			Strategy: MakeControllerDeploymentStrategy(),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: util.LabelsForController(p),
+					Labels:      util.LabelsForController(p),
+					Annotations: makePodAnnotations(p.Spec.ECS.ControllerRestartedAt, p.Spec.ECS.ControllerSecurity),
 				},
 				Spec: *makeControllerPodSpec(p.Name, p.Spec.ECS),
 			},
@@ -49,7 +51,24 @@ func MakeControllerDeployment(p *api.ECSCluster) *appsv1.Deployment {
 	}
 }
 
+// MakeControllerDeploymentStrategy returns the rollout strategy of the
+// controllers. A new controller is started and ready before an old one is
+// stopped, so that restarts do not reduce the number of controllers
+func MakeControllerDeploymentStrategy() appsv1.DeploymentStrategy {
+	maxUnavailable := intstr.FromInt(0)
+	maxSurge := intstr.FromInt(1)
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+}
+
 func makeControllerPodSpec(name string, ecsSpec *api.ECSSpec) *corev1.PodSpec {
+	readinessProbe, livenessProbe := makeProbes(ecsSpec.ControllerProbes)
+
 	podSpec := &corev1.PodSpec{
 		Containers: []corev1.Container{
 			{
@@ -78,31 +97,9 @@ func makeControllerPodSpec(name string, ecsSpec *api.ECSSpec) *corev1.PodSpec {
 						},
 					},
 				},
-				Resources: *ecsSpec.ControllerResources,
-				ReadinessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: util.HealthcheckCommand(9090),
-						},
-					},
-					// Controller pods start fast. We give it up to 1 minute to become ready.
-					PeriodSeconds:    5,
-					FailureThreshold: 12,
-				},
-				LivenessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: util.HealthcheckCommand(9090),
-						},
-					},
-					// We start the liveness probe from the maximum time the pod can take
-					// before becoming ready.
-					// If the pod fails the health check during 1 minute, Kubernetes
-					// will restart it.
-					InitialDelaySeconds: 60,
-					PeriodSeconds:       15,
-					FailureThreshold:    4,
-				},
+				Resources:      *ecsSpec.ControllerResources,
+				ReadinessProbe: readinessProbe,
+				LivenessProbe:  livenessProbe,
 			},
 		},
 		Affinity: util.PodAntiAffinity("ecs-controller", name),
@@ -112,23 +109,17 @@ func makeControllerPodSpec(name string, ecsSpec *api.ECSSpec) *corev1.PodSpec {
 		podSpec.ServiceAccountName = ecsSpec.ControllerServiceAccountName
 	}
 
+	configureSecurity(podSpec, ecsSpec.ControllerSecurity, ecsConfMountPath, ecsLogsMountPath)
+
 	return podSpec
 }
 
 func MakeControllerConfigMap(p *api.ECSCluster) *corev1.ConfigMap {
-	var javaOpts = []string{
-		"-Xms512m",
-		"-XX:+UnlockExperimentalVMOptions",
-		"-XX:+UseCGroupMemoryLimitForHeap",
-		"-XX:MaxRAMFraction=2",
-		"-XX:+ExitOnOutOfMemoryError",
-		"-XX:+CrashOnOutOfMemoryError",
-		"-XX:+HeapDumpOnOutOfMemoryError",
-		"-Decsservice.clusterName=" + p.Name,
-	}
+	javaOpts := makeJVMOpts(p.Spec.ECS.ControllerResources, controllerJVMProfile, p.Spec.ECS.ControllerJVMOptions)
+	javaOpts = append(javaOpts, "-Decsservice.clusterName="+p.Name)
 
-	for name, value := range p.Spec.ECS.Options {
-		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, value))
+	for _, name := range util.SortedKeys(p.Spec.ECS.Options) {
+		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, p.Spec.ECS.Options[name]))
 	}
 
 	configData := map[string]string{
@@ -11,6 +11,8 @@
 package ecs
 
 import (
+	"sort"
+	"strconv"
 	"strings"
 
 	"fmt"
@@ -27,13 +29,22 @@ import (
 const (
 	cacheVolumeName       = "cache"
 	cacheVolumeMountPoint = "/tmp/ecs/cache"
-	tier2FileMountPoint   = "/mnt/tier2"
 	tier2VolumeName       = "tier2"
-	nodeKind      = "ecs-node"
+	tier2CAVolumeName     = "tier2-ca"
+	tier2CAMountPoint     = "/etc/ecs/tier2-ca"
+	hdfsConfVolumeName    = "hadoop-conf"
+	hdfsConfMountPoint    = "/etc/hadoop/conf"
+	keytabVolumeName      = "hdfs-keytab"
+	keytabMountPoint      = "/etc/ecs/hdfs-keytab"
+	krb5VolumeName        = "krb5-conf"
+	krb5MountPoint        = "/etc/ecs/krb5"
+	nodeKind              = "ecs-node"
+
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
 )
 
 func MakeNodeStatefulSet(ecsCluster *api.ECSCluster) *appsv1.StatefulSet {
-	return &appsv1.StatefulSet{
+	statefulSet := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
 			APIVersion: "apps/v1",
@@ -48,16 +59,22 @@ func MakeNodeStatefulSet(ecsCluster *api.ECSCluster) *appsv1.StatefulSet {
 			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: util.LabelsForNode(ecsCluster),
+					Labels:      util.ServiceLabelsForNode(ecsCluster),
+					Annotations: makePodAnnotations(ecsCluster.Spec.ECS.NodeRestartedAt, ecsCluster.Spec.ECS.NodeSecurity),
 				},
 				Spec: makeNodePodSpec(ecsCluster),
 			},
 			Selector: &metav1.LabelSelector{
 				MatchLabels: util.LabelsForNode(ecsCluster),
 			},
-			VolumeClaimTemplates: makeCacheVolumeClaimTemplate(ecsCluster.Spec.ECS),
 		},
 	}
+
+	// An ephemeral cache uses an emptyDir volume set in the pod spec instead
+	if !ecsCluster.Spec.ECS.EphemeralCache {
+		statefulSet.Spec.VolumeClaimTemplates = makeCacheVolumeClaimTemplate(ecsCluster.Spec.ECS)
+	}
+	return statefulSet
 }
 
 func makeNodePodSpec(ecsCluster *api.ECSCluster) corev1.PodSpec {
@@ -75,6 +92,8 @@ func makeNodePodSpec(ecsCluster *api.ECSCluster) corev1.PodSpec {
 
 	environment = configureTier2Secrets(environment, ecsSpec)
 
+	readinessProbe, livenessProbe := makeProbes(ecsSpec.NodeProbes)
+
 	podSpec := corev1.PodSpec{
 		Containers: []corev1.Container{
 			{
@@ -98,35 +117,9 @@ func makeNodePodSpec(ecsCluster *api.ECSCluster) corev1.PodSpec {
 						MountPath: cacheVolumeMountPoint,
 					},
 				},
-				Resources: *ecsSpec.NodeResources,
-				ReadinessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: util.HealthcheckCommand(12345),
-						},
-					},
-					// Segment Stores can take a few minutes to become ready when the cluster
-					// is configured with external enabled as they need to wait for the allocation
-					// of the external IP address.
-					// This config gives it up to 5 minutes to become ready.
-					PeriodSeconds:    10,
-					FailureThreshold: 30,
-				},
-				LivenessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: util.HealthcheckCommand(12345),
-						},
-					},
-					// In the readiness probe we allow the pod to take up to 5 minutes
-					// to become ready. Therefore, the liveness probe will give it
-					// a 5-minute grace period before starting monitoring the container.
-					// If the pod fails the health check during 1 minute, Kubernetes
-					// will restart it.
-					InitialDelaySeconds: 300,
-					PeriodSeconds:       15,
-					FailureThreshold:    4,
-				},
+				Resources:      *ecsSpec.NodeResources,
+				ReadinessProbe: readinessProbe,
+				LivenessProbe:  livenessProbe,
 			},
 		},
 		Affinity: util.PodAntiAffinity("ecs-node", ecsCluster.Name),
@@ -136,25 +129,29 @@ func makeNodePodSpec(ecsCluster *api.ECSCluster) corev1.PodSpec {
 		podSpec.ServiceAccountName = ecsSpec.NodeServiceAccountName
 	}
 
+	if ecsSpec.EphemeralCache {
+		podSpec.Volumes = append(podSpec.Volumes, makeEphemeralVolumes(makeCacheVolumeClaimTemplate(ecsSpec))...)
+	}
+
 	configureTier2Filesystem(&podSpec, ecsSpec)
 
+	configureTier2CABundle(&podSpec, ecsSpec)
+
+	configureTier2Hdfs(&podSpec, ecsSpec)
+
+	configureSecurity(&podSpec, ecsSpec.NodeSecurity, ecsConfMountPath, ecsLogsMountPath)
+
 	return podSpec
 }
 
 func MakeNodeConfigMap(p *api.ECSCluster) *corev1.ConfigMap {
-	javaOpts := []string{
-		"-Xms1g",
-		"-XX:+UnlockExperimentalVMOptions",
-		"-XX:+UseCGroupMemoryLimitForHeap",
-		"-XX:MaxRAMFraction=2",
-		"-XX:+ExitOnOutOfMemoryError",
-		"-XX:+CrashOnOutOfMemoryError",
-		"-XX:+HeapDumpOnOutOfMemoryError",
-		"-Decsservice.clusterName=" + p.Name,
-	}
+	javaOpts := makeJVMOpts(p.Spec.ECS.NodeResources, nodeJVMProfile, p.Spec.ECS.NodeJVMOptions)
+	javaOpts = append(javaOpts, "-Decsservice.clusterName="+p.Name)
+
+	javaOpts = append(javaOpts, getTier2JavaOpts(p.Spec.ECS)...)
 
-	for name, value := range p.Spec.ECS.Options {
-		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, value))
+	for _, name := range util.SortedKeys(p.Spec.ECS.Options) {
+		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, p.Spec.ECS.Options[name]))
 	}
 
 	configData := map[string]string{
@@ -179,6 +176,11 @@ func MakeNodeConfigMap(p *api.ECSCluster) *corev1.ConfigMap {
 
 	if p.Spec.ExternalAccess.Enabled {
 		configData["K8_EXTERNAL_ACCESS"] = "true"
+
+		// Every node looks up its own advertised endpoint using its ordinal
+		for k, v := range getExternalEndpointOptions(p) {
+			configData[k] = v
+		}
 	}
 
 	if p.Spec.ECS.DebugLogging {
@@ -214,11 +216,32 @@ func makeCacheVolumeClaimTemplate(ecsSpec *api.ECSSpec) []corev1.PersistentVolum
 	}
 }
 
+// getExternalEndpointOptions returns the external host and port that each node
+// ordinal advertises to clients, when they are known in advance. The ConfigMap
+// is shared by the nodes, so the entrypoint of the ECS image reads the
+// K8_EXTERNAL_HOST_<ordinal> and K8_EXTERNAL_PORT_<ordinal> keys of the ordinal
+// in its pod name. Nodes without an entry fall back to discovering the address
+// of their external service
+func getExternalEndpointOptions(p *api.ECSCluster) map[string]string {
+	options := make(map[string]string)
+	for i := int32(0); i < p.Spec.ECS.NodeReplicas; i++ {
+		if hostname := p.Spec.ExternalAccess.HostnameForNode(p.Name, i); hostname != "" {
+			options[fmt.Sprintf("K8_EXTERNAL_HOST_%d", i)] = hostname
+		}
+		if nodePort := p.Spec.ExternalAccess.NodePortForNode(i); nodePort != 0 {
+			options[fmt.Sprintf("K8_EXTERNAL_PORT_%d", i)] = fmt.Sprint(nodePort)
+		} else if p.Spec.ExternalAccess.Type == corev1.ServiceTypeLoadBalancer {
+			options[fmt.Sprintf("K8_EXTERNAL_PORT_%d", i)] = "12345"
+		}
+	}
+	return options
+}
+
 func getTier2StorageOptions(ecsSpec *api.ECSSpec) map[string]string {
 	if ecsSpec.Tier2.FileSystem != nil {
 		return map[string]string{
 			"TIER2_STORAGE": "FILESYSTEM",
-			"NFS_MOUNT":     tier2FileMountPoint,
+			"NFS_MOUNT":     ecsSpec.Tier2.FileSystem.MountPath,
 		}
 	}
 
@@ -234,16 +257,58 @@ func getTier2StorageOptions(ecsSpec *api.ECSSpec) map[string]string {
 	}
 
 	if ecsSpec.Tier2.Hdfs != nil {
-		return map[string]string{
-			"TIER2_STORAGE": "HDFS",
-			"HDFS_URL":      ecsSpec.Tier2.Hdfs.Uri,
-			"HDFS_ROOT":     ecsSpec.Tier2.Hdfs.Root,
+		hdfs := ecsSpec.Tier2.Hdfs
+		options := map[string]string{
+			"TIER2_STORAGE":    "HDFS",
+			"HDFS_URL":         hdfs.Uri,
+			"HDFS_ROOT":        hdfs.Root,
+			"HDFS_REPLICATION": fmt.Sprint(hdfs.ReplicationFactor),
+		}
+		if hdfs.ConfigMap != "" {
+			options["HADOOP_CONF_DIR"] = hdfsConfMountPoint
+		}
+		if hdfs.Kerberos != nil {
+			options["HDFS_KERBEROS_PRINCIPAL"] = hdfs.Kerberos.Principal
+			options["HDFS_KERBEROS_KEYTAB"] = fmt.Sprintf("%s/%s", keytabMountPoint, hdfs.Kerberos.KeytabKey)
+		}
+		return options
+	}
+
+	if ecsSpec.Tier2.S3 != nil {
+		// S3_ACCESS_KEY_ID & S3_SECRET_KEY will come from secret storage
+		options := map[string]string{
+			"TIER2_STORAGE":        "S3",
+			"S3_BUCKET":            ecsSpec.Tier2.S3.Bucket,
+			"S3_PREFIX":            ecsSpec.Tier2.S3.Prefix,
+			"S3_REGION":            ecsSpec.Tier2.S3.Region,
+			"S3_PATH_STYLE_ACCESS": strconv.FormatBool(ecsSpec.Tier2.S3.PathStyleAccess),
+		}
+		if ecsSpec.Tier2.S3.Endpoint != "" {
+			options["S3_ENDPOINT"] = ecsSpec.Tier2.S3.Endpoint
+		}
+		if ecsSpec.Tier2.S3.CABundleSecret != "" {
+			options["S3_CA_BUNDLE"] = fmt.Sprintf("%s/%s", tier2CAMountPoint, api.DefaultS3CABundleKey)
 		}
+		return options
 	}
 
 	return make(map[string]string)
 }
 
+// getTier2JavaOpts returns the JVM options required by the Tier 2 client
+func getTier2JavaOpts(ecsSpec *api.ECSSpec) []string {
+	if ecsSpec.Tier2.Hdfs != nil && ecsSpec.Tier2.Hdfs.Kerberos != nil {
+		return []string{
+			"-Djava.security.krb5.conf=" + krb5MountPoint + "/krb5.conf",
+			"-Dhadoop.security.authentication=kerberos",
+			"-Dhdfs.kerberos.principal=" + ecsSpec.Tier2.Hdfs.Kerberos.Principal,
+			"-Dhdfs.kerberos.keytab=" + fmt.Sprintf("%s/%s", keytabMountPoint, ecsSpec.Tier2.Hdfs.Kerberos.KeytabKey),
+		}
+	}
+
+	return nil
+}
+
 func configureTier2Secrets(environment []corev1.EnvFromSource, ecsSpec *api.ECSSpec) []corev1.EnvFromSource {
 	if ecsSpec.Tier2.ECS != nil {
 		return append(environment, corev1.EnvFromSource{
@@ -256,6 +321,17 @@ func configureTier2Secrets(environment []corev1.EnvFromSource, ecsSpec *api.ECSS
 		})
 	}
 
+	if ecsSpec.Tier2.S3 != nil {
+		return append(environment, corev1.EnvFromSource{
+			Prefix: "S3_",
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: ecsSpec.Tier2.S3.Credentials,
+				},
+			},
+		})
+	}
+
 	return environment
 }
 
@@ -264,18 +340,86 @@ func configureTier2Filesystem(podSpec *corev1.PodSpec, ecsSpec *api.ECSSpec) {
 	if ecsSpec.Tier2.FileSystem != nil {
 		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
 			Name:      tier2VolumeName,
-			MountPath: tier2FileMountPoint,
+			MountPath: ecsSpec.Tier2.FileSystem.MountPath,
+			SubPath:   ecsSpec.Tier2.FileSystem.SubPath,
+		})
+
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         tier2VolumeName,
+			VolumeSource: ecsSpec.Tier2.FileSystem.VolumeSource,
+		})
+	}
+}
+
+func configureTier2CABundle(podSpec *corev1.PodSpec, ecsSpec *api.ECSSpec) {
+
+	if ecsSpec.Tier2.S3 != nil && ecsSpec.Tier2.S3.CABundleSecret != "" {
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      tier2CAVolumeName,
+			MountPath: tier2CAMountPoint,
+			ReadOnly:  true,
+		})
+
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: tier2CAVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: ecsSpec.Tier2.S3.CABundleSecret,
+				},
+			},
+		})
+	}
+}
+
+func configureTier2Hdfs(podSpec *corev1.PodSpec, ecsSpec *api.ECSSpec) {
+	hdfs := ecsSpec.Tier2.Hdfs
+	if hdfs == nil {
+		return
+	}
+
+	if hdfs.ConfigMap != "" {
+		mountConfigMap(podSpec, hdfsConfVolumeName, hdfsConfMountPoint, hdfs.ConfigMap)
+	}
+
+	if hdfs.Kerberos != nil {
+		mountConfigMap(podSpec, krb5VolumeName, krb5MountPoint, hdfs.Kerberos.Krb5ConfigMap)
+
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      keytabVolumeName,
+			MountPath: keytabMountPoint,
+			ReadOnly:  true,
 		})
 
 		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
-			Name: tier2VolumeName,
+			Name: keytabVolumeName,
 			VolumeSource: corev1.VolumeSource{
-				PersistentVolumeClaim: ecsSpec.Tier2.FileSystem.PersistentVolumeClaim,
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: hdfs.Kerberos.KeytabSecret,
+				},
 			},
 		})
 	}
 }
 
+func mountConfigMap(podSpec *corev1.PodSpec, volumeName string, mountPath string, configMap string) {
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMap,
+				},
+			},
+		},
+	})
+}
+
 func MakeNodeHeadlessService(ecsCluster *api.ECSCluster) *corev1.Service {
 	return &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -295,6 +439,8 @@ func MakeNodeHeadlessService(ecsCluster *api.ECSCluster) *corev1.Service {
 					Protocol: "TCP",
 				},
 			},
+			// The governing Service selects cordoned nodes too, so that they
+			// keep their DNS names
 			Selector:  util.LabelsForNode(ecsCluster),
 			ClusterIP: corev1.ClusterIPNone,
 		},
@@ -302,39 +448,69 @@ func MakeNodeHeadlessService(ecsCluster *api.ECSCluster) *corev1.Service {
 }
 
 func MakeNodeExternalServices(ecsCluster *api.ECSCluster) []*corev1.Service {
-	var service *corev1.Service
 	services := make([]*corev1.Service, ecsCluster.Spec.ECS.NodeReplicas)
 
 	for i := int32(0); i < ecsCluster.Spec.ECS.NodeReplicas; i++ {
-		service = &corev1.Service{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "Service",
-				APIVersion: "v1",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      util.ServiceNameForNode(ecsCluster.Name, i),
-				Namespace: ecsCluster.Namespace,
-				Labels:    util.LabelsForNode(ecsCluster),
-			},
-			Spec: corev1.ServiceSpec{
-				Type: ecsCluster.Spec.ExternalAccess.Type,
-				Ports: []corev1.ServicePort{
-					{
-						Name:       "server",
-						Port:       12345,
-						Protocol:   "TCP",
-						TargetPort: intstr.FromInt(12345),
-					},
-				},
-				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
-				Selector: map[string]string{
-					appsv1.StatefulSetPodNameLabel: fmt.Sprintf("%s-%d", util.StatefulSetNameForNode(ecsCluster.Name), i),
+		services[i] = MakeNodeExternalService(ecsCluster, i)
+	}
+	return services
+}
+
+// MakeNodeExternalService returns the service that exposes the ECS node with
+// the given ordinal outside of Kubernetes
+func MakeNodeExternalService(ecsCluster *api.ECSCluster, ordinal int32) *corev1.Service {
+	externalAccess := ecsCluster.Spec.ExternalAccess
+
+	annotations := map[string]string{}
+	for k, v := range externalAccess.Annotations {
+		annotations[k] = v
+	}
+	if hostname := externalAccess.HostnameForNode(ecsCluster.Name, ordinal); hostname != "" {
+		annotations[externalDNSHostnameAnnotation] = hostname
+	}
+
+	managed := make([]string, 0, len(annotations))
+	for k := range annotations {
+		managed = append(managed, k)
+	}
+	sort.Strings(managed)
+	annotations[util.ManagedAnnotationsAnnotation] = strings.Join(managed, ",")
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        util.ServiceNameForNode(ecsCluster.Name, ordinal),
+			Namespace:   ecsCluster.Namespace,
+			Labels:      util.LabelsForNode(ecsCluster),
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: externalAccess.Type,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "server",
+					Port:       12345,
+					Protocol:   "TCP",
+					TargetPort: intstr.FromInt(12345),
+					NodePort:   externalAccess.NodePortForNode(ordinal),
 				},
 			},
-		}
-		services[i] = service
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			Selector: map[string]string{
+				appsv1.StatefulSetPodNameLabel: util.PodNameForNode(ecsCluster.Name, ordinal),
+				util.InServiceLabel:            "true",
+			},
+		},
 	}
-	return services
+
+	if externalAccess.Type == corev1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = externalAccess.LoadBalancerSourceRanges
+	}
+
+	return service
 }
 
 func MakeNodePodDisruptionBudget(ecsCluster *api.ECSCluster) *policyv1beta1.PodDisruptionBudget {
@@ -358,8 +534,26 @@ func MakeNodePodDisruptionBudget(ecsCluster *api.ECSCluster) *policyv1beta1.PodD
 		Spec: policyv1beta1.PodDisruptionBudgetSpec{
 			MaxUnavailable: &maxUnavailable,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: util.LabelsForNode(ecsCluster),
+				MatchLabels: util.ServiceLabelsForNode(ecsCluster),
 			},
 		},
 	}
 }
+
+// MakeTier2PersistentVolumeClaim returns the claim used as filesystem Tier 2,
+// built from the volume claim template
+func MakeTier2PersistentVolumeClaim(ecsCluster *api.ECSCluster) *corev1.PersistentVolumeClaim {
+	fs := ecsCluster.Spec.ECS.Tier2.FileSystem
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fs.PersistentVolumeClaim.ClaimName,
+			Namespace: ecsCluster.Namespace,
+			Labels:    util.LabelsForECSCluster(ecsCluster),
+		},
+		Spec: *fs.VolumeClaimTemplate,
+	}
+}
@@ -0,0 +1,316 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECS node", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.Spec.ExternalAccess = &api.ExternalAccess{Enabled: true}
+		p.WithDefaults()
+		p.Spec.ECS.NodeReplicas = 2
+	})
+
+	Context("External services", func() {
+		It("should add the configured annotations and list them as managed", func() {
+			p.Spec.ExternalAccess.Annotations = map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"}
+			p.Spec.ExternalAccess.HostnameTemplate = "{cluster}-node-{ordinal}.example.com"
+			service := MakeNodeExternalService(p, 1)
+			Ω(service.Annotations).Should(Equal(map[string]string{
+				"service.beta.kubernetes.io/aws-load-balancer-type": "nlb",
+				externalDNSHostnameAnnotation:                       "example-node-1.example.com",
+				util.ManagedAnnotationsAnnotation:                   externalDNSHostnameAnnotation + ",service.beta.kubernetes.io/aws-load-balancer-type",
+			}))
+		})
+
+		It("should assign the node ports from the base", func() {
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			p.Spec.ExternalAccess.NodePortBase = 30100
+			Ω(MakeNodeExternalService(p, 0).Spec.Ports[0].NodePort).Should(BeEquivalentTo(30100))
+			Ω(MakeNodeExternalService(p, 1).Spec.Ports[0].NodePort).Should(BeEquivalentTo(30101))
+		})
+
+		It("should leave the node ports to Kubernetes without a base", func() {
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			Ω(MakeNodeExternalService(p, 1).Spec.Ports[0].NodePort).Should(BeZero())
+		})
+
+		It("should only restrict the source ranges of load balancers", func() {
+			p.Spec.ExternalAccess.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+			Ω(MakeNodeExternalService(p, 0).Spec.LoadBalancerSourceRanges).Should(Equal([]string{"10.0.0.0/8"}))
+
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			Ω(MakeNodeExternalService(p, 0).Spec.LoadBalancerSourceRanges).Should(BeEmpty())
+		})
+
+		It("should select the in-service pod of the ordinal", func() {
+			service := MakeNodeExternalService(p, 1)
+			Ω(service.Spec.Selector).Should(HaveKeyWithValue(util.InServiceLabel, "true"))
+			Ω(service.Spec.Selector).Should(ContainElement(util.PodNameForNode(p.Name, 1)))
+		})
+	})
+
+	Context("External endpoints", func() {
+		It("should advertise the hostnames and the node ports of every ordinal", func() {
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			p.Spec.ExternalAccess.NodePortBase = 30100
+			p.Spec.ExternalAccess.HostnameTemplate = "{cluster}-node-{ordinal}.example.com"
+			data := MakeNodeConfigMap(p).Data
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_ACCESS", "true"))
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_HOST_0", "example-node-0.example.com"))
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_HOST_1", "example-node-1.example.com"))
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_PORT_0", "30100"))
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_PORT_1", "30101"))
+		})
+
+		It("should advertise the service port of load balancers", func() {
+			data := MakeNodeConfigMap(p).Data
+			Ω(data).Should(HaveKeyWithValue("K8_EXTERNAL_PORT_0", "12345"))
+			Ω(data).ShouldNot(HaveKey("K8_EXTERNAL_HOST_0"))
+		})
+
+		It("should let the nodes discover random node ports", func() {
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			data := MakeNodeConfigMap(p).Data
+			Ω(data).ShouldNot(HaveKey("K8_EXTERNAL_PORT_0"))
+		})
+
+		It("should not be set without external access", func() {
+			p.Spec.ExternalAccess.Enabled = false
+			data := MakeNodeConfigMap(p).Data
+			Ω(data).ShouldNot(HaveKey("K8_EXTERNAL_ACCESS"))
+			Ω(data).ShouldNot(HaveKey("K8_EXTERNAL_PORT_0"))
+		})
+	})
+
+	Context("S3 Tier 2", func() {
+		var (
+			podSpec corev1.PodSpec
+			data    map[string]string
+		)
+
+		BeforeEach(func() {
+			p.Spec.ECS.Tier2 = &api.Tier2Spec{
+				S3: &api.S3Spec{
+					Endpoint:        "https://minio:9000",
+					Bucket:          "ecs",
+					Prefix:          "tier2",
+					PathStyleAccess: true,
+					CABundleSecret:  "minio-ca",
+					Credentials:     "minio-credentials",
+				},
+			}
+			p.WithDefaults()
+			podSpec = makeNodePodSpec(p)
+			data = MakeNodeConfigMap(p).Data
+		})
+
+		It("should configure the S3 client", func() {
+			Ω(data).Should(HaveKeyWithValue("TIER2_STORAGE", "S3"))
+			Ω(data).Should(HaveKeyWithValue("S3_BUCKET", "ecs"))
+			Ω(data).Should(HaveKeyWithValue("S3_PREFIX", "tier2"))
+			Ω(data).Should(HaveKeyWithValue("S3_REGION", api.DefaultS3Region))
+			Ω(data).Should(HaveKeyWithValue("S3_ENDPOINT", "https://minio:9000"))
+			Ω(data).Should(HaveKeyWithValue("S3_PATH_STYLE_ACCESS", "true"))
+			Ω(data).Should(HaveKeyWithValue("S3_CA_BUNDLE", tier2CAMountPoint+"/"+api.DefaultS3CABundleKey))
+		})
+
+		It("should leave the AWS endpoint to the region", func() {
+			p.Spec.ECS.Tier2.S3.Endpoint = ""
+			Ω(MakeNodeConfigMap(p).Data).ShouldNot(HaveKey("S3_ENDPOINT"))
+		})
+
+		It("should read the credentials from the secret", func() {
+			Ω(podSpec.Containers[0].EnvFrom).Should(ContainElement(corev1.EnvFromSource{
+				Prefix: "S3_",
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "minio-credentials"},
+				},
+			}))
+		})
+
+		It("should mount the CA bundle", func() {
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      tier2CAVolumeName,
+				MountPath: tier2CAMountPoint,
+				ReadOnly:  true,
+			}))
+			Ω(podSpec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: tier2CAVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "minio-ca"},
+				},
+			}))
+		})
+
+		It("should not mount a Tier 2 volume", func() {
+			for _, volume := range podSpec.Volumes {
+				Ω(volume.Name).ShouldNot(Equal(tier2VolumeName))
+			}
+		})
+
+		It("should not add Tier 2 JVM options", func() {
+			Ω(getTier2JavaOpts(p.Spec.ECS)).Should(BeEmpty())
+			Ω(data["JAVA_OPTS"]).ShouldNot(ContainSubstring("kerberos"))
+		})
+	})
+
+	Context("Kerberos HDFS Tier 2", func() {
+		var (
+			podSpec corev1.PodSpec
+			data    map[string]string
+		)
+
+		BeforeEach(func() {
+			p.Spec.ECS.Tier2 = &api.Tier2Spec{
+				Hdfs: &api.HDFSSpec{
+					Uri:       "hdfs://namenode:8020",
+					Root:      "/ecs",
+					ConfigMap: "hadoop-conf",
+					Kerberos: &api.HDFSKerberosSpec{
+						Principal:     "ecs/_HOST@EXAMPLE.COM",
+						KeytabSecret:  "ecs-keytab",
+						Krb5ConfigMap: "krb5",
+					},
+				},
+			}
+			p.WithDefaults()
+			podSpec = makeNodePodSpec(p)
+			data = MakeNodeConfigMap(p).Data
+		})
+
+		It("should configure the HDFS client", func() {
+			Ω(data).Should(HaveKeyWithValue("TIER2_STORAGE", "HDFS"))
+			Ω(data).Should(HaveKeyWithValue("HDFS_URL", "hdfs://namenode:8020"))
+			Ω(data).Should(HaveKeyWithValue("HADOOP_CONF_DIR", hdfsConfMountPoint))
+			Ω(data).Should(HaveKeyWithValue("HDFS_KERBEROS_PRINCIPAL", "ecs/_HOST@EXAMPLE.COM"))
+			Ω(data).Should(HaveKeyWithValue("HDFS_KERBEROS_KEYTAB", keytabMountPoint+"/"+api.DefaultHDFSKeytabKey))
+		})
+
+		It("should mount the keytab, krb5.conf and the Hadoop configuration", func() {
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      keytabVolumeName,
+				MountPath: keytabMountPoint,
+				ReadOnly:  true,
+			}))
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      krb5VolumeName,
+				MountPath: krb5MountPoint,
+				ReadOnly:  true,
+			}))
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      hdfsConfVolumeName,
+				MountPath: hdfsConfMountPoint,
+				ReadOnly:  true,
+			}))
+			Ω(podSpec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: keytabVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "ecs-keytab"},
+				},
+			}))
+			Ω(podSpec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: krb5VolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "krb5"},
+					},
+				},
+			}))
+		})
+
+		It("should add the Kerberos JVM options", func() {
+			Ω(getTier2JavaOpts(p.Spec.ECS)).Should(Equal([]string{
+				"-Djava.security.krb5.conf=" + krb5MountPoint + "/krb5.conf",
+				"-Dhadoop.security.authentication=kerberos",
+				"-Dhdfs.kerberos.principal=ecs/_HOST@EXAMPLE.COM",
+				"-Dhdfs.kerberos.keytab=" + keytabMountPoint + "/" + api.DefaultHDFSKeytabKey,
+			}))
+			Ω(data["JAVA_OPTS"]).Should(ContainSubstring("-Dhadoop.security.authentication=kerberos"))
+		})
+
+		It("should not mount Kerberos files without Kerberos", func() {
+			p.Spec.ECS.Tier2.Hdfs.Kerberos = nil
+			podSpec = makeNodePodSpec(p)
+			for _, volume := range podSpec.Volumes {
+				Ω(volume.Name).ShouldNot(Equal(keytabVolumeName))
+				Ω(volume.Name).ShouldNot(Equal(krb5VolumeName))
+			}
+			Ω(getTier2JavaOpts(p.Spec.ECS)).Should(BeEmpty())
+		})
+	})
+
+	Context("Filesystem Tier 2", func() {
+		It("should mount the default claim", func() {
+			podSpec := makeNodePodSpec(p)
+			Ω(podSpec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: tier2VolumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: api.DefaultECSTier2ClaimName},
+				},
+			}))
+			Ω(MakeNodeConfigMap(p).Data).Should(HaveKeyWithValue("NFS_MOUNT", api.DefaultECSTier2MountPath))
+		})
+
+		It("should mount any volume source at its sub path", func() {
+			p.Spec.ECS.Tier2 = &api.Tier2Spec{
+				FileSystem: &api.FileSystemSpec{
+					VolumeSource: corev1.VolumeSource{
+						NFS: &corev1.NFSVolumeSource{Server: "nfs.example.com", Path: "/exports"},
+					},
+					SubPath: "example",
+				},
+			}
+			p.WithDefaults()
+			podSpec := makeNodePodSpec(p)
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      tier2VolumeName,
+				MountPath: api.DefaultECSTier2MountPath,
+				SubPath:   "example",
+			}))
+			Ω(podSpec.Volumes).Should(ContainElement(corev1.Volume{
+				Name: tier2VolumeName,
+				VolumeSource: corev1.VolumeSource{
+					NFS: &corev1.NFSVolumeSource{Server: "nfs.example.com", Path: "/exports"},
+				},
+			}))
+		})
+
+		It("should make the claim from its template", func() {
+			template := &corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			}
+			p.Spec.ECS.Tier2.FileSystem.VolumeClaimTemplate = template
+			pvc := MakeTier2PersistentVolumeClaim(p)
+			Ω(pvc.Name).Should(Equal(api.DefaultECSTier2ClaimName))
+			Ω(pvc.Namespace).Should(Equal(p.Namespace))
+			Ω(pvc.Spec).Should(Equal(*template))
+			Ω(pvc.OwnerReferences).Should(BeEmpty())
+		})
+	})
+})
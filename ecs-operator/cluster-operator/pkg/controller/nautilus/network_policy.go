@@ -0,0 +1,309 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultZookeeperPort = 2181
+	dnsPort              = 53
+	kerberosPort         = 88
+
+	// Data transfer ports of the HDFS data nodes, in Hadoop 3 and 2
+	hdfsDataNodePort       = 9866
+	hdfsLegacyDataNodePort = 50010
+	defaultHdfsPort        = 8020
+)
+
+// defaultOperatorPodLabels are the labels of the operator pods in
+// deploy/operator.yaml
+var defaultOperatorPodLabels = map[string]string{"name": "ecs-operator"}
+
+// MakeNetworkPolicies returns the NetworkPolicies of the cluster
+func MakeNetworkPolicies(p *api.ECSCluster) []*networkingv1.NetworkPolicy {
+	return []*networkingv1.NetworkPolicy{
+		MakeBookieNetworkPolicy(p),
+		MakeControllerNetworkPolicy(p),
+		MakeNodeNetworkPolicy(p),
+	}
+}
+
+// MakeBookieNetworkPolicy only lets the nodes and the bookies, including the
+// standalone AutoRecovery pods, reach the bookies
+func MakeBookieNetworkPolicy(p *api.ECSCluster) *networkingv1.NetworkPolicy {
+	bookies := []networkingv1.NetworkPolicyPeer{
+		podPeer(util.LabelsForBookie(p)),
+		podPeer(util.LabelsForAutoRecovery(p)),
+	}
+
+	return makeNetworkPolicy(p, util.NetworkPolicyNameForBookie(p.Name), util.LabelsForBookie(p),
+		[]networkingv1.NetworkPolicyIngressRule{
+			{
+				Ports: tcpPorts(api.DefaultBookiePort),
+				From:  append(bookies, podPeer(util.LabelsForNode(p))),
+			},
+		},
+		[]networkingv1.NetworkPolicyEgressRule{
+			{
+				Ports: tcpPorts(api.DefaultBookiePort),
+				To:    bookies,
+			},
+			zookeeperEgress(p),
+			dnsEgress(),
+		},
+	)
+}
+
+// MakeControllerNetworkPolicy lets the nodes, the controllers and the clients
+// reach the controllers, and the operator reach their REST API
+func MakeControllerNetworkPolicy(p *api.ECSCluster) *networkingv1.NetworkPolicy {
+	from := []networkingv1.NetworkPolicyPeer{
+		podPeer(util.LabelsForNode(p)),
+		podPeer(util.LabelsForController(p)),
+		clientPeer(p.Spec.NetworkPolicy),
+	}
+
+	return makeNetworkPolicy(p, util.NetworkPolicyNameForController(p.Name), util.LabelsForController(p),
+		[]networkingv1.NetworkPolicyIngressRule{
+			{
+				Ports: tcpPorts(api.DefaultControllerGRPCPort, api.DefaultControllerRESTPort),
+				From:  from,
+			},
+			{
+				Ports: tcpPorts(api.DefaultControllerRESTPort),
+				From:  []networkingv1.NetworkPolicyPeer{operatorPeer(p.Spec.NetworkPolicy)},
+			},
+		},
+		[]networkingv1.NetworkPolicyEgressRule{
+			{
+				Ports: tcpPorts(api.DefaultControllerGRPCPort, api.DefaultControllerRESTPort),
+				To:    []networkingv1.NetworkPolicyPeer{podPeer(util.LabelsForController(p))},
+			},
+			{
+				Ports: tcpPorts(api.DefaultNodePort),
+				To:    []networkingv1.NetworkPolicyPeer{podPeer(util.LabelsForNode(p))},
+			},
+			zookeeperEgress(p),
+			dnsEgress(),
+		},
+	)
+}
+
+// MakeNodeNetworkPolicy lets the controllers, the nodes and the clients reach
+// the nodes. With external access, clients outside of Kubernetes are allowed
+// too, restricted to the load balancer source ranges if any
+func MakeNodeNetworkPolicy(p *api.ECSCluster) *networkingv1.NetworkPolicy {
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{
+			Ports: tcpPorts(api.DefaultNodePort),
+			From: []networkingv1.NetworkPolicyPeer{
+				podPeer(util.LabelsForController(p)),
+				podPeer(util.LabelsForNode(p)),
+				clientPeer(p.Spec.NetworkPolicy),
+			},
+		},
+	}
+
+	externalAccess := p.Spec.ExternalAccess
+	if externalAccess.Enabled {
+		external := networkingv1.NetworkPolicyIngressRule{
+			Ports: tcpPorts(api.DefaultNodePort),
+		}
+		if externalAccess.Type == corev1.ServiceTypeLoadBalancer {
+			for _, cidr := range externalAccess.LoadBalancerSourceRanges {
+				external.From = append(external.From, networkingv1.NetworkPolicyPeer{
+					IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+				})
+			}
+		}
+		ingress = append(ingress, external)
+	}
+
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: tcpPorts(api.DefaultBookiePort),
+			To:    []networkingv1.NetworkPolicyPeer{podPeer(util.LabelsForBookie(p))},
+		},
+		{
+			Ports: tcpPorts(api.DefaultControllerGRPCPort, api.DefaultControllerRESTPort),
+			To:    []networkingv1.NetworkPolicyPeer{podPeer(util.LabelsForController(p))},
+		},
+		{
+			Ports: tcpPorts(api.DefaultNodePort),
+			To:    []networkingv1.NetworkPolicyPeer{podPeer(util.LabelsForNode(p))},
+		},
+		zookeeperEgress(p),
+		dnsEgress(),
+	}
+
+	// Filesystem Tier 2 volumes are mounted by the kubelet, not by the nodes
+	if ports := tier2Ports(p.Spec.ECS.Tier2); len(ports) > 0 {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{Ports: ports})
+	}
+
+	return makeNetworkPolicy(p, util.NetworkPolicyNameForNode(p.Name), util.LabelsForNode(p), ingress, egress)
+}
+
+func makeNetworkPolicy(p *api.ECSCluster, name string, podLabels map[string]string,
+	ingress []networkingv1.NetworkPolicyIngressRule, egress []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "NetworkPolicy",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.Namespace,
+			Labels:    util.LabelsForECSCluster(p),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+			Ingress: ingress,
+			Egress:  egress,
+		},
+	}
+}
+
+func podPeer(labels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: labels,
+		},
+	}
+}
+
+// clientPeer selects the clients of the cluster, which are all the pods of
+// its namespace by default
+func clientPeer(spec *api.NetworkPolicySpec) networkingv1.NetworkPolicyPeer {
+	if spec == nil || spec.ClientNamespaceSelector == nil && spec.ClientPodSelector == nil {
+		return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}}
+	}
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: spec.ClientNamespaceSelector.DeepCopy(),
+		PodSelector:       spec.ClientPodSelector.DeepCopy(),
+	}
+}
+
+// operatorPeer selects the operator pods, which are in the namespace of the
+// cluster by default
+func operatorPeer(spec *api.NetworkPolicySpec) networkingv1.NetworkPolicyPeer {
+	peer := podPeer(defaultOperatorPodLabels)
+	if spec == nil {
+		return peer
+	}
+	if spec.OperatorPodSelector != nil {
+		peer.PodSelector = spec.OperatorPodSelector.DeepCopy()
+	}
+	peer.NamespaceSelector = spec.OperatorNamespaceSelector.DeepCopy()
+	return peer
+}
+
+func tcpPorts(ports ...int) []networkingv1.NetworkPolicyPort {
+	return networkPorts(corev1.ProtocolTCP, ports...)
+}
+
+func networkPorts(protocol corev1.Protocol, ports ...int) []networkingv1.NetworkPolicyPort {
+	policyPorts := make([]networkingv1.NetworkPolicyPort, len(ports))
+	for i, port := range ports {
+		protocol := protocol
+		port := intstr.FromInt(port)
+		policyPorts[i] = networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port}
+	}
+	return policyPorts
+}
+
+func dnsEgress() networkingv1.NetworkPolicyEgressRule {
+	return networkingv1.NetworkPolicyEgressRule{
+		Ports: append(networkPorts(corev1.ProtocolUDP, dnsPort), tcpPorts(dnsPort)...),
+	}
+}
+
+// zookeeperEgress allows the ports of the ZooKeeper servers in the connection
+// string, e.g. "zk-0:2181,zk-1:2181/chroot"
+func zookeeperEgress(p *api.ECSCluster) networkingv1.NetworkPolicyEgressRule {
+	servers := p.Spec.ZookeeperUri
+	if i := strings.Index(servers, "/"); i >= 0 {
+		servers = servers[:i]
+	}
+
+	seen := map[int]bool{}
+	var ports []int
+	for _, server := range strings.Split(servers, ",") {
+		port := defaultZookeeperPort
+		if i := strings.LastIndex(server, ":"); i >= 0 {
+			if parsed, err := strconv.Atoi(server[i+1:]); err == nil {
+				port = parsed
+			}
+		}
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return networkingv1.NetworkPolicyEgressRule{Ports: tcpPorts(ports...)}
+}
+
+// tier2Ports returns the ports the nodes connect to in order to reach Tier 2
+func tier2Ports(tier2 *api.Tier2Spec) []networkingv1.NetworkPolicyPort {
+	switch {
+	case tier2.ECS != nil:
+		return tcpPorts(urlPort(tier2.ECS.Uri, 80))
+	case tier2.S3 != nil:
+		// The AWS endpoints are only reachable over HTTPS
+		if tier2.S3.Endpoint == "" {
+			return tcpPorts(443)
+		}
+		return tcpPorts(urlPort(tier2.S3.Endpoint, 443))
+	case tier2.Hdfs != nil:
+		ports := tcpPorts(urlPort(tier2.Hdfs.Uri, defaultHdfsPort), hdfsDataNodePort, hdfsLegacyDataNodePort)
+		if tier2.Hdfs.Kerberos != nil {
+			ports = append(ports, tcpPorts(kerberosPort)...)
+			ports = append(ports, networkPorts(corev1.ProtocolUDP, kerberosPort)...)
+		}
+		return ports
+	}
+	return nil
+}
+
+// urlPort returns the port of a URL, or the default port of its scheme
+func urlPort(uri string, defaultPort int) int {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return defaultPort
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+	switch u.Scheme {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return defaultPort
+}
@@ -0,0 +1,138 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// ingressPeers returns the peers allowed to reach the given port
+func ingressPeers(policy *networkingv1.NetworkPolicy, port int) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, rule := range policy.Spec.Ingress {
+		for _, policyPort := range rule.Ports {
+			if *policyPort.Port == intstr.FromInt(port) {
+				peers = append(peers, rule.From...)
+			}
+		}
+	}
+	return peers
+}
+
+var _ = Describe("NetworkPolicies", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+		p.Spec.NetworkPolicy = &api.NetworkPolicySpec{Enabled: true}
+	})
+
+	Context("Without a networkPolicy section", func() {
+		It("should not panic", func() {
+			p.Spec.NetworkPolicy = nil
+			Ω(func() { MakeNetworkPolicies(p) }).ShouldNot(Panic())
+		})
+	})
+
+	Context("Bookies", func() {
+		It("should only admit the nodes and the bookies", func() {
+			policy := MakeBookieNetworkPolicy(p)
+			Ω(policy.Name).Should(Equal(util.NetworkPolicyNameForBookie(p.Name)))
+			Ω(policy.Spec.PodSelector.MatchLabels).Should(Equal(util.LabelsForBookie(p)))
+			Ω(ingressPeers(policy, api.DefaultBookiePort)).Should(ConsistOf(
+				podPeer(util.LabelsForBookie(p)),
+				podPeer(util.LabelsForAutoRecovery(p)),
+				podPeer(util.LabelsForNode(p)),
+			))
+		})
+	})
+
+	Context("Controllers", func() {
+		It("should admit the clients of the namespace by default", func() {
+			policy := MakeControllerNetworkPolicy(p)
+			Ω(ingressPeers(policy, api.DefaultControllerGRPCPort)).Should(ContainElement(
+				networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}},
+			))
+		})
+
+		It("should admit the operator on the REST port", func() {
+			p.Spec.NetworkPolicy.ClientNamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"ecs-client": "true"},
+			}
+			policy := MakeControllerNetworkPolicy(p)
+			Ω(ingressPeers(policy, api.DefaultControllerRESTPort)).Should(ContainElement(
+				podPeer(defaultOperatorPodLabels),
+			))
+			Ω(ingressPeers(policy, api.DefaultControllerGRPCPort)).ShouldNot(ContainElement(
+				podPeer(defaultOperatorPodLabels),
+			))
+		})
+
+		It("should admit an operator in another namespace", func() {
+			namespaceSelector := &metav1.LabelSelector{
+				MatchLabels: map[string]string{"name": "operators"},
+			}
+			podSelector := &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "operator"},
+			}
+			p.Spec.NetworkPolicy.OperatorNamespaceSelector = namespaceSelector
+			p.Spec.NetworkPolicy.OperatorPodSelector = podSelector
+			policy := MakeControllerNetworkPolicy(p)
+			Ω(ingressPeers(policy, api.DefaultControllerRESTPort)).Should(ContainElement(
+				networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector, PodSelector: podSelector},
+			))
+		})
+	})
+
+	Context("Nodes", func() {
+		It("should restrict the clients to the selected namespaces and pods", func() {
+			namespaceSelector := &metav1.LabelSelector{
+				MatchLabels: map[string]string{"ecs-client": "true"},
+			}
+			p.Spec.NetworkPolicy.ClientNamespaceSelector = namespaceSelector
+			policy := MakeNodeNetworkPolicy(p)
+			Ω(ingressPeers(policy, api.DefaultNodePort)).Should(ContainElement(
+				networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector},
+			))
+		})
+
+		It("should admit external clients from the load balancer source ranges", func() {
+			p.Spec.ExternalAccess.Enabled = true
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeLoadBalancer
+			p.Spec.ExternalAccess.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+			policy := MakeNodeNetworkPolicy(p)
+			Ω(ingressPeers(policy, api.DefaultNodePort)).Should(ContainElement(
+				networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+			))
+		})
+
+		It("should allow the ZooKeeper ports of the connection string", func() {
+			p.Spec.ZookeeperUri = "zk-0:2181,zk-1:2182/chroot"
+			egress := zookeeperEgress(p)
+			Ω(egress.Ports).Should(Equal(tcpPorts(2181, 2182)))
+		})
+	})
+})
@@ -0,0 +1,64 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"fmt"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// makeProbe returns the Kubernetes probe for a probe spec
+func makeProbe(spec *api.ProbeSpec) *corev1.Probe {
+	probe := &corev1.Probe{
+		InitialDelaySeconds: spec.InitialDelaySeconds,
+		PeriodSeconds:       spec.PeriodSeconds,
+		TimeoutSeconds:      spec.TimeoutSeconds,
+		FailureThreshold:    spec.FailureThreshold,
+	}
+
+	switch spec.Type {
+	case api.ProbeTypeTCPSocket:
+		probe.TCPSocket = &corev1.TCPSocketAction{
+			Port: intstr.FromInt(int(spec.Port)),
+		}
+	case api.ProbeTypeHTTPGet:
+		probe.HTTPGet = &corev1.HTTPGetAction{
+			Path: spec.Path,
+			Port: intstr.FromInt(int(spec.Port)),
+		}
+	case api.ProbeTypeGRPC:
+		// The Kubernetes API has no native gRPC probes yet
+		probe.Exec = &corev1.ExecAction{
+			Command: []string{"grpc_health_probe", fmt.Sprintf("-addr=:%d", spec.Port)},
+		}
+	default:
+		probe.Exec = &corev1.ExecAction{
+			Command: spec.Command,
+		}
+	}
+	return probe
+}
+
+// makeProbes returns the readiness and liveness probes of a component. The
+// liveness probe waits for as long as the startup probe could take to succeed,
+// so that slow starting containers are not restarted. Only the timings of the
+// startup probe are used
+func makeProbes(spec *api.ProbesSpec) (readiness *corev1.Probe, liveness *corev1.Probe) {
+	readiness = makeProbe(spec.Readiness)
+	liveness = makeProbe(spec.Liveness)
+
+	startup := spec.Startup
+	liveness.InitialDelaySeconds += startup.InitialDelaySeconds + startup.PeriodSeconds*startup.FailureThreshold
+	return readiness, liveness
+}
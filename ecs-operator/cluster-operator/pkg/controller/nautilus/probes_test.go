@@ -0,0 +1,76 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probes", func() {
+	Context("Probe types", func() {
+		It("should make a tcpSocket probe", func() {
+			probe := makeProbe(&api.ProbeSpec{Type: api.ProbeTypeTCPSocket, Port: 3181, PeriodSeconds: 15})
+			Ω(probe.TCPSocket).ShouldNot(BeNil())
+			Ω(probe.TCPSocket.Port).Should(Equal(intstr.FromInt(3181)))
+			Ω(probe.PeriodSeconds).Should(BeEquivalentTo(15))
+			Ω(probe.Exec).Should(BeNil())
+		})
+
+		It("should make an httpGet probe", func() {
+			probe := makeProbe(&api.ProbeSpec{Type: api.ProbeTypeHTTPGet, Port: 10080, Path: api.ControllerHealthPath})
+			Ω(probe.HTTPGet).ShouldNot(BeNil())
+			Ω(probe.HTTPGet.Path).Should(Equal("/health"))
+			Ω(probe.HTTPGet.Port).Should(Equal(intstr.FromInt(10080)))
+		})
+
+		It("should run grpc_health_probe for a grpc probe", func() {
+			probe := makeProbe(&api.ProbeSpec{Type: api.ProbeTypeGRPC, Port: 9090})
+			Ω(probe.Exec).ShouldNot(BeNil())
+			Ω(probe.Exec.Command).Should(Equal([]string{"grpc_health_probe", "-addr=:9090"}))
+		})
+
+		It("should run the command of an exec probe", func() {
+			probe := makeProbe(&api.ProbeSpec{Type: api.ProbeTypeExec, Command: []string{"true"}})
+			Ω(probe.Exec).ShouldNot(BeNil())
+			Ω(probe.Exec.Command).Should(Equal([]string{"true"}))
+		})
+	})
+
+	Context("Startup budget", func() {
+		It("should delay the liveness probe by the worst case startup time", func() {
+			spec := api.BookieProbeDefaults()
+			readiness, liveness := makeProbes(spec)
+			Ω(readiness.InitialDelaySeconds).Should(BeZero())
+			// 30 failed checks, 10 seconds apart
+			Ω(liveness.InitialDelaySeconds).Should(BeEquivalentTo(300))
+		})
+
+		It("should add the startup delay to the liveness delay", func() {
+			spec := api.ControllerProbeDefaults()
+			spec.Liveness.InitialDelaySeconds = 10
+			spec.Startup.InitialDelaySeconds = 20
+			_, liveness := makeProbes(spec)
+			// 10 + 20 + 24 failed checks, 5 seconds apart
+			Ω(liveness.InitialDelaySeconds).Should(BeEquivalentTo(150))
+		})
+
+		It("should not change the spec", func() {
+			spec := api.NodeProbeDefaults()
+			makeProbes(spec)
+			Ω(spec.Liveness.InitialDelaySeconds).Should(BeZero())
+		})
+	})
+})
@@ -0,0 +1,74 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// MakeClusterResources returns every resource the operator creates for the
+// cluster, in the order they are deployed. The cluster spec must have its
+// defaults applied
+func MakeClusterResources(p *api.ECSCluster) []runtime.Object {
+	var objects []runtime.Object
+
+	if p.Spec.NetworkPolicy.IsEnabled() {
+		for _, policy := range MakeNetworkPolicies(p) {
+			objects = append(objects, policy)
+		}
+	}
+
+	if p.Spec.Zookeeper != nil {
+		objects = append(objects,
+			MakeZookeeperHeadlessService(p),
+			MakeZookeeperClientService(p),
+			MakeZookeeperPodDisruptionBudget(p),
+			MakeZookeeperStatefulSet(p),
+		)
+	}
+
+	if fs := p.Spec.ECS.Tier2.FileSystem; fs != nil && fs.PersistentVolumeClaim != nil && fs.VolumeClaimTemplate != nil {
+		objects = append(objects, MakeTier2PersistentVolumeClaim(p))
+	}
+
+	objects = append(objects,
+		MakeBookieHeadlessService(p),
+		MakeBookiePodDisruptionBudget(p),
+		MakeBookieConfigMap(p),
+		MakeBookieStatefulSet(p),
+	)
+
+	if p.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		objects = append(objects,
+			MakeAutoRecoveryPodDisruptionBudget(p),
+			MakeAutoRecoveryDeployment(p),
+		)
+	}
+
+	objects = append(objects,
+		MakeControllerPodDisruptionBudget(p),
+		MakeControllerConfigMap(p),
+		MakeControllerDeployment(p),
+		MakeControllerService(p),
+		MakeNodeHeadlessService(p),
+		MakeNodePodDisruptionBudget(p),
+		MakeNodeConfigMap(p),
+		MakeNodeStatefulSet(p),
+	)
+
+	if p.Spec.ExternalAccess.Enabled {
+		for _, service := range MakeNodeExternalServices(p) {
+			objects = append(objects, service)
+		}
+	}
+	return objects
+}
@@ -0,0 +1,125 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"fmt"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	seccompPodAnnotation = "seccomp.security.alpha.kubernetes.io/pod"
+
+	tmpVolumeName  = "tmp"
+	logsVolumeName = "logs"
+	confVolumeName = "conf"
+	tmpMountPath   = "/tmp"
+
+	// confSeedMountPath is where the init container mounts the conf volume
+	// to copy the conf directory of the image into it
+	confSeedMountPath = "/seed"
+
+	bookieConfMountPath = "/opt/bookkeeper/conf"
+	bookieLogsMountPath = "/opt/bookkeeper/logs"
+	ecsConfMountPath    = "/opt/ecs/conf"
+	ecsLogsMountPath    = "/opt/ecs/logs"
+)
+
+// makePodAnnotations returns the pod template annotations of a component
+func makePodAnnotations(restartedAt string, security *api.SecuritySpec) map[string]string {
+	annotations := util.AnnotationsForRestart(restartedAt)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[seccompPodAnnotation] = security.SeccompProfile
+	return annotations
+}
+
+// configureSecurity applies the security settings of a component to its pod
+// spec.
+// The entrypoints of the images write their conf files on startup and the
+// components write logs, which neither the user a pod runs as nor a read-only
+// root filesystem may allow in the directories of the image. emptyDir volumes
+// are therefore mounted on the logs directory and on the conf directory,
+// which an init container first fills with the conf files of the image. An
+// empty confMountPath leaves the conf directory to the caller. With a
+// read-only root filesystem, /tmp is an emptyDir volume as well
+func configureSecurity(podSpec *corev1.PodSpec, security *api.SecuritySpec, confMountPath string, logsMountPath string) {
+	podSpec.SecurityContext = security.PodSecurityContext.DeepCopy()
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		container.SecurityContext = makeContainerSecurityContext(security)
+		container.VolumeMounts = append(container.VolumeMounts,
+			corev1.VolumeMount{Name: logsVolumeName, MountPath: logsMountPath},
+		)
+		if confMountPath != "" {
+			container.VolumeMounts = append(container.VolumeMounts,
+				corev1.VolumeMount{Name: confVolumeName, MountPath: confMountPath},
+			)
+		}
+		if security.ReadOnlyRootFilesystem {
+			container.VolumeMounts = append(container.VolumeMounts,
+				corev1.VolumeMount{Name: tmpVolumeName, MountPath: tmpMountPath},
+			)
+		}
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, makeEmptyDirVolume(logsVolumeName))
+	if confMountPath != "" {
+		podSpec.Volumes = append(podSpec.Volumes, makeEmptyDirVolume(confVolumeName))
+		podSpec.InitContainers = append(podSpec.InitContainers, makeConfInitContainer(podSpec.Containers[0], security, confMountPath))
+	}
+	if security.ReadOnlyRootFilesystem {
+		podSpec.Volumes = append(podSpec.Volumes, makeEmptyDirVolume(tmpVolumeName))
+	}
+}
+
+// makeConfInitContainer returns the init container that copies the conf
+// directory of the image of a container into the conf volume
+func makeConfInitContainer(container corev1.Container, security *api.SecuritySpec, confMountPath string) corev1.Container {
+	return corev1.Container{
+		Name:            "copy-conf",
+		Image:           container.Image,
+		ImagePullPolicy: container.ImagePullPolicy,
+		Command: []string{
+			"sh", "-c", fmt.Sprintf("cp -R %s/. %s", confMountPath, confSeedMountPath),
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: confVolumeName, MountPath: confSeedMountPath},
+		},
+		SecurityContext: makeContainerSecurityContext(security),
+	}
+}
+
+func makeContainerSecurityContext(security *api.SecuritySpec) *corev1.SecurityContext {
+	securityContext := security.SecurityContext.DeepCopy()
+	if !security.ReadOnlyRootFilesystem {
+		return securityContext
+	}
+
+	if securityContext == nil {
+		securityContext = &corev1.SecurityContext{}
+	}
+	readOnlyRootFilesystem := true
+	securityContext.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	return securityContext
+}
+
+func makeEmptyDirVolume(name string) corev1.Volume {
+	return corev1.Volume{
+		Name:         name,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}
+}
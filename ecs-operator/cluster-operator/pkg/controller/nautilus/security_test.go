@@ -0,0 +1,108 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func volumeNames(podSpec *corev1.PodSpec) []string {
+	var names []string
+	for _, volume := range podSpec.Volumes {
+		names = append(names, volume.Name)
+	}
+	return names
+}
+
+var _ = Describe("Pod security", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+	})
+
+	Context("Security contexts", func() {
+		It("should apply the settings of the component", func() {
+			podSpec := makeBookiePodSpec(p.Name, p.Spec.Bookkeeper)
+			Ω(podSpec.SecurityContext).Should(Equal(p.Spec.Bookkeeper.Security.PodSecurityContext))
+			Ω(podSpec.Containers[0].SecurityContext).Should(Equal(p.Spec.Bookkeeper.Security.SecurityContext))
+			Ω(podSpec.InitContainers[0].SecurityContext).Should(Equal(p.Spec.Bookkeeper.Security.SecurityContext))
+		})
+
+		It("should set the seccomp annotation", func() {
+			annotations := makePodAnnotations("", p.Spec.Bookkeeper.Security)
+			Ω(annotations).Should(Equal(map[string]string{seccompPodAnnotation: api.DefaultSeccompProfile}))
+		})
+	})
+
+	Context("Writable directories", func() {
+		It("should copy the conf directory of the image into a volume", func() {
+			podSpec := makeControllerPodSpec(p.Name, p.Spec.ECS)
+			Ω(podSpec.InitContainers).Should(HaveLen(1))
+			initContainer := podSpec.InitContainers[0]
+			Ω(initContainer.Image).Should(Equal(podSpec.Containers[0].Image))
+			Ω(initContainer.Command).Should(Equal([]string{"sh", "-c", "cp -R /opt/ecs/conf/. /seed"}))
+			Ω(initContainer.VolumeMounts).Should(Equal([]corev1.VolumeMount{{Name: confVolumeName, MountPath: confSeedMountPath}}))
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: confVolumeName, MountPath: ecsConfMountPath}))
+		})
+
+		It("should mount the conf and logs volumes without a read-only root filesystem", func() {
+			podSpec := makeBookiePodSpec(p.Name, p.Spec.Bookkeeper)
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: confVolumeName, MountPath: bookieConfMountPath}))
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: logsVolumeName, MountPath: bookieLogsMountPath}))
+			Ω(volumeNames(podSpec)).Should(ConsistOf(confVolumeName, logsVolumeName))
+		})
+
+		It("should leave the conf directory of ZooKeeper to its builder", func() {
+			p.Spec.Zookeeper = &api.ZookeeperSpec{}
+			p.WithDefaults()
+			podSpec := makeZookeeperPodSpec(p)
+			Ω(podSpec.InitContainers).Should(BeEmpty())
+			Ω(volumeNames(podSpec)).Should(ConsistOf(zookeeperConfVolumeName, logsVolumeName))
+		})
+	})
+
+	Context("Read-only root filesystem", func() {
+		BeforeEach(func() {
+			p.Spec.ECS.NodeSecurity.ReadOnlyRootFilesystem = true
+		})
+
+		It("should mount the root filesystem read-only", func() {
+			podSpec := makeNodePodSpec(p)
+			Ω(*podSpec.Containers[0].SecurityContext.ReadOnlyRootFilesystem).Should(BeTrue())
+			Ω(*podSpec.InitContainers[0].SecurityContext.ReadOnlyRootFilesystem).Should(BeTrue())
+			Ω(p.Spec.ECS.NodeSecurity.SecurityContext.ReadOnlyRootFilesystem).Should(BeNil())
+		})
+
+		It("should mount a writable /tmp", func() {
+			podSpec := makeNodePodSpec(p)
+			Ω(podSpec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{Name: tmpVolumeName, MountPath: tmpMountPath}))
+			Ω(volumeNames(&podSpec)).Should(ContainElement(tmpVolumeName))
+		})
+
+		It("should not mount /tmp otherwise", func() {
+			podSpec := makeBookiePodSpec(p.Name, p.Spec.Bookkeeper)
+			Ω(volumeNames(podSpec)).ShouldNot(ContainElement(tmpVolumeName))
+		})
+	})
+})
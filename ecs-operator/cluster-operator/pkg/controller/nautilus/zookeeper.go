@@ -0,0 +1,269 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	"fmt"
+	"strings"
+
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	zookeeperServerPort   = 2888
+	zookeeperElectionPort = 3888
+
+	zookeeperDataVolumeName = "data"
+	zookeeperDataMountPath  = "/data"
+	zookeeperConfVolumeName = "conf"
+	zookeeperConfMountPath  = "/conf"
+	zookeeperLogsMountPath  = "/logs"
+)
+
+// MakeZookeeperHeadlessService gives each ZooKeeper server a stable hostname.
+// Addresses are published before the servers are ready, since they must
+// reach each other to form a quorum and become ready
+func MakeZookeeperHeadlessService(p *api.ECSCluster) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.HeadlessServiceNameForZookeeper(p.Name),
+			Namespace: p.Namespace,
+			Labels:    util.LabelsForZookeeper(p),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "client",
+					Port: api.DefaultZookeeperClientPort,
+				},
+				{
+					Name: "server",
+					Port: zookeeperServerPort,
+				},
+				{
+					Name: "leader-election",
+					Port: zookeeperElectionPort,
+				},
+			},
+			Selector:                 util.LabelsForZookeeper(p),
+			ClusterIP:                corev1.ClusterIPNone,
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
+
+// MakeZookeeperClientService is the Service in the ZooKeeper URI of the
+// cluster
+func MakeZookeeperClientService(p *api.ECSCluster) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ClientServiceNameForZookeeper(p.Name),
+			Namespace: p.Namespace,
+			Labels:    util.LabelsForZookeeper(p),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "client",
+					Port: api.DefaultZookeeperClientPort,
+				},
+			},
+			Selector: util.LabelsForZookeeper(p),
+		},
+	}
+}
+
+// MakeZookeeperPodDisruptionBudget keeps the quorum of the ensemble during
+// voluntary disruptions
+func MakeZookeeperPodDisruptionBudget(p *api.ECSCluster) *policyv1beta1.PodDisruptionBudget {
+	minAvailable := intstr.FromInt(int(p.Spec.Zookeeper.Quorum()))
+	return &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.PdbNameForZookeeper(p.Name),
+			Namespace: p.Namespace,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: util.LabelsForZookeeper(p),
+			},
+		},
+	}
+}
+
+// MakeZookeeperStatefulSet returns the ZooKeeper ensemble. The servers are
+// listed in its static configuration, so resizing the ensemble restarts
+// every server
+func MakeZookeeperStatefulSet(p *api.ECSCluster) *appsv1.StatefulSet {
+	zookeeperSpec := p.Spec.Zookeeper
+	return &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StatefulSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.StatefulSetNameForZookeeper(p.Name),
+			Namespace: p.Namespace,
+			Labels:    util.LabelsForZookeeper(p),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: util.HeadlessServiceNameForZookeeper(p.Name),
+			Replicas:    &zookeeperSpec.Replicas,
+			// Servers only become ready once a quorum is up, so they are all
+			// started at once
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      util.LabelsForZookeeper(p),
+					Annotations: makePodAnnotations("", zookeeperSpec.Security),
+				},
+				Spec: *makeZookeeperPodSpec(p),
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: util.LabelsForZookeeper(p),
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      zookeeperDataVolumeName,
+						Namespace: p.Namespace,
+					},
+					Spec: *zookeeperSpec.Storage,
+				},
+			},
+		},
+	}
+}
+
+func makeZookeeperPodSpec(p *api.ECSCluster) *corev1.PodSpec {
+	zookeeperSpec := p.Spec.Zookeeper
+
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:            "zookeeper",
+				Image:           zookeeperSpec.Image.String(),
+				ImagePullPolicy: zookeeperSpec.Image.PullPolicy,
+				// The ID of a server is derived from its ordinal
+				Command: []string{
+					"/bin/bash", "-c",
+					"export ZOO_MY_ID=$((${HOSTNAME##*-} + 1)) && exec /docker-entrypoint.sh zkServer.sh start-foreground",
+				},
+				Ports: []corev1.ContainerPort{
+					{
+						Name:          "client",
+						ContainerPort: api.DefaultZookeeperClientPort,
+					},
+					{
+						Name:          "server",
+						ContainerPort: zookeeperServerPort,
+					},
+					{
+						Name:          "leader-election",
+						ContainerPort: zookeeperElectionPort,
+					},
+				},
+				Env: []corev1.EnvVar{
+					{
+						Name:  "ZOO_SERVERS",
+						Value: zookeeperServers(p),
+					},
+					{
+						Name:  "ZOO_DATA_DIR",
+						Value: zookeeperDataMountPath,
+					},
+					{
+						Name:  "ZOO_DATA_LOG_DIR",
+						Value: fmt.Sprintf("%s/log", zookeeperDataMountPath),
+					},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      zookeeperDataVolumeName,
+						MountPath: zookeeperDataMountPath,
+					},
+					{
+						Name:      zookeeperConfVolumeName,
+						MountPath: zookeeperConfMountPath,
+					},
+				},
+				Resources: *zookeeperSpec.Resources,
+				// Servers only report their status once they are part of a
+				// quorum
+				ReadinessProbe: &corev1.Probe{
+					Handler: corev1.Handler{
+						Exec: &corev1.ExecAction{
+							Command: []string{"zkServer.sh", "status"},
+						},
+					},
+					InitialDelaySeconds: 10,
+					PeriodSeconds:       10,
+					TimeoutSeconds:      5,
+				},
+				LivenessProbe: &corev1.Probe{
+					Handler: corev1.Handler{
+						TCPSocket: &corev1.TCPSocketAction{
+							Port: intstr.FromInt(api.DefaultZookeeperClientPort),
+						},
+					},
+					InitialDelaySeconds: 30,
+					PeriodSeconds:       15,
+					TimeoutSeconds:      5,
+				},
+			},
+		},
+		// The image writes its configuration on startup, which the non-root
+		// user cannot do in the directory of the image
+		Volumes: []corev1.Volume{
+			{
+				Name:         zookeeperConfVolumeName,
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			},
+		},
+		Affinity: util.PodAntiAffinity("zookeeper", p.Name),
+	}
+
+	configureSecurity(podSpec, zookeeperSpec.Security, "", zookeeperLogsMountPath)
+
+	return podSpec
+}
+
+// zookeeperServers returns the servers of the ensemble in the format of the
+// ZooKeeper image, e.g. "server.1=example-zookeeper-0.example-zookeeper-headless:2888:3888"
+func zookeeperServers(p *api.ECSCluster) string {
+	stsName := util.StatefulSetNameForZookeeper(p.Name)
+	headlessName := util.HeadlessServiceNameForZookeeper(p.Name)
+
+	servers := make([]string, p.Spec.Zookeeper.Replicas)
+	for i := range servers {
+		servers[i] = fmt.Sprintf("server.%d=%s-%d.%s:%d:%d", i+1, stsName, i, headlessName,
+			zookeeperServerPort, zookeeperElectionPort)
+	}
+	return strings.Join(servers, " ")
+}
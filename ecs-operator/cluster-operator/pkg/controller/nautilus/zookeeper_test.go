@@ -0,0 +1,110 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecs
+
+import (
+	api "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Embedded ZooKeeper", func() {
+	var p *api.ECSCluster
+
+	BeforeEach(func() {
+		p = &api.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+			Spec: api.ClusterSpec{
+				Zookeeper: &api.ZookeeperSpec{},
+			},
+		}
+		p.WithDefaults()
+	})
+
+	Context("Stateful-set", func() {
+		var sts *appsv1.StatefulSet
+
+		BeforeEach(func() {
+			sts = MakeZookeeperStatefulSet(p)
+		})
+
+		It("should start every server at once", func() {
+			Ω(*sts.Spec.Replicas).Should(BeEquivalentTo(api.DefaultZookeeperReplicas))
+			Ω(sts.Spec.PodManagementPolicy).Should(Equal(appsv1.ParallelPodManagement))
+			Ω(sts.Spec.ServiceName).Should(Equal("example-zookeeper-headless"))
+		})
+
+		It("should keep the data on a claim", func() {
+			Ω(sts.Spec.VolumeClaimTemplates).Should(HaveLen(1))
+			Ω(sts.Spec.VolumeClaimTemplates[0].Name).Should(Equal(zookeeperDataVolumeName))
+			Ω(sts.Spec.VolumeClaimTemplates[0].Spec).Should(Equal(*p.Spec.Zookeeper.Storage))
+			Ω(sts.Spec.Template.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      zookeeperDataVolumeName,
+				MountPath: zookeeperDataMountPath,
+			}))
+		})
+
+		It("should select the pods of the ensemble", func() {
+			Ω(sts.Spec.Selector.MatchLabels).Should(Equal(util.LabelsForZookeeper(p)))
+			Ω(sts.Spec.Template.Labels).Should(Equal(util.LabelsForZookeeper(p)))
+		})
+	})
+
+	Context("Configuration", func() {
+		It("should list every server of the ensemble", func() {
+			Ω(zookeeperServers(p)).Should(Equal(
+				"server.1=example-zookeeper-0.example-zookeeper-headless:2888:3888 " +
+					"server.2=example-zookeeper-1.example-zookeeper-headless:2888:3888 " +
+					"server.3=example-zookeeper-2.example-zookeeper-headless:2888:3888"))
+		})
+
+		It("should pass the servers and directories to the image", func() {
+			env := MakeZookeeperStatefulSet(p).Spec.Template.Spec.Containers[0].Env
+			Ω(env).Should(ContainElement(corev1.EnvVar{Name: "ZOO_SERVERS", Value: zookeeperServers(p)}))
+			Ω(env).Should(ContainElement(corev1.EnvVar{Name: "ZOO_DATA_DIR", Value: "/data"}))
+			Ω(env).Should(ContainElement(corev1.EnvVar{Name: "ZOO_DATA_LOG_DIR", Value: "/data/log"}))
+		})
+	})
+
+	Context("Services", func() {
+		It("should publish the servers before they are ready", func() {
+			service := MakeZookeeperHeadlessService(p)
+			Ω(service.Name).Should(Equal(util.HeadlessServiceNameForZookeeper(p.Name)))
+			Ω(service.Spec.ClusterIP).Should(Equal(corev1.ClusterIPNone))
+			Ω(service.Spec.PublishNotReadyAddresses).Should(BeTrue())
+			Ω(service.Spec.Ports).Should(HaveLen(3))
+		})
+
+		It("should serve the embedded zookeeper uri", func() {
+			service := MakeZookeeperClientService(p)
+			Ω(p.Spec.ZookeeperUri).Should(HavePrefix(service.Name + "." + service.Namespace + ":"))
+			Ω(service.Spec.Ports).Should(Equal([]corev1.ServicePort{{Name: "client", Port: api.DefaultZookeeperClientPort}}))
+			Ω(service.Spec.Selector).Should(Equal(util.LabelsForZookeeper(p)))
+		})
+	})
+
+	Context("Pod disruption budget", func() {
+		It("should keep a quorum available", func() {
+			pdb := MakeZookeeperPodDisruptionBudget(p)
+			Ω(*pdb.Spec.MinAvailable).Should(Equal(intstr.FromInt(2)))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AutoRecovery", func() {
+	var (
+		s      = scheme.Scheme
+		p      *v1alpha1.ECSCluster
+		r      *ReconcileECSCluster
+		zk     *fakeZookeeper
+		client client.Client
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.Spec.Bookkeeper = &v1alpha1.BookkeeperSpec{AutoRecoveryDeployment: &v1alpha1.AutoRecoverySpec{}}
+		p.WithDefaults()
+		zk = &fakeZookeeper{auditor: "10.0.0.1:3181"}
+	})
+
+	Context("Auditor", func() {
+		BeforeEach(func() {
+			client = fake.NewFakeClient(p)
+			r = &ReconcileECSCluster{client: client, scheme: s, zookeeper: zk}
+			p.Status.SetZookeeperReachableConditionTrue()
+		})
+
+		It("should not look up the auditor while zookeeper is unreachable", func() {
+			p.Status.SetZookeeperReachableConditionFalse(zookeeperReasonUnreachable, "timed out")
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			Ω(zk.lookups).Should(Equal(0))
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionAuditorElected)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal("ZookeeperUnreachable"))
+		})
+
+		It("should record the elected auditor", func() {
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			Ω(zk.lookups).Should(Equal(1))
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionAuditorElected)).Should(BeTrue())
+			Ω(p.Status.Auditor.BookieID).Should(Equal("10.0.0.1:3181"))
+			Ω(p.Status.AutoRecovery.Auditor).Should(Equal("10.0.0.1:3181"))
+		})
+
+		It("should keep the previous result until the next lookup is due", func() {
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			zk.auditor = "10.0.0.2:3181"
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			Ω(zk.lookups).Should(Equal(1))
+			Ω(p.Status.AutoRecovery.Auditor).Should(Equal("10.0.0.1:3181"))
+
+			p.Status.Auditor.LastCheckTime = time.Now().Add(-auditorCheckInterval).Format(time.RFC3339)
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			Ω(zk.lookups).Should(Equal(2))
+			Ω(p.Status.AutoRecovery.Auditor).Should(Equal("10.0.0.2:3181"))
+		})
+
+		It("should report a missing auditor", func() {
+			zk.auditor = ""
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionAuditorElected)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal("NoAuditor"))
+		})
+
+		It("should report zookeeper errors", func() {
+			zk.auditorErr = fmt.Errorf("connection closed")
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionAuditorElected)
+			Ω(condition.Reason).Should(Equal("ZookeeperError"))
+		})
+
+		It("should not look up the auditor when AutoRecovery is disabled", func() {
+			*p.Spec.Bookkeeper.AutoRecovery = false
+			Ω(r.reconcileAutoRecoveryStatus(p)).Should(Succeed())
+			Ω(zk.lookups).Should(Equal(0))
+			Ω(p.Status.AutoRecovery).Should(BeNil())
+		})
+	})
+
+	Context("Bookie ConfigMap", func() {
+		var nn types.NamespacedName
+
+		getConfigMap := func() *corev1.ConfigMap {
+			configMap := &corev1.ConfigMap{}
+			Ω(client.Get(context.TODO(), nn, configMap)).Should(Succeed())
+			return configMap
+		}
+
+		BeforeEach(func() {
+			nn = types.NamespacedName{Name: util.ConfigMapNameForBookie(p.Name), Namespace: p.Namespace}
+		})
+
+		It("should stop AutoRecovery in the bookies once it runs standalone", func() {
+			embedded := p.DeepCopy()
+			embedded.Spec.Bookkeeper.AutoRecoveryDeployment = nil
+			client = fake.NewFakeClient(p, ecs.MakeBookieConfigMap(embedded))
+			r = &ReconcileECSCluster{client: client, scheme: s, zookeeper: zk}
+			Ω(getConfigMap().Data).Should(HaveKey(ecs.BookieAutoRecoveryKey))
+
+			Ω(r.deployBookie(p)).Should(Succeed())
+			Ω(getConfigMap().Data).ShouldNot(HaveKey(ecs.BookieAutoRecoveryKey))
+		})
+
+		It("should run AutoRecovery in the bookies again", func() {
+			client = fake.NewFakeClient(p, ecs.MakeBookieConfigMap(p))
+			r = &ReconcileECSCluster{client: client, scheme: s, zookeeper: zk}
+			p.Spec.Bookkeeper.AutoRecoveryDeployment = nil
+
+			Ω(r.deployBookie(p)).Should(Succeed())
+			Ω(getConfigMap().Data).Should(HaveKeyWithValue(ecs.BookieAutoRecoveryKey, "true"))
+		})
+
+		It("should leave the other keys as they are", func() {
+			configMap := ecs.MakeBookieConfigMap(p)
+			configMap.Data["BK_journalDirectories"] = "/bk/custom"
+			client = fake.NewFakeClient(p, configMap)
+			r = &ReconcileECSCluster{client: client, scheme: s, zookeeper: zk}
+
+			Ω(r.deployBookie(p)).Should(Succeed())
+			Ω(getConfigMap().Data).Should(HaveKeyWithValue("BK_journalDirectories", "/bk/custom"))
+		})
+	})
+})
@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Legacy bookie volumes", func() {
+	var (
+		s      = scheme.Scheme
+		p      *v1alpha1.ECSCluster
+		r      *ReconcileECSCluster
+		client client.Client
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+	})
+
+	getCluster := func() *v1alpha1.ECSCluster {
+		found := &v1alpha1.ECSCluster{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	It("should keep the new layout for new clusters", func() {
+		client = fake.NewFakeClient(p)
+		r = &ReconcileECSCluster{client: client, scheme: s}
+		updated, err := r.detectLegacyBookieVolumes(p)
+		Ω(err).Should(BeNil())
+		Ω(updated).Should(BeFalse())
+		Ω(p.Spec.Bookkeeper.Storage.LegacyVolumeMounts).Should(BeFalse())
+	})
+
+	It("should keep the new layout of bookies created with it", func() {
+		client = fake.NewFakeClient(p, ecs.MakeBookieStatefulSet(p))
+		r = &ReconcileECSCluster{client: client, scheme: s}
+		updated, err := r.detectLegacyBookieVolumes(p)
+		Ω(err).Should(BeNil())
+		Ω(updated).Should(BeFalse())
+		Ω(getCluster().Spec.Bookkeeper.Storage.LegacyVolumeMounts).Should(BeFalse())
+	})
+
+	It("should record the legacy layout of existing bookies", func() {
+		legacy := p.DeepCopy()
+		legacy.Spec.Bookkeeper.Storage.LegacyVolumeMounts = true
+		client = fake.NewFakeClient(p, ecs.MakeBookieStatefulSet(legacy))
+		r = &ReconcileECSCluster{client: client, scheme: s}
+		updated, err := r.detectLegacyBookieVolumes(p)
+		Ω(err).Should(BeNil())
+		Ω(updated).Should(BeTrue())
+		Ω(p.Spec.Bookkeeper.Storage.LegacyVolumeMounts).Should(BeTrue())
+		Ω(getCluster().Spec.Bookkeeper.Storage.LegacyVolumeMounts).Should(BeTrue())
+	})
+
+	It("should requeue the cluster once the legacy layout is recorded", func() {
+		legacy := p.DeepCopy()
+		legacy.Spec.Bookkeeper.Storage.LegacyVolumeMounts = true
+		client = fake.NewFakeClient(p, ecs.MakeBookieStatefulSet(legacy))
+		r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+		result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: p.Name, Namespace: p.Namespace}})
+		Ω(err).Should(BeNil())
+		Ω(result.Requeue).Should(BeTrue())
+		Ω(getCluster().Spec.Bookkeeper.Storage.LegacyVolumeMounts).Should(BeTrue())
+	})
+})
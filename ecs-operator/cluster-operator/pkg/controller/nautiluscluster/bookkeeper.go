@@ -0,0 +1,169 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	bookkeeperReasonZookeeperUnreachable = "ZookeeperUnreachable"
+	bookkeeperReasonZookeeperError       = "ZookeeperError"
+	bookkeeperReasonNotRegistered        = "BookiesNotRegistered"
+	bookkeeperReasonUnknownRegistered    = "UnknownBookiesRegistered"
+)
+
+// reconcileBookkeeperStatus compares the bookies registered in ZooKeeper with
+// the bookie pods, since a ready pod does not prove that its bookie joined the
+// cluster
+func (r *ReconcileECSCluster) reconcileBookkeeperStatus(p *ecsv1alpha1.ECSCluster) error {
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		p.Status.SetBookkeeperDegradedConditionUnknown(bookkeeperReasonZookeeperUnreachable,
+			"bookie registrations cannot be read")
+		return nil
+	}
+
+	podList := &corev1.PodList{}
+	listOps := &client.ListOptions{
+		Namespace:     p.Namespace,
+		LabelSelector: labels.SelectorFromSet(util.LabelsForBookie(p)),
+	}
+	err := r.client.List(context.TODO(), listOps, podList)
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	available, readOnly, err := r.zookeeper.GetBookieRegistrations(p)
+	if err != nil {
+		log.Printf("failed to get bookie registrations of ecs cluster (%s): %v", p.Name, err)
+		p.Status.SetBookkeeperDegradedConditionUnknown(bookkeeperReasonZookeeperError, err.Error())
+		return nil
+	}
+
+	err = r.countUnderReplicatedLedgers(p)
+	if err != nil {
+		log.Printf("failed to count under-replicated ledgers of ecs cluster (%s): %v", p.Name, err)
+		p.Status.SetBookkeeperDegradedConditionUnknown(bookkeeperReasonZookeeperError, err.Error())
+		return nil
+	}
+
+	useHostName := util.UsesHostNameAsBookieID(p)
+	registered := map[string]string{}
+	for _, id := range available {
+		registered[bookieKey(id, useHostName)] = id
+	}
+	for _, id := range readOnly {
+		registered[bookieKey(id, useHostName)] = id
+	}
+
+	var missing []string
+	podKeys := map[string]bool{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		key := bookieKeyForPod(pod, useHostName)
+		if key == "" {
+			continue
+		}
+		podKeys[key] = true
+		if _, ok := registered[key]; util.IsPodReady(pod) && !ok {
+			missing = append(missing, pod.Name)
+		}
+	}
+	sort.Strings(missing)
+
+	var unknown []string
+	for key, id := range registered {
+		if !podKeys[key] {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+
+	p.Status.Bookkeeper.RegisteredBookies = available
+	p.Status.Bookkeeper.ReadOnlyBookies = readOnly
+	p.Status.Bookkeeper.MissingBookies = missing
+
+	switch {
+	case len(missing) > 0:
+		p.Status.SetBookkeeperDegradedConditionTrue(bookkeeperReasonNotRegistered,
+			fmt.Sprintf("ready bookies are not registered: %s", strings.Join(missing, ", ")))
+	case len(unknown) > 0:
+		p.Status.SetBookkeeperDegradedConditionTrue(bookkeeperReasonUnknownRegistered,
+			fmt.Sprintf("registered bookies have no pod: %s", strings.Join(unknown, ", ")))
+	default:
+		p.Status.SetBookkeeperDegradedConditionFalse()
+	}
+	return nil
+}
+
+// countUnderReplicatedLedgers records the number of under-replicated ledgers
+// in the status, counting them again in ZooKeeper once the last count is
+// older than ledgerCheckInterval
+func (r *ReconcileECSCluster) countUnderReplicatedLedgers(p *ecsv1alpha1.ECSCluster) error {
+	if !isLedgerCheckDue(p) {
+		return nil
+	}
+
+	count, err := r.zookeeper.CountUnderReplicatedLedgers(p)
+	if err != nil {
+		return err
+	}
+
+	if p.Status.Bookkeeper == nil {
+		p.Status.Bookkeeper = &ecsv1alpha1.BookkeeperStatus{}
+	}
+	p.Status.Bookkeeper.UnderReplicatedLedgers = int32(count)
+	p.Status.Bookkeeper.LastLedgerCheckTime = time.Now().Format(time.RFC3339)
+	return nil
+}
+
+// bookieKey matches the ID of a registered bookie with its pod. Bookies that
+// use their hostname as ID register the fully qualified name of the pod, of
+// which only the pod name is kept
+func bookieKey(id string, useHostName bool) string {
+	if !useHostName {
+		return id
+	}
+
+	host, port := id, ""
+	if i := strings.LastIndex(id, ":"); i != -1 {
+		host, port = id[:i], id[i:]
+	}
+	if i := strings.Index(host, "."); i != -1 {
+		host = host[:i]
+	}
+	return host + port
+}
+
+// bookieKeyForPod returns the key of the bookie of a pod, or an empty string
+// if the pod has no IP yet and bookies register with their IP
+func bookieKeyForPod(pod *corev1.Pod, useHostName bool) string {
+	if useHostName {
+		return fmt.Sprintf("%s:%d", pod.Name, ecsv1alpha1.DefaultBookiePort)
+	}
+	if pod.Status.PodIP == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", pod.Status.PodIP, ecsv1alpha1.DefaultBookiePort)
+}
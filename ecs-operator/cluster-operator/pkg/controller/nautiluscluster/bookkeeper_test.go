@@ -0,0 +1,206 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bookkeeper status", func() {
+	var (
+		s  = scheme.Scheme
+		p  *v1alpha1.ECSCluster
+		r  *ReconcileECSCluster
+		zk *fakeZookeeper
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+		p.Status.SetZookeeperReachableConditionTrue()
+		zk = &fakeZookeeper{available: []string{"10.0.0.1:3181"}, readOnly: []string{"10.0.0.2:3181"}}
+	})
+
+	JustBeforeEach(func() {
+		var objects []runtime.Object
+		for i, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			objects = append(objects, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("example-bookie-%d", i),
+					Namespace: p.Namespace,
+					Labels:    util.LabelsForBookie(p),
+				},
+				Status: corev1.PodStatus{
+					PodIP: ip,
+					Conditions: []corev1.PodCondition{
+						{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+					},
+				},
+			})
+		}
+		r = &ReconcileECSCluster{client: fake.NewFakeClient(append(objects, p)...), scheme: s, zookeeper: zk}
+	})
+
+	degraded := func() *v1alpha1.ClusterCondition {
+		_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionBookkeeperDegraded)
+		Ω(condition).ShouldNot(BeNil())
+		return condition
+	}
+
+	Context("Registrations", func() {
+		It("should count read-only bookies as registered", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(p.Status.Bookkeeper.RegisteredBookies).Should(Equal([]string{"10.0.0.1:3181"}))
+			Ω(p.Status.Bookkeeper.ReadOnlyBookies).Should(Equal([]string{"10.0.0.2:3181"}))
+			Ω(p.Status.Bookkeeper.MissingBookies).Should(BeEmpty())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionFalse))
+		})
+
+		It("should report the ready bookies that are missing", func() {
+			zk.readOnly = nil
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(p.Status.Bookkeeper.MissingBookies).Should(Equal([]string{"example-bookie-1"}))
+			Ω(degraded().Status).Should(Equal(corev1.ConditionTrue))
+			Ω(degraded().Reason).Should(Equal(bookkeeperReasonNotRegistered))
+		})
+
+		It("should report the registered bookies that have no pod", func() {
+			zk.available = append(zk.available, "10.0.0.9:3181")
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionTrue))
+			Ω(degraded().Reason).Should(Equal(bookkeeperReasonUnknownRegistered))
+			Ω(degraded().Message).Should(ContainSubstring("10.0.0.9:3181"))
+		})
+
+		It("should report the registrations that cannot be read", func() {
+			zk.registrationsErr = fmt.Errorf("failed to list available bookies: connection lost")
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionUnknown))
+			Ω(degraded().Reason).Should(Equal(bookkeeperReasonZookeeperError))
+		})
+
+		It("should not read zookeeper while it is unreachable", func() {
+			p.Status.SetZookeeperReachableConditionFalse(zookeeperReasonUnreachable, "timed out")
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(zk.ledgerCounts).Should(Equal(0))
+			Ω(p.Status.Bookkeeper).Should(BeNil())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionUnknown))
+		})
+	})
+
+	Context("Hostname bookie IDs", func() {
+		BeforeEach(func() {
+			p.Spec.Bookkeeper.Options = map[string]string{"useHostNameAsBookieID": "true"}
+			zk.available = []string{"example-bookie-0.example-bookie-headless.default.svc.cluster.local:3181"}
+			zk.readOnly = []string{"example-bookie-1.example-bookie-headless.default.svc.cluster.local:3181"}
+		})
+
+		It("should match the registrations with the pod names", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(p.Status.Bookkeeper.MissingBookies).Should(BeEmpty())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionFalse))
+		})
+
+		It("should report the unknown registrations by their ID", func() {
+			zk.available = append(zk.available, "example-bookie-5.example-bookie-headless.default.svc.cluster.local:3181")
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(degraded().Reason).Should(Equal(bookkeeperReasonUnknownRegistered))
+			Ω(degraded().Message).Should(ContainSubstring("example-bookie-5.example-bookie-headless"))
+		})
+	})
+
+	Context("Under-replicated ledgers", func() {
+		BeforeEach(func() {
+			zk.underReplicated = 2
+		})
+
+		It("should report the count", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(p.Status.Bookkeeper.UnderReplicatedLedgers).Should(BeEquivalentTo(2))
+			Ω(p.Status.Bookkeeper.LastLedgerCheckTime).ShouldNot(BeEmpty())
+		})
+
+		It("should not count them again before the interval", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			zk.underReplicated = 0
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(zk.ledgerCounts).Should(Equal(1))
+			Ω(p.Status.Bookkeeper.UnderReplicatedLedgers).Should(BeEquivalentTo(2))
+		})
+
+		It("should count them again after the interval", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			p.Status.Bookkeeper.LastLedgerCheckTime = time.Now().Add(-ledgerCheckInterval).Format(time.RFC3339)
+			zk.underReplicated = 0
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(zk.ledgerCounts).Should(Equal(2))
+			Ω(p.Status.Bookkeeper.UnderReplicatedLedgers).Should(BeZero())
+		})
+
+		It("should report a count that fails", func() {
+			zk.underReplicatedErr = fmt.Errorf("failed to construct BFS tree: connection lost")
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			Ω(degraded().Status).Should(Equal(corev1.ConditionUnknown))
+		})
+	})
+
+	Context("Restart blocker", func() {
+		It("should hold the restart of a bookie", func() {
+			zk.underReplicated = 2
+			message, err := r.bookieRestartBlocker(p)
+			Ω(err).Should(BeNil())
+			Ω(message).Should(Equal("2 ledgers are under-replicated"))
+		})
+
+		It("should allow the restart of a bookie", func() {
+			message, err := r.bookieRestartBlocker(p)
+			Ω(err).Should(BeNil())
+			Ω(message).Should(BeEmpty())
+		})
+
+		It("should count the ledgers again instead of using the status", func() {
+			Ω(r.reconcileBookkeeperStatus(p)).Should(Succeed())
+			zk.underReplicated = 1
+			message, err := r.bookieRestartBlocker(p)
+			Ω(err).Should(BeNil())
+			Ω(message).Should(Equal("1 ledgers are under-replicated"))
+			Ω(zk.ledgerCounts).Should(Equal(2))
+			Ω(p.Status.Bookkeeper.UnderReplicatedLedgers).Should(BeZero())
+		})
+
+		It("should hold the restart while zookeeper is unreachable", func() {
+			p.Status.SetZookeeperReachableConditionFalse(zookeeperReasonUnreachable, "timed out")
+			message, err := r.bookieRestartBlocker(p)
+			Ω(err).Should(BeNil())
+			Ω(message).ShouldNot(BeEmpty())
+			Ω(zk.ledgerCounts).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Cluster status warnings", func() {
+	var (
+		p   *v1alpha1.ECSCluster
+		out *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.WithDefaults()
+		out = &bytes.Buffer{}
+		log.SetOutput(out)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	Context("Ephemeral storage", func() {
+		It("should be false with persistent storage", func() {
+			reconcileEphemeralStorageStatus(p)
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionEphemeralStorage)).Should(BeFalse())
+			Ω(out.String()).Should(BeEmpty())
+		})
+
+		It("should warn once while the storage stays ephemeral", func() {
+			p.Spec.Bookkeeper.Storage.Ephemeral = true
+			reconcileEphemeralStorageStatus(p)
+			reconcileEphemeralStorageStatus(p)
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionEphemeralStorage)).Should(BeTrue())
+			Ω(strings.Count(out.String(), "emptyDir")).Should(Equal(1))
+		})
+
+		It("should warn again when the ephemeral components change", func() {
+			p.Spec.Bookkeeper.Storage.Ephemeral = true
+			reconcileEphemeralStorageStatus(p)
+			p.Spec.ECS.EphemeralCache = true
+			reconcileEphemeralStorageStatus(p)
+			Ω(strings.Count(out.String(), "emptyDir")).Should(Equal(2))
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionEphemeralStorage)
+			Ω(condition.Message).Should(ContainSubstring("node cache"))
+		})
+	})
+
+	Context("JVM memory", func() {
+		BeforeEach(func() {
+			p.Spec.Bookkeeper.Resources = &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			}
+		})
+
+		It("should be false with the generated options", func() {
+			reconcileJVMMemoryStatus(p)
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionJVMMemoryExceeded)).Should(BeFalse())
+		})
+
+		It("should warn once while the options exceed the limit", func() {
+			p.Spec.Bookkeeper.JVMOptions = &v1alpha1.JVMOptions{Mode: v1alpha1.JVMOptionsAppend, Options: []string{"-Xmx8g"}}
+			reconcileJVMMemoryStatus(p)
+			reconcileJVMMemoryStatus(p)
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionJVMMemoryExceeded)).Should(BeTrue())
+			Ω(strings.Count(out.String(), "may be OOM killed")).Should(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,181 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// controllerCheckInterval is the minimum delay between two queries of the
+	// REST API of the controllers of a cluster
+	controllerCheckInterval = 30 * time.Second
+
+	healthReasonControllerNotReady    = "ControllerNotReady"
+	healthReasonControllerUnreachable = "ControllerUnreachable"
+	healthReasonControllerUnhealthy   = "ControllerUnhealthy"
+	healthReasonSegmentStoresMissing  = "SegmentStoresMissing"
+	healthReasonContainersUnassigned  = "ContainersUnassigned"
+
+	// containerCountOption is the ECS option setting the number of segment
+	// containers of the cluster
+	containerCountOption = "ecsservice.containerCount"
+)
+
+// reconcileControllerStatus queries the REST API of the controllers once at
+// least one of them is ready
+func (r *ReconcileECSCluster) reconcileControllerStatus(p *ecsv1alpha1.ECSCluster) error {
+	deploy := &appsv1.Deployment{}
+	name := util.DeploymentNameForController(p.Name)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deploy)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get deployment (%s): %v", name, err)
+	}
+	if err != nil || deploy.Status.ReadyReplicas == 0 {
+		p.Status.SetHealthyConditionFalse(healthReasonControllerNotReady, "no controller is ready")
+		// The controllers are queried as soon as one is ready again
+		if p.Status.Controller != nil {
+			p.Status.Controller.LastCheckTime = ""
+		}
+		return nil
+	}
+
+	reconcileControllerHealth(p, util.NewControllerClient(util.ECSControllerRESTURL(*p)))
+	return nil
+}
+
+// reconcileControllerHealth records the health, the segment stores and the
+// container assignment reported by the controllers, and sets the Healthy
+// condition accordingly
+func reconcileControllerHealth(p *ecsv1alpha1.ECSCluster, controllerClient *util.ControllerClient) {
+	if !isControllerCheckDue(p) {
+		return
+	}
+
+	health, segmentStores, assignment, err := getControllerState(controllerClient)
+	if err != nil {
+		log.Printf("failed to get controller status of ecs cluster (%s): %v", p.Name, err)
+		p.Status.SetHealthyConditionFalse(healthReasonControllerUnreachable, err.Error())
+		if p.Status.Controller == nil {
+			p.Status.Controller = &ecsv1alpha1.ControllerStatus{}
+		}
+		p.Status.Controller.LastCheckTime = time.Now().Format(time.RFC3339)
+		return
+	}
+	setControllerStatus(p, health, segmentStores, assignment)
+}
+
+// isControllerCheckDue rate-limits the queries of the controllers of a
+// cluster, which make several requests with long timeouts
+func isControllerCheckDue(p *ecsv1alpha1.ECSCluster) bool {
+	status := p.Status.Controller
+	if status == nil {
+		return true
+	}
+
+	if _, c := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionHealthy); c == nil {
+		return true
+	}
+
+	lastCheckTime, err := time.Parse(time.RFC3339, status.LastCheckTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastCheckTime) >= controllerCheckInterval
+}
+
+func getControllerState(controllerClient *util.ControllerClient) (*util.ControllerHealth, []string, map[string][]int32, error) {
+	health, err := controllerClient.Health()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	segmentStores, err := controllerClient.SegmentStores()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	assignment, err := controllerClient.ContainerAssignment()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return health, segmentStores, assignment, nil
+}
+
+func setControllerStatus(p *ecsv1alpha1.ECSCluster, health *util.ControllerHealth, segmentStores []string, assignment map[string][]int32) {
+	status := &ecsv1alpha1.ControllerStatus{
+		Health:        health.Status,
+		SegmentStores: segmentStores,
+		LastCheckTime: time.Now().Format(time.RFC3339),
+	}
+
+	registered := map[string]bool{}
+	for _, segmentStore := range segmentStores {
+		registered[segmentStore] = true
+	}
+
+	assignedStores := make([]string, 0, len(assignment))
+	for segmentStore := range assignment {
+		assignedStores = append(assignedStores, segmentStore)
+	}
+	sort.Strings(assignedStores)
+
+	var unregistered []string
+	assigned := map[int32]bool{}
+	for _, segmentStore := range assignedStores {
+		containers := assignment[segmentStore]
+		status.ContainerAssignment = append(status.ContainerAssignment, ecsv1alpha1.SegmentStoreContainers{
+			SegmentStore: segmentStore,
+			Containers:   int32(len(containers)),
+		})
+		if len(containers) > 0 && !registered[segmentStore] {
+			unregistered = append(unregistered, segmentStore)
+		}
+		for _, container := range containers {
+			assigned[container] = true
+		}
+	}
+	p.Status.Controller = status
+
+	// The number of containers is only known when it is configured
+	containerCount, _ := strconv.Atoi(p.Spec.ECS.Options[containerCountOption])
+
+	switch {
+	case health.Status != util.ControllerHealthUp:
+		p.Status.SetHealthyConditionFalse(healthReasonControllerUnhealthy,
+			fmt.Sprintf("controller health is %s", health.Status))
+	case int32(len(segmentStores)) < p.Spec.ECS.NodeReplicas:
+		p.Status.SetHealthyConditionFalse(healthReasonSegmentStoresMissing,
+			fmt.Sprintf("%d of %d segment stores are registered", len(segmentStores), p.Spec.ECS.NodeReplicas))
+	case len(unregistered) > 0:
+		p.Status.SetHealthyConditionFalse(healthReasonContainersUnassigned,
+			fmt.Sprintf("segment containers are assigned to unregistered segment stores: %s", strings.Join(unregistered, ", ")))
+	case len(assigned) < containerCount:
+		p.Status.SetHealthyConditionFalse(healthReasonContainersUnassigned,
+			fmt.Sprintf("%d of %d segment containers are assigned", len(assigned), containerCount))
+	default:
+		p.Status.SetHealthyConditionTrue()
+	}
+}
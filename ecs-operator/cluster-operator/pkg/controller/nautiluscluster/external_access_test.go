@@ -0,0 +1,107 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("External node services", func() {
+	const (
+		cloudAnnotation       = "cloud.example.com/load-balancer-id"
+		awsTypeAnnotation     = "service.beta.kubernetes.io/aws-load-balancer-type"
+		awsInternalAnnotation = "service.beta.kubernetes.io/aws-load-balancer-internal"
+	)
+
+	var (
+		p       *v1alpha1.ECSCluster
+		current *corev1.Service
+		changed bool
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		p.Spec.ExternalAccess = &v1alpha1.ExternalAccess{
+			Enabled: true,
+			Annotations: map[string]string{
+				awsTypeAnnotation:     "nlb",
+				awsInternalAnnotation: "true",
+			},
+		}
+		p.WithDefaults()
+		current = ecs.MakeNodeExternalService(p, 0)
+		current.Annotations[cloudAnnotation] = "lb-1"
+	})
+
+	Context("Unchanged spec", func() {
+		It("should not update the service", func() {
+			changed = syncNodeExternalService(current, ecs.MakeNodeExternalService(p, 0))
+			Ω(changed).Should(BeFalse())
+			Ω(current.Annotations).Should(HaveKeyWithValue(cloudAnnotation, "lb-1"))
+		})
+	})
+
+	Context("Removed annotation", func() {
+		BeforeEach(func() {
+			delete(p.Spec.ExternalAccess.Annotations, awsInternalAnnotation)
+			p.Spec.ExternalAccess.Annotations[awsTypeAnnotation] = "external"
+			changed = syncNodeExternalService(current, ecs.MakeNodeExternalService(p, 0))
+		})
+
+		It("should only update the managed annotations", func() {
+			Ω(changed).Should(BeTrue())
+			Ω(current.Annotations).Should(HaveKeyWithValue(cloudAnnotation, "lb-1"))
+			Ω(current.Annotations).Should(HaveKeyWithValue(awsTypeAnnotation, "external"))
+			Ω(current.Annotations).ShouldNot(HaveKey(awsInternalAnnotation))
+			Ω(current.Annotations).Should(HaveKeyWithValue(util.ManagedAnnotationsAnnotation, awsTypeAnnotation))
+		})
+	})
+
+	Context("Changed source ranges and node ports", func() {
+		BeforeEach(func() {
+			p.Spec.ExternalAccess.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+			changed = syncNodeExternalService(current, ecs.MakeNodeExternalService(p, 0))
+		})
+
+		It("should update the source ranges", func() {
+			Ω(changed).Should(BeTrue())
+			Ω(current.Spec.LoadBalancerSourceRanges).Should(Equal([]string{"10.0.0.0/8"}))
+		})
+
+		It("should keep the node port assigned by Kubernetes", func() {
+			current.Spec.Ports[0].NodePort = 31000
+			syncNodeExternalService(current, ecs.MakeNodeExternalService(p, 0))
+			Ω(current.Spec.Ports[0].NodePort).Should(BeEquivalentTo(31000))
+		})
+
+		It("should set the node port of the base", func() {
+			p.Spec.ExternalAccess.Type = corev1.ServiceTypeNodePort
+			p.Spec.ExternalAccess.NodePortBase = 30100
+			changed = syncNodeExternalService(current, ecs.MakeNodeExternalService(p, 0))
+			Ω(changed).Should(BeTrue())
+			Ω(current.Spec.Type).Should(Equal(corev1.ServiceTypeNodePort))
+			Ω(current.Spec.Ports[0].NodePort).Should(BeEquivalentTo(30100))
+		})
+	})
+})
@@ -0,0 +1,211 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Maintenance", func() {
+	var (
+		s       = scheme.Scheme
+		p       *v1alpha1.ECSCluster
+		objects []runtime.Object
+		r       *ReconcileECSCluster
+		client  client.Client
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+		p.Spec.Maintenance = &v1alpha1.MaintenanceSpec{
+			BookieOrdinals: []int32{1},
+		}
+		objects = nil
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(append(objects, p)...)
+		r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+	})
+
+	Context("Cordoned ordinals", func() {
+		It("should not scale below a cordoned ordinal", func() {
+			Ω(keepCordonedOrdinals("example-bookie", 2, []int32{3})).Should(BeEquivalentTo(4))
+		})
+
+		It("should scale down when no cordoned ordinal is removed", func() {
+			Ω(keepCordonedOrdinals("example-bookie", 2, []int32{0, 1})).Should(BeEquivalentTo(2))
+		})
+	})
+
+	Context("Pods", func() {
+		BeforeEach(func() {
+			for _, name := range []string{"example-bookie-0", "example-bookie-1"} {
+				labels := util.ServiceLabelsForBookie(p)
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: p.Namespace, Labels: labels},
+				})
+			}
+		})
+
+		getPod := func(name string) *corev1.Pod {
+			pod := &corev1.Pod{}
+			Ω(client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, pod)).Should(Succeed())
+			return pod
+		}
+
+		It("should take the cordoned pods out of service", func() {
+			Ω(r.reconcileMaintenance(p)).Should(Succeed())
+			Ω(getPod("example-bookie-0").Labels[util.InServiceLabel]).Should(Equal("true"))
+			Ω(getPod("example-bookie-1").Labels[util.InServiceLabel]).Should(Equal("false"))
+		})
+
+		It("should put the pods back in service", func() {
+			p.Spec.Maintenance = nil
+			Ω(r.reconcileMaintenance(p)).Should(Succeed())
+			Ω(getPod("example-bookie-1").Labels[util.InServiceLabel]).Should(Equal("true"))
+		})
+	})
+
+	Context("Governing services", func() {
+		BeforeEach(func() {
+			// Selected in-service pods only before cordoned pods kept their
+			// DNS names
+			service := ecs.MakeBookieHeadlessService(p)
+			service.Spec.Selector = util.ServiceLabelsForBookie(p)
+			objects = append(objects, service, ecs.MakeBookiePodDisruptionBudget(p))
+		})
+
+		It("should select the cordoned pods", func() {
+			Ω(ecs.MakeBookieHeadlessService(p).Spec.Selector).ShouldNot(HaveKey(util.InServiceLabel))
+			Ω(ecs.MakeNodeHeadlessService(p).Spec.Selector).ShouldNot(HaveKey(util.InServiceLabel))
+		})
+
+		It("should update the selector of existing services", func() {
+			Ω(r.reconcileMaintenance(p)).Should(Succeed())
+			service := &corev1.Service{}
+			Ω(client.Get(context.TODO(), types.NamespacedName{Name: util.HeadlessServiceNameForBookie(p.Name), Namespace: p.Namespace}, service)).Should(Succeed())
+			Ω(service.Spec.Selector).Should(Equal(util.LabelsForBookie(p)))
+		})
+
+		It("should keep the cordoned pods out of the pod disruption budgets", func() {
+			Ω(r.reconcileMaintenance(p)).Should(Succeed())
+			pdb := &policyv1beta1.PodDisruptionBudget{}
+			Ω(client.Get(context.TODO(), types.NamespacedName{Name: util.PdbNameForBookie(p.Name), Namespace: p.Namespace}, pdb)).Should(Succeed())
+			Ω(pdb.Spec.Selector.MatchLabels).Should(HaveKeyWithValue(util.InServiceLabel, "true"))
+		})
+	})
+})
+
+var _ = Describe("Paused cluster", func() {
+	var (
+		s      = scheme.Scheme
+		p      *v1alpha1.ECSCluster
+		sts    *appsv1.StatefulSet
+		r      *ReconcileECSCluster
+		client client.Client
+		req    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "example",
+				Namespace:  "default",
+				Finalizers: []string{util.ZkFinalizer},
+			},
+			Spec: v1alpha1.ClusterSpec{
+				// Its metadata is deleted along with its volumes, without
+				// connecting to ZooKeeper
+				Zookeeper: &v1alpha1.ZookeeperSpec{},
+				Paused:    true,
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+
+		replicas := int32(1)
+		sts = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: util.StatefulSetNameForBookie(p.Name), Namespace: p.Namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Name: p.Name, Namespace: p.Namespace}}
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(p, sts)
+		r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+	})
+
+	getCluster := func() *v1alpha1.ECSCluster {
+		found := &v1alpha1.ECSCluster{}
+		Ω(client.Get(context.TODO(), req.NamespacedName, found)).Should(Succeed())
+		return found
+	}
+
+	It("should not change the resources", func() {
+		_, err := r.Reconcile(req)
+		Ω(err).Should(BeNil())
+
+		found := &appsv1.StatefulSet{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: sts.Name, Namespace: sts.Namespace}, found)).Should(Succeed())
+		Ω(*found.Spec.Replicas).Should(BeEquivalentTo(1))
+		Ω(getCluster().Status.IsClusterConditionTrue(v1alpha1.ClusterConditionReconciliationPaused)).Should(BeTrue())
+	})
+
+	Context("Deletion", func() {
+		BeforeEach(func() {
+			now := metav1.Now()
+			p.DeletionTimestamp = &now
+		})
+
+		It("should remove the finalizer", func() {
+			_, err := r.Reconcile(req)
+			Ω(err).Should(BeNil())
+			Ω(getCluster().Finalizers).ShouldNot(ContainElement(util.ZkFinalizer))
+		})
+
+		It("should remove the finalizer of an invalid cluster", func() {
+			p.Spec.Paused = false
+			p.Spec.ECS.Tier2.S3 = &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"}
+			Ω(p.Validate()).ShouldNot(BeNil())
+
+			_, err := r.Reconcile(req)
+			Ω(err).Should(BeNil())
+			Ω(getCluster().Finalizers).ShouldNot(ContainElement(util.ZkFinalizer))
+		})
+	})
+})
@@ -13,14 +13,20 @@ package ecscluster
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
 	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/health"
 	"github.com/ecs/ecs-operator/pkg/util"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
@@ -38,7 +44,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// ReconcileTime is the delay between reconciliations
+// ReconcileTime is the delay between reconciliations while waiting for a
+// dependency that is not watched, such as Tier 2
 const ReconcileTime = 30 * time.Second
 
 // Add creates a new ECSCluster Controller and adds it to the Manager. The Manager will set fields on the Controller
@@ -49,13 +56,21 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileECSCluster{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileECSCluster{
+		client:         mgr.GetClient(),
+		scheme:         mgr.GetScheme(),
+		probeZookeeper: util.ProbeZookeeper,
+		zookeeper:      utilZookeeperReader{},
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	// Create a new controller
-	c, err := controller.New("ecscluster-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("ecscluster-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: controllerconfig.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -66,9 +81,47 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to secondary resources and requeue the owner ECSCluster
+	owned := []runtime.Object{
+		&appsv1.StatefulSet{},
+		&appsv1.Deployment{},
+		&corev1.Service{},
+		&corev1.ConfigMap{},
+		&policyv1beta1.PodDisruptionBudget{},
+		&networkingv1.NetworkPolicy{},
+	}
+	for _, t := range owned {
+		err = c.Watch(&source.Kind{Type: t}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &ecsv1alpha1.ECSCluster{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pods are owned by the StatefulSets and ReplicaSets, so they are mapped
+	// to their ECSCluster through the cluster labels
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(requestsForPod),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func requestsForPod(o handler.MapObject) []reconcile.Request {
+	name, ok := util.ClusterNameForLabels(o.Meta.GetLabels())
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: o.Meta.GetNamespace()}},
+	}
+}
+
 var _ reconcile.Reconciler = &ReconcileECSCluster{}
 
 // ReconcileECSCluster reconciles a ECSCluster object
@@ -77,6 +130,11 @@ type ReconcileECSCluster struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+
+	// probeZookeeper is util.ProbeZookeeper, replaced in the tests
+	probeZookeeper func(p *ecsv1alpha1.ECSCluster) (time.Duration, bool, error)
+	// zookeeper reads the BookKeeper metadata, replaced in the tests
+	zookeeper zookeeperReader
 }
 
 // Reconcile reads that state of the cluster for a ECSCluster object and makes changes based on the state read
@@ -84,12 +142,27 @@ type ReconcileECSCluster struct {
 // Note:
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
-func (r *ReconcileECSCluster) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+func (r *ReconcileECSCluster) Reconcile(request reconcile.Request) (result reconcile.Result, err error) {
 	log.Printf("Reconciling ECSCluster %s/%s\n", request.Namespace, request.Name)
 
+	health.ReconcileStarted(request.String())
+	defer func() {
+		health.ReconcileFinished(request.String(), err)
+	}()
+
+	// A single operator serves clusters from several namespaces. Turn a panic
+	// into a failed reconciliation so one bad cluster cannot take the others down
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Errorf("panic while reconciling ECSCluster %s/%s: %v", request.Namespace, request.Name, rec)
+			result = reconcile.Result{}
+			err = fmt.Errorf("panic while reconciling ecs cluster (%s): %v", request.Name, rec)
+		}
+	}()
+
 	// Fetch the ECSCluster instance
 	ecsCluster := &ecsv1alpha1.ECSCluster{}
-	err := r.client.Get(context.TODO(), request.NamespacedName, ecsCluster)
+	err = r.client.Get(context.TODO(), request.NamespacedName, ecsCluster)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
@@ -103,6 +176,41 @@ func (r *ReconcileECSCluster) Reconcile(request reconcile.Request) (reconcile.Re
 		return reconcile.Result{}, err
 	}
 
+	// The finalizer of a deleted cluster is run whatever its spec, even while
+	// paused, or its deletion would hang
+	if !ecsCluster.DeletionTimestamp.IsZero() {
+		err = r.reconcileFinalizers(ecsCluster)
+		if err != nil {
+			log.Printf("failed to clean up zookeeper: %v", err)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+
+	// Only the status of a paused cluster is updated, so that manual changes
+	// are not reverted
+	if util.IsPaused(ecsCluster) {
+		err = r.reconcilePaused(ecsCluster)
+		if err != nil {
+			log.Printf("failed to reconcile paused ecs cluster (%s): %v", ecsCluster.Name, err)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: controllerconfig.ResyncPeriod}, nil
+	}
+
+	// Conflicting settings are reported instead of being resolved by the
+	// defaults. The cluster is reconciled again once its spec is updated
+	err = ecsCluster.Validate()
+	if err != nil {
+		log.Printf("invalid spec of ecs cluster (%s): %v", ecsCluster.Name, err)
+		ecsCluster.Status.SetSpecValidConditionFalse(err.Error())
+		err = r.client.Status().Update(context.TODO(), ecsCluster)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to update cluster status: %v", err)
+		}
+		return reconcile.Result{}, nil
+	}
+
 	// Set default configuration for unspecified values
 	changed := ecsCluster.WithDefaults()
 	if changed {
@@ -113,13 +221,35 @@ func (r *ReconcileECSCluster) Reconcile(request reconcile.Request) (reconcile.Re
 		return reconcile.Result{Requeue: true}, nil
 	}
 
+	// Updating the spec reloads the cluster and drops the status computed so
+	// far, so the cluster is reconciled again with the recorded layout
+	changed, err = r.detectLegacyBookieVolumes(ecsCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changed {
+		return reconcile.Result{Requeue: true}, nil
+	}
+
+	ecsCluster.Status.SetReconciliationPausedConditionFalse()
+	ecsCluster.Status.SetSpecValidConditionTrue()
+
 	err = r.run(ecsCluster)
 	if err != nil {
 		log.Printf("failed to reconcile ecs cluster (%s): %v", ecsCluster.Name, err)
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+	// Tier 2 and ZooKeeper are not Kubernetes resources, so their recovery is
+	// not watched
+	if !ecsCluster.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionTier2Reachable) ||
+		!ecsCluster.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+	}
+
+	// Changes to the cluster and its resources are watched. The periodic
+	// resync is only a safety net
+	return reconcile.Result{RequeueAfter: controllerconfig.ResyncPeriod}, nil
 }
 
 func (r *ReconcileECSCluster) run(p *ecsv1alpha1.ECSCluster) (err error) {
@@ -130,6 +260,38 @@ func (r *ReconcileECSCluster) run(p *ecsv1alpha1.ECSCluster) (err error) {
 		return err
 	}
 
+	err = r.reconcileQuiesce(p)
+	if err != nil {
+		log.Printf("failed to reconcile quiesce: %v", err)
+		return err
+	}
+
+	quorum, err := r.deployZookeeper(p)
+	if err != nil {
+		log.Printf("failed to deploy zookeeper: %v", err)
+		return err
+	}
+	if !quorum {
+		log.Printf("waiting for the zookeeper quorum of ecs cluster (%s)", p.Name)
+		return r.reconcileClusterStatus(p)
+	}
+
+	err = r.deployTier2(p)
+	if err != nil {
+		log.Printf("failed to deploy tier2: %v", err)
+		return err
+	}
+
+	// Validate Tier 2 before rolling out nodes that depend on it
+	r.reconcileTier2(p)
+
+	// Probe ZooKeeper before rolling out bookies and nodes that depend on it
+	err = r.reconcileZookeeper(p)
+	if err != nil {
+		log.Printf("failed to reconcile zookeeper: %v", err)
+		return err
+	}
+
 	err = r.deployCluster(p)
 	if err != nil {
 		log.Printf("failed to deploy cluster: %v", err)
@@ -142,6 +304,18 @@ func (r *ReconcileECSCluster) run(p *ecsv1alpha1.ECSCluster) (err error) {
 		return err
 	}
 
+	err = r.reconcileMaintenance(p)
+	if err != nil {
+		log.Printf("failed to reconcile maintenance: %v", err)
+		return err
+	}
+
+	err = r.reconcileRestarts(p)
+	if err != nil {
+		log.Printf("failed to reconcile restarts: %v", err)
+		return err
+	}
+
 	err = r.reconcileClusterStatus(p)
 	if err != nil {
 		log.Printf("failed to reconcile cluster status: %v", err)
@@ -150,10 +324,25 @@ func (r *ReconcileECSCluster) run(p *ecsv1alpha1.ECSCluster) (err error) {
 	return nil
 }
 
+// reconcilePaused only updates the status of the cluster. The defaults are
+// applied in memory so that the status can be computed, but are not saved
+func (r *ReconcileECSCluster) reconcilePaused(p *ecsv1alpha1.ECSCluster) error {
+	log.Printf("reconciliation of ecs cluster (%s) is paused", p.Name)
+	p.WithDefaults()
+
+	if p.Spec.Paused {
+		p.Status.SetReconciliationPausedConditionTrue("SpecPaused", "spec.paused is true")
+	} else {
+		p.Status.SetReconciliationPausedConditionTrue("AnnotationPaused",
+			fmt.Sprintf("annotation %s is true", util.PausedAnnotation))
+	}
+	return r.reconcileClusterStatus(p)
+}
+
 func (r *ReconcileECSCluster) deployCluster(p *ecsv1alpha1.ECSCluster) (err error) {
-	err = r.deployBookie(p)
+	err = r.deployNetworkPolicies(p)
 	if err != nil {
-		log.Printf("failed to deploy bookie: %v", err)
+		log.Printf("failed to deploy network policies: %v", err)
 		return err
 	}
 
@@ -163,6 +352,29 @@ func (r *ReconcileECSCluster) deployCluster(p *ecsv1alpha1.ECSCluster) (err erro
 		return err
 	}
 
+	// Bookies and nodes would wait for ZooKeeper on startup
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		log.Printf("skipping bookie and node deployment of ecs cluster (%s) until zookeeper is reachable", p.Name)
+		return nil
+	}
+
+	err = r.deployBookie(p)
+	if err != nil {
+		log.Printf("failed to deploy bookie: %v", err)
+		return err
+	}
+
+	err = r.deployAutoRecovery(p)
+	if err != nil {
+		log.Printf("failed to deploy autorecovery: %v", err)
+		return err
+	}
+
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionTier2Reachable) {
+		log.Printf("skipping node deployment of ecs cluster (%s) until tier2 is reachable", p.Name)
+		return nil
+	}
+
 	err = r.deployNode(p)
 	if err != nil {
 		log.Printf("failed to deploy segment store: %v", err)
@@ -171,6 +383,209 @@ func (r *ReconcileECSCluster) deployCluster(p *ecsv1alpha1.ECSCluster) (err erro
 	return nil
 }
 
+// deployNetworkPolicies creates the NetworkPolicies of the cluster and keeps
+// them in sync with its spec, or deletes them when they are disabled
+func (r *ReconcileECSCluster) deployNetworkPolicies(p *ecsv1alpha1.ECSCluster) (err error) {
+	if !p.Spec.NetworkPolicy.IsEnabled() {
+		return r.deleteNetworkPolicies(p)
+	}
+
+	for _, desired := range ecs.MakeNetworkPolicies(p) {
+		current := &networkingv1.NetworkPolicy{}
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get network policy (%s): %v", desired.Name, err)
+		}
+
+		if errors.IsNotFound(err) {
+			controllerutil.SetControllerReference(p, desired, r.scheme)
+			err = r.client.Create(context.TODO(), desired)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create network policy (%s): %v", desired.Name, err)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(current.Spec, desired.Spec) {
+			current.Spec = desired.Spec
+			err = r.client.Update(context.TODO(), current)
+			if err != nil {
+				return fmt.Errorf("failed to update network policy (%s): %v", current.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ReconcileECSCluster) deleteNetworkPolicies(p *ecsv1alpha1.ECSCluster) (err error) {
+	names := []string{
+		util.NetworkPolicyNameForBookie(p.Name),
+		util.NetworkPolicyNameForController(p.Name),
+		util.NetworkPolicyNameForNode(p.Name),
+	}
+	for _, name := range names {
+		policy := &networkingv1.NetworkPolicy{}
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, policy)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get network policy (%s): %v", name, err)
+		}
+		err = r.client.Delete(context.TODO(), policy)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete network policy (%s): %v", name, err)
+		}
+	}
+	return nil
+}
+
+// deployZookeeper creates the embedded ZooKeeper ensemble, if any, rolls out
+// the changes of its section and returns whether a quorum of its servers is
+// ready. The ensemble is kept when its section is removed, since the cluster
+// metadata lives in it
+func (r *ReconcileECSCluster) deployZookeeper(p *ecsv1alpha1.ECSCluster) (quorum bool, err error) {
+	if p.Spec.Zookeeper == nil {
+		return true, nil
+	}
+
+	headlessService := ecs.MakeZookeeperHeadlessService(p)
+	controllerutil.SetControllerReference(p, headlessService, r.scheme)
+	err = r.client.Create(context.TODO(), headlessService)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	clientService := ecs.MakeZookeeperClientService(p)
+	controllerutil.SetControllerReference(p, clientService, r.scheme)
+	err = r.client.Create(context.TODO(), clientService)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	pdb := ecs.MakeZookeeperPodDisruptionBudget(p)
+	controllerutil.SetControllerReference(p, pdb, r.scheme)
+	err = r.client.Create(context.TODO(), pdb)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	statefulSet := ecs.MakeZookeeperStatefulSet(p)
+	controllerutil.SetControllerReference(p, statefulSet, r.scheme)
+	for i := range statefulSet.Spec.VolumeClaimTemplates {
+		controllerutil.SetControllerReference(p, &statefulSet.Spec.VolumeClaimTemplates[i], r.scheme)
+	}
+	err = r.client.Create(context.TODO(), statefulSet)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	sts := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: statefulSet.Name, Namespace: p.Namespace}, sts)
+	if err != nil {
+		return false, fmt.Errorf("failed to get stateful-set (%s): %v", statefulSet.Name, err)
+	}
+
+	err = r.syncZookeeper(sts, statefulSet)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.syncPodDisruptionBudget(p, ecs.MakeZookeeperPodDisruptionBudget(p))
+	if err != nil {
+		return false, err
+	}
+
+	// The quorum is that of the servers of the stateful-set, which the
+	// readiness of each server is reported against
+	return sts.Status.ReadyReplicas >= *sts.Spec.Replicas/2+1, nil
+}
+
+// syncZookeeper rolls out the replicas, image, resources and security of the
+// zookeeper section to the ensemble. The servers are listed in the static
+// configuration of each server, so a change of replicas restarts every
+// server, one at a time, with the new list. The volumes of removed servers
+// are deleted
+func (r *ReconcileECSCluster) syncZookeeper(current *appsv1.StatefulSet, desired *appsv1.StatefulSet) (err error) {
+	if !zookeeperChanged(current, desired) {
+		return nil
+	}
+
+	log.Printf("rolling out the zookeeper spec to stateful-set (%s)", current.Name)
+	resized := *current.Spec.Replicas != *desired.Spec.Replicas
+	current.Spec.Replicas = desired.Spec.Replicas
+	current.Spec.Template.Spec = desired.Spec.Template.Spec
+	if current.Spec.Template.Annotations == nil {
+		current.Spec.Template.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Spec.Template.Annotations {
+		current.Spec.Template.Annotations[k] = v
+	}
+	err = r.client.Update(context.TODO(), current)
+	if err != nil {
+		return fmt.Errorf("failed to update stateful-set (%s): %v", current.Name, err)
+	}
+
+	// Removed servers must not rejoin later with stale data
+	if resized {
+		err = r.syncStatefulSetPvc(current)
+		if err != nil {
+			return fmt.Errorf("failed to sync pvcs of stateful-set (%s): %v", current.Name, err)
+		}
+	}
+	return nil
+}
+
+// zookeeperChanged returns true if the ensemble differs from the zookeeper
+// section. Only the fields derived from the section are compared, since the
+// API server fills in the defaults of the others
+func zookeeperChanged(current *appsv1.StatefulSet, desired *appsv1.StatefulSet) bool {
+	if *current.Spec.Replicas != *desired.Spec.Replicas {
+		return true
+	}
+
+	currentPod := &current.Spec.Template
+	desiredPod := &desired.Spec.Template
+	if len(currentPod.Spec.Containers) != len(desiredPod.Spec.Containers) {
+		return true
+	}
+	for k, v := range desiredPod.Annotations {
+		if currentPod.Annotations[k] != v {
+			return true
+		}
+	}
+	if !reflect.DeepEqual(currentPod.Spec.SecurityContext, desiredPod.Spec.SecurityContext) {
+		return true
+	}
+
+	for i := range desiredPod.Spec.Containers {
+		c, d := &currentPod.Spec.Containers[i], &desiredPod.Spec.Containers[i]
+		if c.Image != d.Image || c.ImagePullPolicy != d.ImagePullPolicy ||
+			!reflect.DeepEqual(c.Env, d.Env) ||
+			!reflect.DeepEqual(c.SecurityContext, d.SecurityContext) ||
+			!sameResourceList(c.Resources.Requests, d.Resources.Requests) ||
+			!sameResourceList(c.Resources.Limits, d.Resources.Limits) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameResourceList compares resource quantities by value, since a quantity
+// read from the API server may be formatted differently
+func sameResourceList(a corev1.ResourceList, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *ReconcileECSCluster) deployController(p *ecsv1alpha1.ECSCluster) (err error) {
 	pdb := ecs.MakeControllerPodDisruptionBudget(p)
 	controllerutil.SetControllerReference(p, pdb, r.scheme)
@@ -212,15 +627,9 @@ func (r *ReconcileECSCluster) deployNode(p *ecsv1alpha1.ECSCluster) (err error)
 		return err
 	}
 
-	if p.Spec.ExternalAccess.Enabled {
-		services := ecs.MakeNodeExternalServices(p)
-		for _, service := range services {
-			controllerutil.SetControllerReference(p, service, r.scheme)
-			err = r.client.Create(context.TODO(), service)
-			if err != nil && !errors.IsAlreadyExists(err) {
-				return err
-			}
-		}
+	err = r.syncNodeExternalServices(p)
+	if err != nil {
+		return err
 	}
 
 	pdb := ecs.MakeNodePodDisruptionBudget(p)
@@ -230,10 +639,8 @@ func (r *ReconcileECSCluster) deployNode(p *ecsv1alpha1.ECSCluster) (err error)
 		return err
 	}
 
-	configMap := ecs.MakeNodeConfigMap(p)
-	controllerutil.SetControllerReference(p, configMap, r.scheme)
-	err = r.client.Create(context.TODO(), configMap)
-	if err != nil && !errors.IsAlreadyExists(err) {
+	err = r.syncNodeConfigMap(p)
+	if err != nil {
 		return err
 	}
 
@@ -250,6 +657,151 @@ func (r *ReconcileECSCluster) deployNode(p *ecsv1alpha1.ECSCluster) (err error)
 	return nil
 }
 
+// syncNodeConfigMap creates the node ConfigMap or updates it when the
+// configuration derived from the spec changes, e.g. the external endpoints
+// advertised by each node ordinal after scaling
+func (r *ReconcileECSCluster) syncNodeConfigMap(p *ecsv1alpha1.ECSCluster) (err error) {
+	configMap := ecs.MakeNodeConfigMap(p)
+	controllerutil.SetControllerReference(p, configMap, r.scheme)
+	err = r.client.Create(context.TODO(), configMap)
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	current := &corev1.ConfigMap{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, current)
+	if err != nil {
+		return fmt.Errorf("failed to get configmap (%s): %v", configMap.Name, err)
+	}
+
+	if !reflect.DeepEqual(current.Data, configMap.Data) {
+		current.Data = configMap.Data
+		err = r.client.Update(context.TODO(), current)
+		if err != nil {
+			return fmt.Errorf("failed to update configmap (%s): %v", configMap.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncNodeExternalServices keeps one external service per node ordinal while
+// external access is enabled, and removes them all once it is disabled
+func (r *ReconcileECSCluster) syncNodeExternalServices(p *ecsv1alpha1.ECSCluster) (err error) {
+	var replicas int32
+	if p.Spec.ExternalAccess.Enabled {
+		replicas = p.Spec.ECS.NodeReplicas
+	}
+
+	serviceList := &corev1.ServiceList{}
+	listOps := &client.ListOptions{
+		Namespace:     p.Namespace,
+		LabelSelector: labels.SelectorFromSet(util.LabelsForNode(p)),
+	}
+	err = r.client.List(context.TODO(), listOps, serviceList)
+	if err != nil {
+		return fmt.Errorf("failed to list node services: %v", err)
+	}
+
+	existing := make(map[int32]bool)
+	for i := range serviceList.Items {
+		service := &serviceList.Items[i]
+		ordinal, ok := util.NodeOrdinalForService(p.Name, service.Name)
+		if !ok {
+			continue
+		}
+
+		if ordinal < replicas {
+			existing[ordinal] = true
+			if syncNodeExternalService(service, ecs.MakeNodeExternalService(p, ordinal)) {
+				err = r.client.Update(context.TODO(), service)
+				if err != nil {
+					return fmt.Errorf("failed to update external service (%s): %v", service.Name, err)
+				}
+			}
+			continue
+		}
+
+		err = r.client.Delete(context.TODO(), service)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete external service (%s): %v", service.Name, err)
+		}
+	}
+
+	for i := int32(0); i < replicas; i++ {
+		if existing[i] {
+			continue
+		}
+
+		service := ecs.MakeNodeExternalService(p, i)
+		controllerutil.SetControllerReference(p, service, r.scheme)
+		err = r.client.Create(context.TODO(), service)
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create external service (%s): %v", service.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncNodeExternalService copies the external access settings of the desired
+// service into the current one and reports whether it was changed
+func syncNodeExternalService(current *corev1.Service, desired *corev1.Service) (changed bool) {
+	if syncManagedAnnotations(current, desired.Annotations) {
+		changed = true
+	}
+
+	if !reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) {
+		changed = true
+		current.Spec.Selector = desired.Spec.Selector
+	}
+
+	if current.Spec.Type != desired.Spec.Type {
+		changed = true
+		current.Spec.Type = desired.Spec.Type
+	}
+
+	if !reflect.DeepEqual(current.Spec.LoadBalancerSourceRanges, desired.Spec.LoadBalancerSourceRanges) {
+		changed = true
+		current.Spec.LoadBalancerSourceRanges = desired.Spec.LoadBalancerSourceRanges
+	}
+
+	// A NodePort of 0 leaves the port assigned by Kubernetes untouched
+	nodePort := desired.Spec.Ports[0].NodePort
+	if len(current.Spec.Ports) > 0 && nodePort != 0 && current.Spec.Ports[0].NodePort != nodePort {
+		changed = true
+		current.Spec.Ports[0].NodePort = nodePort
+	}
+
+	return changed
+}
+
+// syncManagedAnnotations sets the desired annotations on the service and
+// removes the ones the operator set before but no longer wants, keeping the
+// annotations it does not manage
+func syncManagedAnnotations(current *corev1.Service, desired map[string]string) (changed bool) {
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+
+	if managed := current.Annotations[util.ManagedAnnotationsAnnotation]; managed != "" {
+		for _, k := range strings.Split(managed, ",") {
+			if _, ok := desired[k]; !ok {
+				if _, ok := current.Annotations[k]; ok {
+					changed = true
+					delete(current.Annotations, k)
+				}
+			}
+		}
+	}
+
+	for k, v := range desired {
+		if current.Annotations[k] != v {
+			changed = true
+			current.Annotations[k] = v
+		}
+	}
+	return changed
+}
+
 func (r *ReconcileECSCluster) deployBookie(p *ecsv1alpha1.ECSCluster) (err error) {
 	headlessService := ecs.MakeBookieHeadlessService(p)
 	controllerutil.SetControllerReference(p, headlessService, r.scheme)
@@ -268,8 +820,14 @@ func (r *ReconcileECSCluster) deployBookie(p *ecsv1alpha1.ECSCluster) (err error
 	configMap := ecs.MakeBookieConfigMap(p)
 	controllerutil.SetControllerReference(p, configMap, r.scheme)
 	err = r.client.Create(context.TODO(), configMap)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return err
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+		err = r.syncBookieAutoRecovery(configMap)
+		if err != nil {
+			return err
+		}
 	}
 
 	statefulSet := ecs.MakeBookieStatefulSet(p)
@@ -285,17 +843,136 @@ func (r *ReconcileECSCluster) deployBookie(p *ecsv1alpha1.ECSCluster) (err error
 	return nil
 }
 
-func (r *ReconcileECSCluster) syncClusterSize(p *ecsv1alpha1.ECSCluster) (err error) {
-	err = r.syncBookieSize(p)
+// syncBookieAutoRecovery updates the BK_AUTORECOVERY key of the existing
+// bookie ConfigMap, so that the bookies stop running AutoRecovery once it runs
+// standalone. The other keys are left as they were when the bookies were
+// created. Running bookies pick up the change when they are restarted
+func (r *ReconcileECSCluster) syncBookieAutoRecovery(desired *corev1.ConfigMap) (err error) {
+	current := &corev1.ConfigMap{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current)
+	if err != nil {
+		return fmt.Errorf("failed to get configmap (%s): %v", desired.Name, err)
+	}
+
+	currentValue, currentSet := current.Data[ecs.BookieAutoRecoveryKey]
+	desiredValue, desiredSet := desired.Data[ecs.BookieAutoRecoveryKey]
+	if currentSet == desiredSet && currentValue == desiredValue {
+		return nil
+	}
+
+	if desiredSet {
+		if current.Data == nil {
+			current.Data = make(map[string]string)
+		}
+		current.Data[ecs.BookieAutoRecoveryKey] = desiredValue
+	} else {
+		delete(current.Data, ecs.BookieAutoRecoveryKey)
+	}
+	err = r.client.Update(context.TODO(), current)
+	if err != nil {
+		return fmt.Errorf("failed to update configmap (%s): %v", desired.Name, err)
+	}
+	return nil
+}
+
+// detectLegacyBookieVolumes records in the spec that the existing bookies
+// use the volume layout of earlier operator versions, so that the bookie pods
+// keep finding their journal and ledgers if the StatefulSet is recreated.
+// It returns true if the cluster was updated
+func (r *ReconcileECSCluster) detectLegacyBookieVolumes(p *ecsv1alpha1.ECSCluster) (updated bool, err error) {
+	if p.Spec.Bookkeeper.Storage.LegacyVolumeMounts {
+		return false, nil
+	}
+
+	sts := &appsv1.StatefulSet{}
+	name := util.StatefulSetNameForBookie(p.Name)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, sts)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get stateful-set (%s): %v", name, err)
+	}
+
+	if !ecs.HasLegacyBookieVolumeMounts(sts) {
+		return false, nil
+	}
+
+	log.Printf("ecs cluster (%s): bookies use the legacy volume layout, keeping it", p.Name)
+	p.Spec.Bookkeeper.Storage.LegacyVolumeMounts = true
+	err = r.client.Update(context.TODO(), p)
 	if err != nil {
+		return false, fmt.Errorf("failed to update ecs cluster (%s): %v", p.Name, err)
+	}
+	return true, nil
+}
+
+// deployAutoRecovery creates the standalone AutoRecovery Deployment, or
+// deletes it when AutoRecovery runs inside the bookies or is disabled
+func (r *ReconcileECSCluster) deployAutoRecovery(p *ecsv1alpha1.ECSCluster) (err error) {
+	pdb := ecs.MakeAutoRecoveryPodDisruptionBudget(p)
+	deployment := ecs.MakeAutoRecoveryDeployment(p)
+
+	if !p.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		err = r.client.Delete(context.TODO(), deployment)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete deployment (%s): %v", deployment.Name, err)
+		}
+		err = r.client.Delete(context.TODO(), pdb)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pdb (%s): %v", pdb.Name, err)
+		}
+		return nil
+	}
+
+	controllerutil.SetControllerReference(p, pdb, r.scheme)
+	err = r.client.Create(context.TODO(), pdb)
+	if err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
 
-	err = r.syncNodeSize(p)
+	controllerutil.SetControllerReference(p, deployment, r.scheme)
+	err = r.client.Create(context.TODO(), deployment)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (r *ReconcileECSCluster) syncClusterSize(p *ecsv1alpha1.ECSCluster) (err error) {
+	// A backup stops the bookies and nodes until their volumes are
+	// snapshotted
+	if util.IsQuiesced(p) {
+		log.Printf("skipping bookie and node scaling of ecs cluster (%s) quiesced by backup (%s)",
+			p.Name, p.Annotations[util.QuiesceAnnotation])
+		return r.syncControllerSize(p)
+	}
+
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		log.Printf("skipping bookie and node scaling of ecs cluster (%s) until zookeeper is reachable", p.Name)
+		return r.syncControllerSize(p)
+	}
+
+	err = r.syncBookieSize(p)
 	if err != nil {
 		return err
 	}
 
+	if p.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		err = r.syncAutoRecoverySize(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionTier2Reachable) {
+		err = r.syncNodeSize(p)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = r.syncControllerSize(p)
 	if err != nil {
 		return err
@@ -304,6 +981,32 @@ func (r *ReconcileECSCluster) syncClusterSize(p *ecsv1alpha1.ECSCluster) (err er
 	return nil
 }
 
+// reconcileQuiesce releases a cluster quiesced by a backup that was deleted
+// or finished without releasing it
+func (r *ReconcileECSCluster) reconcileQuiesce(p *ecsv1alpha1.ECSCluster) (err error) {
+	if !util.IsQuiesced(p) {
+		return nil
+	}
+
+	backup := &ecsv1alpha1.ECSClusterBackup{}
+	name := types.NamespacedName{Name: p.Annotations[util.QuiesceAnnotation], Namespace: p.Namespace}
+	err = r.client.Get(context.TODO(), name, backup)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get backup (%s): %v", name.Name, err)
+	}
+	if err == nil && !backup.Status.IsFinished() {
+		return nil
+	}
+
+	log.Printf("releasing ecs cluster (%s) quiesced by backup (%s)", p.Name, name.Name)
+	delete(p.Annotations, util.QuiesceAnnotation)
+	err = r.client.Update(context.TODO(), p)
+	if err != nil {
+		return fmt.Errorf("failed to unquiesce ecs cluster (%s): %v", p.Name, err)
+	}
+	return nil
+}
+
 func (r *ReconcileECSCluster) syncBookieSize(p *ecsv1alpha1.ECSCluster) (err error) {
 	sts := &appsv1.StatefulSet{}
 	name := util.StatefulSetNameForBookie(p.Name)
@@ -312,16 +1015,23 @@ func (r *ReconcileECSCluster) syncBookieSize(p *ecsv1alpha1.ECSCluster) (err err
 		return fmt.Errorf("failed to get stateful-set (%s): %v", sts.Name, err)
 	}
 
-	if *sts.Spec.Replicas != p.Spec.Bookkeeper.Replicas {
-		sts.Spec.Replicas = &(p.Spec.Bookkeeper.Replicas)
+	replicas := p.Spec.Bookkeeper.Replicas
+	if p.Spec.Maintenance != nil {
+		replicas = keepCordonedOrdinals(sts.Name, replicas, p.Spec.Maintenance.BookieOrdinals)
+	}
+
+	if *sts.Spec.Replicas != replicas {
+		sts.Spec.Replicas = &replicas
 		err = r.client.Update(context.TODO(), sts)
 		if err != nil {
 			return fmt.Errorf("failed to update size of stateful-set (%s): %v", sts.Name, err)
 		}
 
-		err = r.syncStatefulSetPvc(sts)
-		if err != nil {
-			return fmt.Errorf("failed to sync pvcs of stateful-set (%s): %v", sts.Name, err)
+		if !p.Spec.Bookkeeper.Storage.Ephemeral {
+			err = r.syncStatefulSetPvc(sts)
+			if err != nil {
+				return fmt.Errorf("failed to sync pvcs of stateful-set (%s): %v", sts.Name, err)
+			}
 		}
 	}
 	return nil
@@ -335,21 +1045,41 @@ func (r *ReconcileECSCluster) syncNodeSize(p *ecsv1alpha1.ECSCluster) (err error
 		return fmt.Errorf("failed to get stateful-set (%s): %v", sts.Name, err)
 	}
 
-	if *sts.Spec.Replicas != p.Spec.ECS.NodeReplicas {
-		sts.Spec.Replicas = &(p.Spec.ECS.NodeReplicas)
+	replicas := p.Spec.ECS.NodeReplicas
+	if p.Spec.Maintenance != nil {
+		replicas = keepCordonedOrdinals(sts.Name, replicas, p.Spec.Maintenance.NodeOrdinals)
+	}
+
+	if *sts.Spec.Replicas != replicas {
+		sts.Spec.Replicas = &replicas
 		err = r.client.Update(context.TODO(), sts)
 		if err != nil {
 			return fmt.Errorf("failed to update size of stateful-set (%s): %v", sts.Name, err)
 		}
 
-		err = r.syncStatefulSetPvc(sts)
-		if err != nil {
-			return fmt.Errorf("failed to sync pvcs of stateful-set (%s): %v", sts.Name, err)
+		if !p.Spec.ECS.EphemeralCache {
+			err = r.syncStatefulSetPvc(sts)
+			if err != nil {
+				return fmt.Errorf("failed to sync pvcs of stateful-set (%s): %v", sts.Name, err)
+			}
 		}
 	}
 	return nil
 }
 
+// keepCordonedOrdinals returns the number of replicas of a StatefulSet that
+// keeps the pods under maintenance, which would otherwise be deleted by a
+// scale down
+func keepCordonedOrdinals(stsName string, replicas int32, cordoned []int32) int32 {
+	for _, ordinal := range cordoned {
+		if ordinal >= replicas {
+			log.Printf("not scaling stateful-set (%s) below ordinal %d under maintenance", stsName, ordinal)
+			replicas = ordinal + 1
+		}
+	}
+	return replicas
+}
+
 func (r *ReconcileECSCluster) syncControllerSize(p *ecsv1alpha1.ECSCluster) (err error) {
 	deploy := &appsv1.Deployment{}
 	name := util.DeploymentNameForController(p.Name)
@@ -368,6 +1098,145 @@ func (r *ReconcileECSCluster) syncControllerSize(p *ecsv1alpha1.ECSCluster) (err
 	return nil
 }
 
+func (r *ReconcileECSCluster) syncAutoRecoverySize(p *ecsv1alpha1.ECSCluster) (err error) {
+	deploy := &appsv1.Deployment{}
+	name := util.DeploymentNameForAutoRecovery(p.Name)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deploy)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment (%s): %v", name, err)
+	}
+
+	replicas := p.Spec.Bookkeeper.AutoRecoveryDeployment.Replicas
+	if *deploy.Spec.Replicas != replicas {
+		deploy.Spec.Replicas = &replicas
+		err = r.client.Update(context.TODO(), deploy)
+		if err != nil {
+			return fmt.Errorf("failed to update size of deployment (%s): %v", deploy.Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcileMaintenance takes the bookies and nodes under maintenance out of
+// service and puts the others back. The PodDisruptionBudgets of clusters
+// created before maintenance mode are updated to select in-service pods only,
+// and the governing Services that selected them are updated to select every
+// pod again
+func (r *ReconcileECSCluster) reconcileMaintenance(p *ecsv1alpha1.ECSCluster) (err error) {
+	components := []struct {
+		sts      string
+		labels   map[string]string
+		cordoned func(int32) bool
+	}{
+		{util.StatefulSetNameForBookie(p.Name), util.LabelsForBookie(p), p.Spec.Maintenance.IsBookieCordoned},
+		{util.StatefulSetNameForNode(p.Name), util.LabelsForNode(p), p.Spec.Maintenance.IsNodeCordoned},
+	}
+
+	for _, component := range components {
+		podList := &corev1.PodList{}
+		listOps := &client.ListOptions{
+			Namespace:     p.Namespace,
+			LabelSelector: labels.SelectorFromSet(component.labels),
+		}
+		err = r.client.List(context.TODO(), listOps, podList)
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %v", err)
+		}
+
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			ordinal, ok := util.OrdinalForPod(component.sts, pod.Name)
+			if !ok {
+				continue
+			}
+
+			inService := "true"
+			if component.cordoned(ordinal) {
+				inService = "false"
+			}
+			if pod.Labels[util.InServiceLabel] == inService {
+				continue
+			}
+
+			log.Printf("setting pod (%s) in service: %s", pod.Name, inService)
+			pod.Labels[util.InServiceLabel] = inService
+			err = r.client.Update(context.TODO(), pod)
+			if err != nil {
+				return fmt.Errorf("failed to update pod (%s): %v", pod.Name, err)
+			}
+		}
+	}
+
+	for _, service := range []*corev1.Service{ecs.MakeBookieHeadlessService(p), ecs.MakeNodeHeadlessService(p)} {
+		err = r.syncServiceSelector(service)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pdb := range []*policyv1beta1.PodDisruptionBudget{ecs.MakeBookiePodDisruptionBudget(p), ecs.MakeNodePodDisruptionBudget(p)} {
+		err = r.syncPodDisruptionBudget(p, pdb)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ReconcileECSCluster) syncServiceSelector(desired *corev1.Service) (err error) {
+	current := &corev1.Service{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current)
+	if errors.IsNotFound(err) {
+		// Not deployed yet, e.g. while Tier 2 or ZooKeeper is unreachable
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get service (%s): %v", desired.Name, err)
+	}
+
+	if reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) {
+		return nil
+	}
+
+	current.Spec.Selector = desired.Spec.Selector
+	err = r.client.Update(context.TODO(), current)
+	if err != nil {
+		return fmt.Errorf("failed to update service (%s): %v", current.Name, err)
+	}
+	return nil
+}
+
+// syncPodDisruptionBudget recreates a PodDisruptionBudget whose selector or
+// budget changed, as its spec cannot be updated
+func (r *ReconcileECSCluster) syncPodDisruptionBudget(p *ecsv1alpha1.ECSCluster, desired *policyv1beta1.PodDisruptionBudget) (err error) {
+	current := &policyv1beta1.PodDisruptionBudget{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get pdb (%s): %v", desired.Name, err)
+	}
+
+	if reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) &&
+		reflect.DeepEqual(current.Spec.MinAvailable, desired.Spec.MinAvailable) &&
+		reflect.DeepEqual(current.Spec.MaxUnavailable, desired.Spec.MaxUnavailable) {
+		return nil
+	}
+
+	err = r.client.Delete(context.TODO(), current)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pdb (%s): %v", current.Name, err)
+	}
+
+	controllerutil.SetControllerReference(p, desired, r.scheme)
+	err = r.client.Create(context.TODO(), desired)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create pdb (%s): %v", desired.Name, err)
+	}
+	return nil
+}
+
 func (r *ReconcileECSCluster) reconcileFinalizers(p *ecsv1alpha1.ECSCluster) (err error) {
 	if p.DeletionTimestamp.IsZero() {
 		if !util.ContainsString(p.ObjectMeta.Finalizers, util.ZkFinalizer) {
@@ -391,6 +1260,12 @@ func (r *ReconcileECSCluster) reconcileFinalizers(p *ecsv1alpha1.ECSCluster) (er
 }
 
 func (r *ReconcileECSCluster) cleanUpZookeeperMeta(p *ecsv1alpha1.ECSCluster) (err error) {
+	// An embedded ensemble is garbage collected along with the cluster, and
+	// its znodes with its volumes
+	if p.Spec.Zookeeper != nil {
+		return nil
+	}
+
 	if err = util.WaitForClusterToTerminate(r.client, p); err != nil {
 		return fmt.Errorf("failed to wait for cluster pods termination (%s): %v", p.Name, err)
 	}
@@ -403,7 +1278,7 @@ func (r *ReconcileECSCluster) cleanUpZookeeperMeta(p *ecsv1alpha1.ECSCluster) (e
 
 func (r *ReconcileECSCluster) syncStatefulSetPvc(sts *appsv1.StatefulSet) error {
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: sts.Spec.Template.Labels,
+		MatchLabels: sts.Spec.Selector.MatchLabels,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to convert label selector: %v", err)
@@ -474,9 +1349,233 @@ func (r *ReconcileECSCluster) reconcileClusterStatus(p *ecsv1alpha1.ECSCluster)
 	p.Status.Members.Ready = readyMembers
 	p.Status.Members.Unready = unreadyMembers
 
+	reconcileEphemeralStorageStatus(p)
+	reconcileJVMMemoryStatus(p)
+
+	err = r.reconcileAutoRecoveryStatus(p)
+	if err != nil {
+		return fmt.Errorf("failed to get autorecovery status: %v", err)
+	}
+
+	err = r.reconcileBookkeeperStatus(p)
+	if err != nil {
+		return fmt.Errorf("failed to get bookkeeper status: %v", err)
+	}
+
+	err = r.reconcileControllerStatus(p)
+	if err != nil {
+		return fmt.Errorf("failed to get controller status: %v", err)
+	}
+
+	p.Status.ExternalAddresses, err = r.getNodeExternalAddresses(p)
+	if err != nil {
+		return fmt.Errorf("failed to get external addresses: %v", err)
+	}
+
 	err = r.client.Status().Update(context.TODO(), p)
 	if err != nil {
 		return fmt.Errorf("failed to update cluster status: %v", err)
 	}
 	return nil
 }
+
+// reconcileEphemeralStorageStatus warns that the data of the components using
+// emptyDir volumes does not survive the deletion of their pods. The warning is
+// logged when the condition changes
+func reconcileEphemeralStorageStatus(p *ecsv1alpha1.ECSCluster) {
+	var components []string
+	if p.Spec.Bookkeeper.Storage.Ephemeral {
+		components = append(components, "bookie journal, ledgers and index")
+	}
+	if p.Spec.ECS.EphemeralCache {
+		components = append(components, "node cache")
+	}
+
+	if len(components) == 0 {
+		p.Status.SetEphemeralStorageConditionFalse()
+		return
+	}
+
+	message := fmt.Sprintf("WARNING: %s stored in emptyDir volumes, data is lost when pods are deleted. Do not use in production",
+		strings.Join(components, " and "))
+	_, condition := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionEphemeralStorage)
+	if condition == nil || condition.Status != corev1.ConditionTrue || condition.Message != message {
+		log.Warnf("ecs cluster (%s): %s", p.Name, message)
+	}
+	p.Status.SetEphemeralStorageConditionTrue(message)
+}
+
+// reconcileJVMMemoryStatus reports the components whose JVM options exceed
+// their memory limit. The warning is logged when the condition changes
+func reconcileJVMMemoryStatus(p *ecsv1alpha1.ECSCluster) {
+	warnings := ecs.ValidateJVMOptions(p)
+	if len(warnings) == 0 {
+		p.Status.SetJVMMemoryExceededConditionFalse()
+		return
+	}
+
+	message := strings.Join(warnings, "; ")
+	_, condition := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionJVMMemoryExceeded)
+	if condition == nil || condition.Status != corev1.ConditionTrue || condition.Message != message {
+		log.Warnf("ecs cluster (%s) may be OOM killed: %s", p.Name, message)
+	}
+	p.Status.SetJVMMemoryExceededConditionTrue(message)
+}
+
+// reconcileAutoRecoveryStatus reports the replicas of the standalone
+// AutoRecovery Deployment and whether an auditor is elected. Without an
+// auditor, the ledgers of failed bookies are never re-replicated
+func (r *ReconcileECSCluster) reconcileAutoRecoveryStatus(p *ecsv1alpha1.ECSCluster) error {
+	if !*p.Spec.Bookkeeper.AutoRecovery {
+		p.Status.AutoRecovery = nil
+		p.Status.Auditor = nil
+		p.Status.SetAuditorElectedConditionFalse("AutoRecoveryDisabled", "")
+		return nil
+	}
+
+	if p.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		deploy := &appsv1.Deployment{}
+		name := util.DeploymentNameForAutoRecovery(p.Name)
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deploy)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get deployment (%s): %v", name, err)
+		}
+		p.Status.AutoRecovery = &ecsv1alpha1.AutoRecoveryStatus{
+			Replicas:      p.Spec.Bookkeeper.AutoRecoveryDeployment.Replicas,
+			ReadyReplicas: deploy.Status.ReadyReplicas,
+		}
+	} else {
+		p.Status.AutoRecovery = nil
+	}
+
+	// The auditor is looked up in ZooKeeper at most every 30s, and the
+	// previous result is kept in between
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		p.Status.SetAuditorElectedConditionFalse("ZookeeperUnreachable", "the auditor cannot be looked up while zookeeper is unreachable")
+		return nil
+	}
+	if !isAuditorCheckDue(p) {
+		if p.Status.AutoRecovery != nil && p.Status.Auditor != nil {
+			p.Status.AutoRecovery.Auditor = p.Status.Auditor.BookieID
+		}
+		return nil
+	}
+
+	auditor, err := r.zookeeper.GetAuditor(p)
+	p.Status.Auditor = &ecsv1alpha1.AuditorStatus{
+		BookieID:      auditor,
+		LastCheckTime: time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		log.Printf("failed to get auditor of ecs cluster (%s): %v", p.Name, err)
+		p.Status.SetAuditorElectedConditionFalse("ZookeeperError", err.Error())
+		return nil
+	}
+
+	if p.Status.AutoRecovery != nil {
+		p.Status.AutoRecovery.Auditor = auditor
+	}
+	if auditor == "" {
+		p.Status.SetAuditorElectedConditionFalse("NoAuditor", "no auditor is elected, under-replicated ledgers are not recovered")
+		return nil
+	}
+	p.Status.SetAuditorElectedConditionTrue(auditor)
+	return nil
+}
+
+// getNodeExternalAddresses returns the external address of every node ordinal.
+// LoadBalancer services advertise their ingress IP or hostname, while NodePort
+// services advertise the address of the Kubernetes node hosting the pod.
+// A configured hostname template takes precedence over the allocated address
+func (r *ReconcileECSCluster) getNodeExternalAddresses(p *ecsv1alpha1.ECSCluster) ([]ecsv1alpha1.NodeExternalAddress, error) {
+	if !p.Spec.ExternalAccess.Enabled {
+		return nil, nil
+	}
+
+	var addresses []ecsv1alpha1.NodeExternalAddress
+	for i := int32(0); i < p.Spec.ECS.NodeReplicas; i++ {
+		address := ecsv1alpha1.NodeExternalAddress{
+			Node: util.PodNameForNode(p.Name, i),
+		}
+
+		service := &corev1.Service{}
+		name := util.ServiceNameForNode(p.Name, i)
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, service)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				addresses = append(addresses, address)
+				continue
+			}
+			return nil, err
+		}
+
+		var (
+			host string
+			port int32
+		)
+		switch service.Spec.Type {
+		case corev1.ServiceTypeLoadBalancer:
+			host, port = loadBalancerAddress(service)
+		case corev1.ServiceTypeNodePort:
+			host, port, err = r.nodePortAddress(p, service, address.Node)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if hostname := p.Spec.ExternalAccess.HostnameForNode(p.Name, i); hostname != "" {
+			host = hostname
+		}
+		if host != "" && port != 0 {
+			address.Address = fmt.Sprintf("%s:%d", host, port)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func loadBalancerAddress(service *corev1.Service) (string, int32) {
+	if len(service.Spec.Ports) == 0 {
+		return "", 0
+	}
+
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP, service.Spec.Ports[0].Port
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname, service.Spec.Ports[0].Port
+		}
+	}
+	return "", 0
+}
+
+func (r *ReconcileECSCluster) nodePortAddress(p *ecsv1alpha1.ECSCluster, service *corev1.Service, podName string) (string, int32, error) {
+	if len(service.Spec.Ports) == 0 || service.Spec.Ports[0].NodePort == 0 {
+		return "", 0, nil
+	}
+	nodePort := service.Spec.Ports[0].NodePort
+
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: p.Namespace}, pod)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nodePort, nil
+		}
+		return "", 0, err
+	}
+	if pod.Spec.NodeName == "" {
+		return "", nodePort, nil
+	}
+
+	node := &corev1.Node{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Spec.NodeName}, node)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nodePort, nil
+		}
+		return "", 0, err
+	}
+
+	return util.NodeAddress(node), nodePort, nil
+}
@@ -12,20 +12,28 @@ package ecscluster
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	controllerconfig "github.com/ecs/ecs-operator/pkg/controller/config"
 	"github.com/ecs/ecs-operator/pkg/util"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	. "github.com/onsi/ginkgo"
@@ -37,6 +45,46 @@ func TestBookie(t *testing.T) {
 	RunSpecs(t, "ECS cluster")
 }
 
+// reachableZookeeper stands in for a ZooKeeper ensemble holding the metadata
+// of the cluster
+func reachableZookeeper(p *v1alpha1.ECSCluster) (time.Duration, bool, error) {
+	return time.Millisecond, true, nil
+}
+
+func unreachableZookeeper(p *v1alpha1.ECSCluster) (time.Duration, bool, error) {
+	return 0, false, fmt.Errorf("failed to connect to zookeeper: timed out")
+}
+
+// fakeZookeeper stands in for the BookKeeper metadata of the cluster and
+// counts the lookups
+type fakeZookeeper struct {
+	auditor    string
+	auditorErr error
+	lookups    int
+
+	available        []string
+	readOnly         []string
+	registrationsErr error
+
+	underReplicated    int
+	underReplicatedErr error
+	ledgerCounts       int
+}
+
+func (z *fakeZookeeper) GetAuditor(p *v1alpha1.ECSCluster) (string, error) {
+	z.lookups++
+	return z.auditor, z.auditorErr
+}
+
+func (z *fakeZookeeper) GetBookieRegistrations(p *v1alpha1.ECSCluster) ([]string, []string, error) {
+	return z.available, z.readOnly, z.registrationsErr
+}
+
+func (z *fakeZookeeper) CountUnderReplicatedLedgers(p *v1alpha1.ECSCluster) (int, error) {
+	z.ledgerCounts++
+	return z.underReplicated, z.underReplicatedErr
+}
+
 var _ = Describe("ECSCluster Controller", func() {
 	const (
 		Name      = "example"
@@ -50,8 +98,9 @@ var _ = Describe("ECSCluster Controller", func() {
 
 	Context("Reconcile", func() {
 		var (
-			req reconcile.Request
-			p   *v1alpha1.ECSCluster
+			req   reconcile.Request
+			p     *v1alpha1.ECSCluster
+			tier2 *corev1.PersistentVolumeClaim
 		)
 
 		BeforeEach(func() {
@@ -68,6 +117,15 @@ var _ = Describe("ECSCluster Controller", func() {
 				},
 			}
 			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+			tier2 = &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      v1alpha1.DefaultECSTier2ClaimName,
+					Namespace: Namespace,
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Phase: corev1.ClaimBound,
+				},
+			}
 		})
 
 		Context("Default spec", func() {
@@ -78,8 +136,8 @@ var _ = Describe("ECSCluster Controller", func() {
 
 			BeforeEach(func() {
 				p.WithDefaults()
-				client = fake.NewFakeClient(p)
-				r = &ReconcileECSCluster{client: client, scheme: s}
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
 				_, err = r.Reconcile(req)
 			})
 
@@ -164,8 +222,8 @@ var _ = Describe("ECSCluster Controller", func() {
 					},
 				}
 				p.WithDefaults()
-				client = fake.NewFakeClient(p)
-				r = &ReconcileECSCluster{client: client, scheme: s}
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
 				_, err = r.Reconcile(req)
 			})
 
@@ -221,5 +279,453 @@ var _ = Describe("ECSCluster Controller", func() {
 				})
 			})
 		})
+
+		Context("Unreachable tier2", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				client = fake.NewFakeClient(p)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+				_, err = r.Reconcile(req)
+			})
+
+			It("shouldn't error", func() {
+				Ω(err).Should(BeNil())
+			})
+
+			It("should set the tier2 reachable condition to false", func() {
+				foundP := &v1alpha1.ECSCluster{}
+				err = client.Get(context.TODO(), req.NamespacedName, foundP)
+				Ω(err).Should(BeNil())
+				_, condition := foundP.Status.GetClusterCondition(v1alpha1.ClusterConditionTier2Reachable)
+				Ω(condition).ShouldNot(BeNil())
+				Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			})
+
+			It("should not deploy the nodes", func() {
+				foundSS := &appsv1.StatefulSet{}
+				nn := types.NamespacedName{
+					Name:      util.StatefulSetNameForNode(p.Name),
+					Namespace: Namespace,
+				}
+				err = client.Get(context.TODO(), nn, foundSS)
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+			})
+		})
+
+		Context("Invalid spec", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				p.Spec.ECS.Tier2.S3 = &v1alpha1.S3Spec{Bucket: "ecs", Credentials: "s3-credentials"}
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+				_, err = r.Reconcile(req)
+			})
+
+			It("shouldn't error", func() {
+				Ω(err).Should(BeNil())
+			})
+
+			It("should set the spec valid condition to false", func() {
+				foundP := &v1alpha1.ECSCluster{}
+				err = client.Get(context.TODO(), req.NamespacedName, foundP)
+				Ω(err).Should(BeNil())
+				_, condition := foundP.Status.GetClusterCondition(v1alpha1.ClusterConditionSpecValid)
+				Ω(condition).ShouldNot(BeNil())
+				Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			})
+
+			It("should not deploy the cluster", func() {
+				foundSS := &appsv1.StatefulSet{}
+				nn := types.NamespacedName{
+					Name:      util.StatefulSetNameForBookie(p.Name),
+					Namespace: Namespace,
+				}
+				err = client.Get(context.TODO(), nn, foundSS)
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+			})
+		})
+
+		Context("Network policies", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				p.Spec.NetworkPolicy = &v1alpha1.NetworkPolicySpec{Enabled: true}
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+				err = r.deployNetworkPolicies(p)
+			})
+
+			It("should create the network policies", func() {
+				Ω(err).Should(BeNil())
+				foundPolicy := &networkingv1.NetworkPolicy{}
+				nn := types.NamespacedName{
+					Name:      util.NetworkPolicyNameForController(p.Name),
+					Namespace: Namespace,
+				}
+				err = client.Get(context.TODO(), nn, foundPolicy)
+				Ω(err).Should(BeNil())
+			})
+
+			It("should delete the network policies once disabled", func() {
+				p.Spec.NetworkPolicy = nil
+				err = r.deployNetworkPolicies(p)
+				Ω(err).Should(BeNil())
+				for _, name := range []string{
+					util.NetworkPolicyNameForBookie(p.Name),
+					util.NetworkPolicyNameForController(p.Name),
+					util.NetworkPolicyNameForNode(p.Name),
+				} {
+					foundPolicy := &networkingv1.NetworkPolicy{}
+					err = client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: Namespace}, foundPolicy)
+					Ω(errors.IsNotFound(err)).Should(BeTrue())
+				}
+			})
+		})
+
+		Context("Unreachable zookeeper", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: unreachableZookeeper, zookeeper: &fakeZookeeper{}}
+				_, err = r.Reconcile(req)
+			})
+
+			It("shouldn't error", func() {
+				Ω(err).Should(BeNil())
+			})
+
+			It("should set the zookeeper reachable condition to false", func() {
+				foundP := &v1alpha1.ECSCluster{}
+				err = client.Get(context.TODO(), req.NamespacedName, foundP)
+				Ω(err).Should(BeNil())
+				_, condition := foundP.Status.GetClusterCondition(v1alpha1.ClusterConditionZookeeperReachable)
+				Ω(condition).ShouldNot(BeNil())
+				Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			})
+
+			It("should not report the bookkeeper status", func() {
+				foundP := &v1alpha1.ECSCluster{}
+				err = client.Get(context.TODO(), req.NamespacedName, foundP)
+				Ω(err).Should(BeNil())
+				Ω(foundP.Status.Bookkeeper).Should(BeNil())
+				_, condition := foundP.Status.GetClusterCondition(v1alpha1.ClusterConditionBookkeeperDegraded)
+				Ω(condition).ShouldNot(BeNil())
+				Ω(condition.Status).Should(Equal(corev1.ConditionUnknown))
+			})
+
+			It("should not deploy the bookies and the nodes", func() {
+				for _, name := range []string{util.StatefulSetNameForBookie(p.Name), util.StatefulSetNameForNode(p.Name)} {
+					foundSS := &appsv1.StatefulSet{}
+					nn := types.NamespacedName{
+						Name:      name,
+						Namespace: Namespace,
+					}
+					err = client.Get(context.TODO(), nn, foundSS)
+					Ω(errors.IsNotFound(err)).Should(BeTrue())
+				}
+			})
+		})
+
+		Context("External access", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1alpha1.ClusterSpec{
+					ExternalAccess: &v1alpha1.ExternalAccess{
+						Enabled: true,
+					},
+					ECS: &v1alpha1.ECSSpec{
+						NodeReplicas: 3,
+					},
+				}
+				p.WithDefaults()
+				client = fake.NewFakeClient(p, tier2)
+				r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+				_, err = r.Reconcile(req)
+			})
+
+			It("shouldn't error", func() {
+				Ω(err).Should(BeNil())
+			})
+
+			It("should create an external service per node", func() {
+				for i := int32(0); i < 3; i++ {
+					foundSvc := &corev1.Service{}
+					nn := types.NamespacedName{
+						Name:      util.ServiceNameForNode(p.Name, i),
+						Namespace: Namespace,
+					}
+					err = client.Get(context.TODO(), nn, foundSvc)
+					Ω(err).Should(BeNil())
+					Ω(foundSvc.Spec.Type).Should(Equal(corev1.ServiceTypeLoadBalancer))
+				}
+			})
+
+			Context("Scale down", func() {
+				BeforeEach(func() {
+					err = client.Get(context.TODO(), req.NamespacedName, p)
+					Ω(err).Should(BeNil())
+					p.Spec.ECS.NodeReplicas = 1
+					err = client.Update(context.TODO(), p)
+					Ω(err).Should(BeNil())
+					_, err = r.Reconcile(req)
+				})
+
+				It("should remove the services of the removed nodes", func() {
+					Ω(err).Should(BeNil())
+					foundSvc := &corev1.Service{}
+					nn := types.NamespacedName{
+						Name:      util.ServiceNameForNode(p.Name, 0),
+						Namespace: Namespace,
+					}
+					Ω(client.Get(context.TODO(), nn, foundSvc)).Should(BeNil())
+					for i := int32(1); i < 3; i++ {
+						nn.Name = util.ServiceNameForNode(p.Name, i)
+						err = client.Get(context.TODO(), nn, foundSvc)
+						Ω(errors.IsNotFound(err)).Should(BeTrue())
+					}
+				})
+			})
+
+			Context("Disable external access", func() {
+				BeforeEach(func() {
+					err = client.Get(context.TODO(), req.NamespacedName, p)
+					Ω(err).Should(BeNil())
+					p.Spec.ExternalAccess.Enabled = false
+					p.Spec.ExternalAccess.Type = ""
+					err = client.Update(context.TODO(), p)
+					Ω(err).Should(BeNil())
+					_, err = r.Reconcile(req)
+				})
+
+				It("should remove all the external services", func() {
+					Ω(err).Should(BeNil())
+					for i := int32(0); i < 3; i++ {
+						foundSvc := &corev1.Service{}
+						nn := types.NamespacedName{
+							Name:      util.ServiceNameForNode(p.Name, i),
+							Namespace: Namespace,
+						}
+						err = client.Get(context.TODO(), nn, foundSvc)
+						Ω(errors.IsNotFound(err)).Should(BeTrue())
+					}
+				})
+			})
+		})
+
+		Context("Resync period", func() {
+			var (
+				resyncPeriod time.Duration
+				result       reconcile.Result
+				err          error
+			)
+
+			BeforeEach(func() {
+				resyncPeriod = controllerconfig.ResyncPeriod
+				controllerconfig.ResyncPeriod = time.Hour
+				p.WithDefaults()
+			})
+
+			AfterEach(func() {
+				controllerconfig.ResyncPeriod = resyncPeriod
+			})
+
+			It("should requeue a healthy cluster after the resync period", func() {
+				r = &ReconcileECSCluster{client: fake.NewFakeClient(p, tier2), scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+				result, err = r.Reconcile(req)
+				Ω(err).Should(BeNil())
+				Ω(result.RequeueAfter).Should(Equal(time.Hour))
+			})
+
+			It("should requeue sooner while zookeeper is unreachable", func() {
+				r = &ReconcileECSCluster{client: fake.NewFakeClient(p, tier2), scheme: s, probeZookeeper: unreachableZookeeper, zookeeper: &fakeZookeeper{}}
+				result, err = r.Reconcile(req)
+				Ω(err).Should(BeNil())
+				Ω(result.RequeueAfter).Should(Equal(ReconcileTime))
+			})
+		})
+	})
+})
+
+var _ = Describe("Pod watch", func() {
+	mapPod := func(labels map[string]string) []reconcile.Request {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example-bookie-0",
+				Namespace: "default",
+				Labels:    labels,
+			},
+		}
+		return requestsForPod(handler.MapObject{Meta: pod, Object: pod})
+	}
+
+	It("should enqueue the cluster of a pod", func() {
+		p := &v1alpha1.ECSCluster{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+		Ω(mapPod(util.LabelsForBookie(p))).Should(Equal([]reconcile.Request{
+			{NamespacedName: types.NamespacedName{Name: "example", Namespace: "default"}},
+		}))
+	})
+
+	It("should ignore the pods of other applications", func() {
+		Ω(mapPod(map[string]string{"app": "zookeeper", "ecs_cluster": "example"})).Should(BeEmpty())
+		Ω(mapPod(nil)).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Controller health", func() {
+	var (
+		p             *v1alpha1.ECSCluster
+		server        *httptest.Server
+		health        string
+		segmentStores string
+		requests      int
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ClusterSpec{
+				ECS: &v1alpha1.ECSSpec{
+					NodeReplicas: 2,
+					Options: map[string]string{
+						"ecsservice.containerCount": "4",
+					},
+				},
+			},
+		}
+		p.WithDefaults()
+
+		health = `{"status": "UP"}`
+		segmentStores = `{"segmentStores": ["10.0.0.1:12345", "10.0.0.2:12345"]}`
+		requests = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			switch r.URL.Path {
+			case "/health":
+				fmt.Fprint(w, health)
+			case "/v1/cluster/segmentstores":
+				fmt.Fprint(w, segmentStores)
+			case "/v1/cluster/containers":
+				fmt.Fprint(w, `{"containers": {"10.0.0.1:12345": [0, 2], "10.0.0.2:12345": [1, 3]}}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		reconcileControllerHealth(p, util.NewControllerClient(server.URL))
+	})
+
+	Context("Healthy cluster", func() {
+		It("should set the healthy condition to true", func() {
+			Ω(p.Status.IsClusterConditionTrue(v1alpha1.ClusterConditionHealthy)).Should(BeTrue())
+		})
+
+		It("should report the container assignment", func() {
+			Ω(p.Status.Controller.Health).Should(Equal("UP"))
+			Ω(p.Status.Controller.SegmentStores).Should(HaveLen(2))
+			Ω(p.Status.Controller.ContainerAssignment).Should(Equal([]v1alpha1.SegmentStoreContainers{
+				{SegmentStore: "10.0.0.1:12345", Containers: 2},
+				{SegmentStore: "10.0.0.2:12345", Containers: 2},
+			}))
+		})
+	})
+
+	Context("Unhealthy controller", func() {
+		BeforeEach(func() {
+			health = `{"status": "DOWN"}`
+		})
+
+		It("should set the healthy condition to false", func() {
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionHealthy)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal(healthReasonControllerUnhealthy))
+		})
+	})
+
+	Context("Missing segment store", func() {
+		BeforeEach(func() {
+			segmentStores = `{"segmentStores": ["10.0.0.1:12345"]}`
+		})
+
+		It("should set the healthy condition to false", func() {
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionHealthy)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal(healthReasonSegmentStoresMissing))
+		})
+	})
+
+	Context("Unassigned containers", func() {
+		BeforeEach(func() {
+			p.Spec.ECS.Options["ecsservice.containerCount"] = "8"
+		})
+
+		It("should set the healthy condition to false", func() {
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionHealthy)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal(healthReasonContainersUnassigned))
+			Ω(condition.Message).Should(Equal("4 of 8 segment containers are assigned"))
+		})
+	})
+
+	Context("Unreachable controller", func() {
+		BeforeEach(func() {
+			server.Close()
+		})
+
+		It("should set the healthy condition to false", func() {
+			_, condition := p.Status.GetClusterCondition(v1alpha1.ClusterConditionHealthy)
+			Ω(condition.Status).Should(Equal(corev1.ConditionFalse))
+			Ω(condition.Reason).Should(Equal(healthReasonControllerUnreachable))
+		})
+
+		It("should record the check", func() {
+			Ω(p.Status.Controller.LastCheckTime).ShouldNot(BeEmpty())
+		})
+	})
+
+	Context("Rate limit", func() {
+		It("should not query the controllers again before the interval", func() {
+			reconcileControllerHealth(p, util.NewControllerClient(server.URL))
+			Ω(requests).Should(Equal(3))
+		})
+
+		It("should query the controllers again after the interval", func() {
+			p.Status.Controller.LastCheckTime = time.Now().Add(-controllerCheckInterval).Format(time.RFC3339)
+			health = `{"status": "DOWN"}`
+			reconcileControllerHealth(p, util.NewControllerClient(server.URL))
+			Ω(requests).Should(Equal(6))
+			Ω(p.Status.Controller.Health).Should(Equal("DOWN"))
+		})
 	})
 })
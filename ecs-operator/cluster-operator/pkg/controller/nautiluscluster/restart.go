@@ -0,0 +1,308 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	restartComponentBookie     = "bookie"
+	restartComponentController = "controller"
+	restartComponentNode       = "node"
+)
+
+// statefulSetRestart is a component restarted one pod at a time
+type statefulSetRestart struct {
+	component   string
+	stsName     string
+	pdbName     string
+	restartedAt string
+	cordoned    func(int32) bool
+
+	// blocker returns why no pod of the component can be restarted right
+	// now, or an empty string if one can
+	blocker func(p *ecsv1alpha1.ECSCluster) (string, error)
+}
+
+// reconcileRestarts rolls out the restartedAt fields of the components and
+// records the progress in the status
+func (r *ReconcileECSCluster) reconcileRestarts(p *ecsv1alpha1.ECSCluster) (err error) {
+	// The bookies and nodes of a quiesced cluster are stopped
+	if !util.IsQuiesced(p) {
+		restarts := []statefulSetRestart{
+			{
+				component:   restartComponentBookie,
+				stsName:     util.StatefulSetNameForBookie(p.Name),
+				pdbName:     util.PdbNameForBookie(p.Name),
+				restartedAt: p.Spec.Bookkeeper.RestartedAt,
+				cordoned:    p.Spec.Maintenance.IsBookieCordoned,
+				blocker:     r.bookieRestartBlocker,
+			},
+			{
+				component:   restartComponentNode,
+				stsName:     util.StatefulSetNameForNode(p.Name),
+				pdbName:     util.PdbNameForNode(p.Name),
+				restartedAt: p.Spec.ECS.NodeRestartedAt,
+				cordoned:    p.Spec.Maintenance.IsNodeCordoned,
+			},
+		}
+
+		for _, restart := range restarts {
+			err = r.restartStatefulSet(p, restart)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.restartController(p)
+}
+
+// restartStatefulSet rolls out a new restartedAt value by lowering the
+// partition of the rolling update of the StatefulSet one ordinal at a time.
+// Pods are restarted from the highest ordinal down, each once the previous
+// one is ready and the PodDisruptionBudget allows it
+func (r *ReconcileECSCluster) restartStatefulSet(p *ecsv1alpha1.ECSCluster, restart statefulSetRestart) (err error) {
+	sts := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: restart.stsName, Namespace: p.Namespace}, sts)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get stateful-set (%s): %v", restart.stsName, err)
+	}
+
+	// Removing the annotation would change the pod template and restart
+	// every pod, so clearing restartedAt keeps the last value
+	if restart.restartedAt == "" {
+		restart.restartedAt = sts.Spec.Template.Annotations[util.RestartedAtAnnotation]
+	}
+	if restart.restartedAt == "" {
+		return nil
+	}
+
+	replicas := *sts.Spec.Replicas
+	status := ecsv1alpha1.RestartStatus{
+		Component:   restart.component,
+		RestartedAt: restart.restartedAt,
+		Phase:       ecsv1alpha1.RestartPhaseInProgress,
+		Replicas:    replicas,
+	}
+
+	if sts.Spec.Template.Annotations[util.RestartedAtAnnotation] != restart.restartedAt {
+		log.Printf("starting rolling restart of stateful-set (%s)", sts.Name)
+		setRestartedAtAnnotation(&sts.Spec.Template.Annotations, restart.restartedAt)
+
+		// Hold every pod at the current revision. They are then released one
+		// by one
+		sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+			Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition: &replicas,
+			},
+		}
+		err = r.client.Update(context.TODO(), sts)
+		if err != nil {
+			return fmt.Errorf("failed to update stateful-set (%s): %v", sts.Name, err)
+		}
+
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	var partition int32
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		partition = *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	if partition > replicas {
+		partition = replicas
+	}
+
+	// Nothing is reported for pods that were never restarted, e.g. when
+	// restartedAt was set at creation
+	current := p.Status.GetRestartStatus(restart.component)
+	if partition == 0 && (current == nil || current.RestartedAt != restart.restartedAt ||
+		current.Phase == ecsv1alpha1.RestartPhaseCompleted) {
+		return nil
+	}
+
+	status.RestartedReplicas = sts.Status.UpdatedReplicas
+	if status.RestartedReplicas > replicas {
+		status.RestartedReplicas = replicas
+	}
+
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.UpdatedReplicas < replicas-partition {
+		status.Message = fmt.Sprintf("waiting for pod (%s-%d) to restart", sts.Name, partition)
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	if sts.Status.ReadyReplicas < replicas {
+		status.Message = "waiting for all pods to be ready"
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	if partition == 0 {
+		log.Printf("completed rolling restart of stateful-set (%s)", sts.Name)
+		status.Phase = ecsv1alpha1.RestartPhaseCompleted
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	next := partition - 1
+	if restart.cordoned(next) {
+		status.Message = fmt.Sprintf("pod (%s-%d) is under maintenance", sts.Name, next)
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: restart.pdbName, Namespace: p.Namespace}, pdb)
+	if err != nil {
+		return fmt.Errorf("failed to get pdb (%s): %v", restart.pdbName, err)
+	}
+	if pdb.Status.PodDisruptionsAllowed < 1 {
+		status.Message = fmt.Sprintf("pdb (%s) allows no disruption", pdb.Name)
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	if restart.blocker != nil {
+		status.Message, err = restart.blocker(p)
+		if err != nil {
+			status.Message = err.Error()
+		}
+		if status.Message != "" {
+			p.Status.SetRestartStatus(status)
+			return nil
+		}
+	}
+
+	log.Printf("restarting pod (%s-%d)", sts.Name, next)
+	sts.Spec.UpdateStrategy.RollingUpdate.Partition = &next
+	err = r.client.Update(context.TODO(), sts)
+	if err != nil {
+		return fmt.Errorf("failed to update stateful-set (%s): %v", sts.Name, err)
+	}
+
+	status.Message = fmt.Sprintf("waiting for pod (%s-%d) to restart", sts.Name, next)
+	p.Status.SetRestartStatus(status)
+	return nil
+}
+
+// bookieRestartBlocker prevents restarting a bookie while ledgers are
+// under-replicated, as it may hold the last copies of their entries. The
+// ledgers are counted right before each bookie is released, since the count
+// of the bookkeeper status may predate the restart of the previous one
+func (r *ReconcileECSCluster) bookieRestartBlocker(p *ecsv1alpha1.ECSCluster) (string, error) {
+	if !p.Status.IsClusterConditionTrue(ecsv1alpha1.ClusterConditionZookeeperReachable) {
+		return "zookeeper is not reachable to count the under-replicated ledgers", nil
+	}
+
+	count, err := r.zookeeper.CountUnderReplicatedLedgers(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to count under-replicated ledgers: %v", err)
+	}
+	if count > 0 {
+		return fmt.Sprintf("%d ledgers are under-replicated", count), nil
+	}
+	return "", nil
+}
+
+// restartController rolls out a new restartedAt value with the rolling
+// update of the controller Deployment, which starts a new controller before
+// stopping an old one
+func (r *ReconcileECSCluster) restartController(p *ecsv1alpha1.ECSCluster) (err error) {
+	deployment := &appsv1.Deployment{}
+	name := util.DeploymentNameForController(p.Name)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deployment)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get deployment (%s): %v", name, err)
+	}
+
+	restartedAt := p.Spec.ECS.ControllerRestartedAt
+	if restartedAt == "" {
+		restartedAt = deployment.Spec.Template.Annotations[util.RestartedAtAnnotation]
+	}
+	if restartedAt == "" {
+		return nil
+	}
+
+	replicas := *deployment.Spec.Replicas
+	status := ecsv1alpha1.RestartStatus{
+		Component:   restartComponentController,
+		RestartedAt: restartedAt,
+		Phase:       ecsv1alpha1.RestartPhaseInProgress,
+		Replicas:    replicas,
+	}
+
+	if deployment.Spec.Template.Annotations[util.RestartedAtAnnotation] != restartedAt {
+		log.Printf("starting rolling restart of deployment (%s)", deployment.Name)
+		setRestartedAtAnnotation(&deployment.Spec.Template.Annotations, restartedAt)
+		deployment.Spec.Strategy = ecs.MakeControllerDeploymentStrategy()
+		err = r.client.Update(context.TODO(), deployment)
+		if err != nil {
+			return fmt.Errorf("failed to update deployment (%s): %v", deployment.Name, err)
+		}
+
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	current := p.Status.GetRestartStatus(restartComponentController)
+	if current == nil || current.RestartedAt != restartedAt || current.Phase == ecsv1alpha1.RestartPhaseCompleted {
+		return nil
+	}
+
+	status.RestartedReplicas = deployment.Status.UpdatedReplicas
+	if status.RestartedReplicas > replicas {
+		status.RestartedReplicas = replicas
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation ||
+		deployment.Status.UpdatedReplicas < replicas ||
+		deployment.Status.Replicas > replicas ||
+		deployment.Status.AvailableReplicas < replicas {
+		status.Message = "waiting for the deployment to roll out"
+		p.Status.SetRestartStatus(status)
+		return nil
+	}
+
+	log.Printf("completed rolling restart of deployment (%s)", deployment.Name)
+	status.Phase = ecsv1alpha1.RestartPhaseCompleted
+	p.Status.SetRestartStatus(status)
+	return nil
+}
+
+// setRestartedAtAnnotation sets the restartedAt annotation of a pod template
+func setRestartedAtAnnotation(annotations *map[string]string, restartedAt string) {
+	if *annotations == nil {
+		*annotations = map[string]string{}
+	}
+	(*annotations)[util.RestartedAtAnnotation] = restartedAt
+}
@@ -0,0 +1,241 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rolling restart", func() {
+	var (
+		s       = scheme.Scheme
+		p       *v1alpha1.ECSCluster
+		sts     *appsv1.StatefulSet
+		pdb     *policyv1beta1.PodDisruptionBudget
+		restart statefulSetRestart
+		blocked string
+		r       *ReconcileECSCluster
+		client  client.Client
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+
+		replicas := int32(3)
+		sts = ecs.MakeBookieStatefulSet(p)
+		sts.Spec.Replicas = &replicas
+		sts.Status.ReadyReplicas = replicas
+
+		pdb = ecs.MakeBookiePodDisruptionBudget(p)
+		pdb.Status.PodDisruptionsAllowed = 1
+
+		blocked = ""
+		restart = statefulSetRestart{
+			component:   restartComponentBookie,
+			stsName:     sts.Name,
+			pdbName:     pdb.Name,
+			restartedAt: "t1",
+			cordoned:    func(int32) bool { return false },
+			blocker: func(*v1alpha1.ECSCluster) (string, error) {
+				return blocked, nil
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(p, sts, pdb)
+		r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+	})
+
+	getStatefulSet := func() *appsv1.StatefulSet {
+		current := &appsv1.StatefulSet{}
+		Ω(client.Get(context.TODO(), types.NamespacedName{Name: sts.Name, Namespace: p.Namespace}, current)).Should(Succeed())
+		return current
+	}
+
+	getPartition := func() int32 {
+		return *getStatefulSet().Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+
+	// rollingOut holds the stateful-set at a partition with the pods above it
+	// already restarted
+	rollingOut := func(partition int32) {
+		sts.Spec.Template.Annotations = map[string]string{util.RestartedAtAnnotation: "t1"}
+		sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+			Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition: &partition,
+			},
+		}
+		sts.Status.UpdatedReplicas = *sts.Spec.Replicas - partition
+		p.Status.SetRestartStatus(v1alpha1.RestartStatus{
+			Component:   restartComponentBookie,
+			RestartedAt: "t1",
+			Phase:       v1alpha1.RestartPhaseInProgress,
+			Replicas:    *sts.Spec.Replicas,
+		})
+	}
+
+	Context("Start", func() {
+		It("should hold every pod at the current revision", func() {
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getStatefulSet().Spec.Template.Annotations).Should(HaveKeyWithValue(util.RestartedAtAnnotation, "t1"))
+			Ω(getPartition()).Should(BeEquivalentTo(3))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Phase).Should(Equal(v1alpha1.RestartPhaseInProgress))
+		})
+	})
+
+	Context("Cleared restartedAt", func() {
+		BeforeEach(func() {
+			restart.restartedAt = ""
+		})
+
+		It("should keep the annotation", func() {
+			sts.Spec.Template.Annotations = map[string]string{util.RestartedAtAnnotation: "t1"}
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getStatefulSet().Spec.Template.Annotations).Should(HaveKeyWithValue(util.RestartedAtAnnotation, "t1"))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie)).Should(BeNil())
+		})
+
+		It("should not start a restart", func() {
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getStatefulSet().Spec.Template.Annotations).ShouldNot(HaveKey(util.RestartedAtAnnotation))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie)).Should(BeNil())
+		})
+	})
+
+	Context("Partition", func() {
+		It("should restart the highest ordinal first", func() {
+			rollingOut(3)
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(2))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("waiting for pod (example-bookie-2) to restart"))
+		})
+
+		It("should wait for the restarted pod", func() {
+			rollingOut(2)
+			sts.Status.UpdatedReplicas = 0
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(2))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("waiting for pod (example-bookie-2) to restart"))
+		})
+
+		It("should wait for all pods to be ready", func() {
+			rollingOut(2)
+			sts.Status.ReadyReplicas = 2
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(2))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("waiting for all pods to be ready"))
+		})
+
+		It("should restart the next ordinal once the previous one is ready", func() {
+			rollingOut(2)
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(1))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).RestartedReplicas).Should(BeEquivalentTo(1))
+		})
+
+		It("should complete once every pod is restarted", func() {
+			rollingOut(0)
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Phase).Should(Equal(v1alpha1.RestartPhaseCompleted))
+		})
+
+		It("should hold a cordoned ordinal", func() {
+			rollingOut(2)
+			restart.cordoned = func(ordinal int32) bool { return ordinal == 1 }
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(2))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("pod (example-bookie-1) is under maintenance"))
+		})
+	})
+
+	Context("Pod disruption budget", func() {
+		BeforeEach(func() {
+			pdb.Status.PodDisruptionsAllowed = 0
+		})
+
+		It("should wait for a disruption to be allowed", func() {
+			rollingOut(3)
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(3))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("pdb (example-bookie) allows no disruption"))
+		})
+	})
+
+	Context("Under-replicated ledgers", func() {
+		BeforeEach(func() {
+			blocked = "2 ledgers are under-replicated"
+		})
+
+		It("should hold the restart", func() {
+			rollingOut(3)
+			Ω(r.restartStatefulSet(p, restart)).Should(Succeed())
+			Ω(getPartition()).Should(BeEquivalentTo(3))
+			Ω(p.Status.GetRestartStatus(restartComponentBookie).Message).Should(Equal("2 ledgers are under-replicated"))
+		})
+	})
+
+	Context("Controller", func() {
+		var deployment *appsv1.Deployment
+
+		BeforeEach(func() {
+			deployment = ecs.MakeControllerDeployment(p)
+			deployment.Spec.Template.Annotations = map[string]string{util.RestartedAtAnnotation: "t1"}
+		})
+
+		JustBeforeEach(func() {
+			Ω(client.Create(context.TODO(), deployment)).Should(Succeed())
+		})
+
+		getDeployment := func() *appsv1.Deployment {
+			current := &appsv1.Deployment{}
+			Ω(client.Get(context.TODO(), types.NamespacedName{Name: deployment.Name, Namespace: p.Namespace}, current)).Should(Succeed())
+			return current
+		}
+
+		It("should keep the annotation when restartedAt is cleared", func() {
+			p.Spec.ECS.ControllerRestartedAt = ""
+			Ω(r.restartController(p)).Should(Succeed())
+			Ω(getDeployment().Spec.Template.Annotations).Should(HaveKeyWithValue(util.RestartedAtAnnotation, "t1"))
+			Ω(p.Status.GetRestartStatus(restartComponentController)).Should(BeNil())
+		})
+
+		It("should roll out a new restartedAt", func() {
+			p.Spec.ECS.ControllerRestartedAt = "t2"
+			Ω(r.restartController(p)).Should(Succeed())
+			Ω(getDeployment().Spec.Template.Annotations).Should(HaveKeyWithValue(util.RestartedAtAnnotation, "t2"))
+			Ω(p.Status.GetRestartStatus(restartComponentController).Phase).Should(Equal(v1alpha1.RestartPhaseInProgress))
+		})
+	})
+})
@@ -0,0 +1,276 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// tier2CheckInterval is the minimum delay between two Tier 2 checks of a
+	// cluster, since they connect to external systems with long timeouts
+	tier2CheckInterval = 30 * time.Second
+
+	tier2ReasonCredentials = "CredentialsUnavailable"
+	tier2ReasonUnreachable = "Unreachable"
+	tier2ReasonVolume      = "VolumeUnavailable"
+)
+
+// tier2Error is a Tier 2 validation failure along with the condition reason
+type tier2Error struct {
+	reason string
+	err    error
+}
+
+func (e *tier2Error) Error() string {
+	return e.err.Error()
+}
+
+// reconcileTier2 validates the Tier 2 configuration and records the result in
+// the Tier2Reachable condition. Node rollout is blocked while it is false
+func (r *ReconcileECSCluster) reconcileTier2(p *ecsv1alpha1.ECSCluster) {
+	if !isTier2CheckDue(p) {
+		return
+	}
+
+	p.Status.Tier2 = &ecsv1alpha1.Tier2Status{
+		ObservedGeneration: p.Generation,
+		LastCheckTime:      time.Now().Format(time.RFC3339),
+	}
+
+	err := r.checkTier2(p)
+	if err == nil {
+		p.Status.SetTier2ReachableConditionTrue()
+		return
+	}
+
+	log.Printf("tier2 of ecs cluster (%s) is not reachable: %v", p.Name, err)
+	reason := tier2ReasonUnreachable
+	if e, ok := err.(*tier2Error); ok {
+		reason = e.reason
+	}
+	p.Status.SetTier2ReachableConditionFalse(reason, err.Error())
+}
+
+// isTier2CheckDue rate-limits the checks of a cluster, unless its spec
+// changed since the last one
+func isTier2CheckDue(p *ecsv1alpha1.ECSCluster) bool {
+	status := p.Status.Tier2
+	if status == nil || status.ObservedGeneration != p.Generation {
+		return true
+	}
+
+	if _, c := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionTier2Reachable); c == nil {
+		return true
+	}
+
+	lastCheckTime, err := time.Parse(time.RFC3339, status.LastCheckTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastCheckTime) >= tier2CheckInterval
+}
+
+func (r *ReconcileECSCluster) checkTier2(p *ecsv1alpha1.ECSCluster) error {
+	tier2 := p.Spec.ECS.Tier2
+
+	if tier2.FileSystem != nil {
+		return r.checkTier2Filesystem(p)
+	}
+
+	if tier2.ECS != nil {
+		config := util.S3BucketConfig{
+			Endpoint:        tier2.ECS.Uri,
+			Region:          ecsv1alpha1.DefaultS3Region,
+			Bucket:          tier2.ECS.Bucket,
+			PathStyleAccess: true,
+		}
+		return r.checkTier2Bucket(p, config, tier2.ECS.Credentials, "")
+	}
+
+	if tier2.S3 != nil {
+		config := util.S3BucketConfig{
+			Endpoint:        tier2.S3.Endpoint,
+			Region:          tier2.S3.Region,
+			Bucket:          tier2.S3.Bucket,
+			PathStyleAccess: tier2.S3.PathStyleAccess,
+			CreateBucket:    tier2.S3.CreateBucket,
+		}
+		return r.checkTier2Bucket(p, config, tier2.S3.Credentials, tier2.S3.CABundleSecret)
+	}
+
+	if tier2.Hdfs != nil {
+		if tier2.Hdfs.Kerberos != nil {
+			keytab := tier2.Hdfs.Kerberos.KeytabSecret
+			secret := &corev1.Secret{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Name: keytab, Namespace: p.Namespace}, secret)
+			if err != nil {
+				return &tier2Error{reason: tier2ReasonCredentials, err: fmt.Errorf("failed to get secret (%s): %v", keytab, err)}
+			}
+			if _, ok := secret.Data[tier2.Hdfs.Kerberos.KeytabKey]; !ok {
+				return &tier2Error{reason: tier2ReasonCredentials, err: fmt.Errorf("secret (%s) has no key %s", keytab, tier2.Hdfs.Kerberos.KeytabKey)}
+			}
+		}
+
+		if err := util.CheckHdfsReachable(tier2.Hdfs.Uri); err != nil {
+			return &tier2Error{reason: tier2ReasonUnreachable, err: err}
+		}
+	}
+
+	return nil
+}
+
+// deployTier2 creates the filesystem Tier 2 claim from its template when it
+// does not exist. The claim holds the Tier 2 data, so it is only owned by the
+// cluster, and deleted along with it, when requested
+func (r *ReconcileECSCluster) deployTier2(p *ecsv1alpha1.ECSCluster) (err error) {
+	fs := p.Spec.ECS.Tier2.FileSystem
+	if fs == nil || fs.PersistentVolumeClaim == nil || fs.VolumeClaimTemplate == nil {
+		return nil
+	}
+
+	pvc := ecs.MakeTier2PersistentVolumeClaim(p)
+	if fs.DeleteClaimWithCluster {
+		controllerutil.SetControllerReference(p, pvc, r.scheme)
+	}
+	err = r.client.Create(context.TODO(), pvc)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create tier2 pvc (%s): %v", pvc.Name, err)
+	}
+
+	current := &corev1.PersistentVolumeClaim{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: p.Namespace}, current)
+	if err != nil {
+		return fmt.Errorf("failed to get tier2 pvc (%s): %v", pvc.Name, err)
+	}
+	if r.syncTier2Owner(p, current, fs.DeleteClaimWithCluster) {
+		err = r.client.Update(context.TODO(), current)
+		if err != nil {
+			return fmt.Errorf("failed to update tier2 pvc (%s): %v", pvc.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncTier2Owner adds or removes the owner reference of the cluster on the
+// Tier 2 claim and reports whether it was changed. Claims of other owners are
+// left untouched
+func (r *ReconcileECSCluster) syncTier2Owner(p *ecsv1alpha1.ECSCluster, pvc *corev1.PersistentVolumeClaim, owned bool) (changed bool) {
+	var refs []metav1.OwnerReference
+	found := false
+	for _, ref := range pvc.OwnerReferences {
+		if ref.UID == p.UID {
+			found = true
+			if !owned {
+				changed = true
+				continue
+			}
+		}
+		refs = append(refs, ref)
+	}
+
+	if changed {
+		pvc.OwnerReferences = refs
+	}
+
+	if owned && !found && metav1.GetControllerOf(pvc) == nil {
+		controllerutil.SetControllerReference(p, pvc, r.scheme)
+		changed = true
+	}
+	return changed
+}
+
+func (r *ReconcileECSCluster) checkTier2Filesystem(p *ecsv1alpha1.ECSCluster) error {
+	fs := p.Spec.ECS.Tier2.FileSystem
+
+	if fs.NFS != nil {
+		if err := util.CheckNfsReachable(fs.NFS.Server); err != nil {
+			return &tier2Error{reason: tier2ReasonUnreachable, err: err}
+		}
+		return nil
+	}
+
+	claim := fs.PersistentVolumeClaim
+	if claim == nil {
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: claim.ClaimName, Namespace: p.Namespace}, pvc)
+	if err != nil {
+		return &tier2Error{reason: tier2ReasonVolume, err: fmt.Errorf("failed to get pvc (%s): %v", claim.ClaimName, err)}
+	}
+
+	if pvc.Status.Phase == corev1.ClaimBound {
+		return nil
+	}
+
+	// Claims of storage classes with delayed binding stay pending until
+	// the first node pod is scheduled
+	if pvc.Status.Phase == corev1.ClaimPending && pvc.Spec.StorageClassName != nil {
+		sc := &storagev1.StorageClass{}
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: *pvc.Spec.StorageClassName}, sc)
+		if err == nil && sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+			return nil
+		}
+	}
+
+	return &tier2Error{reason: tier2ReasonVolume, err: fmt.Errorf("pvc (%s) is not bound", claim.ClaimName)}
+}
+
+func (r *ReconcileECSCluster) checkTier2Bucket(p *ecsv1alpha1.ECSCluster, config util.S3BucketConfig, credentials string, caBundle string) error {
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: credentials, Namespace: p.Namespace}, secret)
+	if err != nil {
+		return &tier2Error{reason: tier2ReasonCredentials, err: fmt.Errorf("failed to get secret (%s): %v", credentials, err)}
+	}
+
+	config.AccessKeyId = string(secret.Data[util.Tier2AccessKeyIdKey])
+	config.SecretKey = string(secret.Data[util.Tier2SecretKeyKey])
+	if config.AccessKeyId == "" || config.SecretKey == "" {
+		return &tier2Error{
+			reason: tier2ReasonCredentials,
+			err:    fmt.Errorf("secret (%s) must contain the keys %s and %s", credentials, util.Tier2AccessKeyIdKey, util.Tier2SecretKeyKey),
+		}
+	}
+
+	if caBundle != "" {
+		caSecret := &corev1.Secret{}
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: caBundle, Namespace: p.Namespace}, caSecret)
+		if err != nil {
+			return &tier2Error{reason: tier2ReasonCredentials, err: fmt.Errorf("failed to get secret (%s): %v", caBundle, err)}
+		}
+		config.CABundle = caSecret.Data[ecsv1alpha1.DefaultS3CABundleKey]
+	}
+
+	if err = util.CheckS3Bucket(config); err != nil {
+		return &tier2Error{reason: tier2ReasonUnreachable, err: err}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tier 2 check", func() {
+	var p *v1alpha1.ECSCluster
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "example",
+				Namespace:  "default",
+				Generation: 2,
+			},
+		}
+		p.Status.SetTier2ReachableConditionTrue()
+		p.Status.Tier2 = &v1alpha1.Tier2Status{
+			ObservedGeneration: 2,
+			LastCheckTime:      time.Now().Format(time.RFC3339),
+		}
+	})
+
+	It("should skip a recent check", func() {
+		Ω(isTier2CheckDue(p)).Should(BeFalse())
+	})
+
+	It("should check again after the interval", func() {
+		p.Status.Tier2.LastCheckTime = time.Now().Add(-tier2CheckInterval).Format(time.RFC3339)
+		Ω(isTier2CheckDue(p)).Should(BeTrue())
+	})
+
+	It("should check again when the spec changed", func() {
+		p.Generation = 3
+		Ω(isTier2CheckDue(p)).Should(BeTrue())
+	})
+
+	It("should check without a previous result", func() {
+		p.Status.Tier2 = nil
+		Ω(isTier2CheckDue(p)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Tier 2 claim", func() {
+	var (
+		s      = scheme.Scheme
+		p      *v1alpha1.ECSCluster
+		r      *ReconcileECSCluster
+		client client.Client
+		nn     types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+				UID:       "example-uid",
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+		p.Spec.ECS.Tier2.FileSystem.VolumeClaimTemplate = &corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("10Gi"),
+				},
+			},
+		}
+		nn = types.NamespacedName{Name: v1alpha1.DefaultECSTier2ClaimName, Namespace: p.Namespace}
+	})
+
+	getClaim := func() *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Ω(client.Get(context.TODO(), nn, pvc)).Should(BeNil())
+		return pvc
+	}
+
+	Context("Missing claim", func() {
+		BeforeEach(func() {
+			client = fake.NewFakeClient(p)
+			r = &ReconcileECSCluster{client: client, scheme: s}
+		})
+
+		It("should create an unowned claim from the template", func() {
+			Ω(r.deployTier2(p)).Should(BeNil())
+			pvc := getClaim()
+			Ω(pvc.Spec.AccessModes).Should(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}))
+			Ω(pvc.OwnerReferences).Should(BeEmpty())
+		})
+
+		It("should create an owned claim on request", func() {
+			p.Spec.ECS.Tier2.FileSystem.DeleteClaimWithCluster = true
+			Ω(r.deployTier2(p)).Should(BeNil())
+			pvc := getClaim()
+			Ω(pvc.OwnerReferences).Should(HaveLen(1))
+			Ω(pvc.OwnerReferences[0].UID).Should(Equal(p.UID))
+		})
+	})
+
+	Context("Claim owned by the cluster", func() {
+		BeforeEach(func() {
+			controller := true
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      nn.Name,
+					Namespace: nn.Namespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "ecs.ecs.io/v1alpha1", Kind: "ECSCluster", Name: p.Name, UID: p.UID, Controller: &controller},
+					},
+				},
+			}
+			client = fake.NewFakeClient(p, pvc)
+			r = &ReconcileECSCluster{client: client, scheme: s}
+		})
+
+		It("should release the claim", func() {
+			Ω(r.deployTier2(p)).Should(BeNil())
+			Ω(getClaim().OwnerReferences).Should(BeEmpty())
+		})
+
+		It("should keep the claim owned on request", func() {
+			p.Spec.ECS.Tier2.FileSystem.DeleteClaimWithCluster = true
+			Ω(r.deployTier2(p)).Should(BeNil())
+			Ω(getClaim().OwnerReferences).Should(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ecsv1alpha1 "github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// zookeeperProbeInterval is the minimum delay between two ZooKeeper probes
+	// of a cluster
+	zookeeperProbeInterval = 30 * time.Second
+
+	zookeeperReasonUnreachable     = "Unreachable"
+	zookeeperReasonMetadataMissing = "MetadataMissing"
+
+	// auditorCheckInterval is the minimum delay between two lookups of the
+	// auditor of a cluster
+	auditorCheckInterval = 30 * time.Second
+
+	// ledgerCheckInterval is the minimum delay between two counts of the
+	// under-replicated ledgers of a cluster, which walk every znode of the
+	// under-replication tree
+	ledgerCheckInterval = 30 * time.Second
+)
+
+// zookeeperReader reads the BookKeeper metadata of a cluster in ZooKeeper
+type zookeeperReader interface {
+	// GetAuditor returns the bookie ID of the elected auditor, or an empty
+	// string if there is none
+	GetAuditor(p *ecsv1alpha1.ECSCluster) (string, error)
+
+	// GetBookieRegistrations returns the IDs of the writable and of the
+	// read-only bookies
+	GetBookieRegistrations(p *ecsv1alpha1.ECSCluster) (available []string, readOnly []string, err error)
+
+	// CountUnderReplicatedLedgers returns the number of ledgers waiting to be
+	// replicated
+	CountUnderReplicatedLedgers(p *ecsv1alpha1.ECSCluster) (int, error)
+}
+
+// utilZookeeperReader reads ZooKeeper with the helpers of the util package
+type utilZookeeperReader struct{}
+
+func (utilZookeeperReader) GetAuditor(p *ecsv1alpha1.ECSCluster) (string, error) {
+	return util.GetAuditor(p)
+}
+
+func (utilZookeeperReader) GetBookieRegistrations(p *ecsv1alpha1.ECSCluster) ([]string, []string, error) {
+	return util.GetBookieRegistrations(p)
+}
+
+func (utilZookeeperReader) CountUnderReplicatedLedgers(p *ecsv1alpha1.ECSCluster) (int, error) {
+	return util.CountUnderReplicatedLedgers(p)
+}
+
+// reconcileZookeeper probes ZooKeeper and records the result in the
+// ZookeeperReachable condition. Bookie and node rollout is blocked while it is
+// false. The root znode of the cluster is created by the bookies, so it is
+// only required once they started
+func (r *ReconcileECSCluster) reconcileZookeeper(p *ecsv1alpha1.ECSCluster) error {
+	if !r.isZookeeperProbeDue(p) {
+		return nil
+	}
+
+	sts := &appsv1.StatefulSet{}
+	name := util.StatefulSetNameForBookie(p.Name)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, sts)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get stateful-set (%s): %v", name, err)
+	}
+	bookiesStarted := err == nil && sts.Status.ReadyReplicas > 0
+
+	p.Status.Zookeeper = &ecsv1alpha1.ZookeeperStatus{
+		ZookeeperUri:  p.Spec.ZookeeperUri,
+		LastProbeTime: time.Now().Format(time.RFC3339),
+	}
+
+	latency, exist, err := r.probeZookeeper(p)
+	if err != nil {
+		log.Printf("zookeeper of ecs cluster (%s) is not reachable: %v", p.Name, err)
+		p.Status.SetZookeeperReachableConditionFalse(zookeeperReasonUnreachable, err.Error())
+		return nil
+	}
+	p.Status.Zookeeper.LatencyMilliseconds = int64(latency / time.Millisecond)
+
+	if bookiesStarted && !exist {
+		message := fmt.Sprintf("znode (/%s/%s) does not exist", util.ECSPath, p.Name)
+		log.Printf("zookeeper of ecs cluster (%s) has no metadata: %s", p.Name, message)
+		p.Status.SetZookeeperReachableConditionFalse(zookeeperReasonMetadataMissing, message)
+		return nil
+	}
+
+	p.Status.SetZookeeperReachableConditionTrue()
+	return nil
+}
+
+// isZookeeperProbeDue rate-limits the probes of a cluster, unless its
+// ZooKeeper URI changed since the last one
+func (r *ReconcileECSCluster) isZookeeperProbeDue(p *ecsv1alpha1.ECSCluster) bool {
+	status := p.Status.Zookeeper
+	if status == nil || status.ZookeeperUri != p.Spec.ZookeeperUri {
+		return true
+	}
+
+	if _, c := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionZookeeperReachable); c == nil {
+		return true
+	}
+
+	lastProbeTime, err := time.Parse(time.RFC3339, status.LastProbeTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastProbeTime) >= zookeeperProbeInterval
+}
+
+// isAuditorCheckDue rate-limits the lookups of the auditor of a cluster
+func isAuditorCheckDue(p *ecsv1alpha1.ECSCluster) bool {
+	status := p.Status.Auditor
+	if status == nil {
+		return true
+	}
+
+	if _, c := p.Status.GetClusterCondition(ecsv1alpha1.ClusterConditionAuditorElected); c == nil {
+		return true
+	}
+
+	lastCheckTime, err := time.Parse(time.RFC3339, status.LastCheckTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastCheckTime) >= auditorCheckInterval
+}
+
+// isLedgerCheckDue rate-limits the counts of the under-replicated ledgers of
+// a cluster
+func isLedgerCheckDue(p *ecsv1alpha1.ECSCluster) bool {
+	status := p.Status.Bookkeeper
+	if status == nil {
+		return true
+	}
+
+	lastCheckTime, err := time.Parse(time.RFC3339, status.LastLedgerCheckTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastCheckTime) >= ledgerCheckInterval
+}
@@ -0,0 +1,199 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package ecscluster
+
+import (
+	"context"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	"github.com/ecs/ecs-operator/pkg/controller/ecs"
+	"github.com/ecs/ecs-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Embedded ZooKeeper", func() {
+	var (
+		s       = scheme.Scheme
+		p       *v1alpha1.ECSCluster
+		objects []runtime.Object
+		r       *ReconcileECSCluster
+		client  client.Client
+	)
+
+	BeforeEach(func() {
+		p = &v1alpha1.ECSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ClusterSpec{
+				Zookeeper: &v1alpha1.ZookeeperSpec{},
+			},
+		}
+		s.AddKnownTypes(v1alpha1.SchemeGroupVersion, p)
+		p.WithDefaults()
+		objects = nil
+	})
+
+	JustBeforeEach(func() {
+		client = fake.NewFakeClient(append(objects, p)...)
+		r = &ReconcileECSCluster{client: client, scheme: s, probeZookeeper: reachableZookeeper, zookeeper: &fakeZookeeper{}}
+	})
+
+	// readyServers creates the ensemble with some of its servers ready
+	readyServers := func(ready int32) {
+		sts := ecs.MakeZookeeperStatefulSet(p)
+		sts.Status.ReadyReplicas = ready
+		objects = append(objects, sts)
+	}
+
+	Context("Resources", func() {
+		It("should create the ensemble", func() {
+			_, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+
+			key := func(name string) types.NamespacedName {
+				return types.NamespacedName{Name: name, Namespace: p.Namespace}
+			}
+			Ω(client.Get(context.TODO(), key(util.HeadlessServiceNameForZookeeper(p.Name)), &corev1.Service{})).Should(Succeed())
+			Ω(client.Get(context.TODO(), key(util.ClientServiceNameForZookeeper(p.Name)), &corev1.Service{})).Should(Succeed())
+			Ω(client.Get(context.TODO(), key(util.PdbNameForZookeeper(p.Name)), &policyv1beta1.PodDisruptionBudget{})).Should(Succeed())
+
+			sts := &appsv1.StatefulSet{}
+			Ω(client.Get(context.TODO(), key(util.StatefulSetNameForZookeeper(p.Name)), sts)).Should(Succeed())
+			Ω(sts.OwnerReferences).Should(HaveLen(1))
+			Ω(sts.OwnerReferences[0].Name).Should(Equal(p.Name))
+		})
+
+		It("should not deploy an ensemble without a zookeeper section", func() {
+			p.Spec.Zookeeper = nil
+			quorum, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+			Ω(quorum).Should(BeTrue())
+
+			sts := &appsv1.StatefulSet{}
+			err = client.Get(context.TODO(), types.NamespacedName{Name: util.StatefulSetNameForZookeeper(p.Name), Namespace: p.Namespace}, sts)
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Quorum", func() {
+		It("should wait for new servers", func() {
+			quorum, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+			Ω(quorum).Should(BeFalse())
+		})
+
+		Context("Without a quorum", func() {
+			BeforeEach(func() {
+				readyServers(1)
+			})
+
+			It("should wait for a majority of the servers", func() {
+				quorum, err := r.deployZookeeper(p)
+				Ω(err).Should(BeNil())
+				Ω(quorum).Should(BeFalse())
+			})
+		})
+
+		Context("With a quorum", func() {
+			BeforeEach(func() {
+				readyServers(2)
+			})
+
+			It("should deploy the other components", func() {
+				quorum, err := r.deployZookeeper(p)
+				Ω(err).Should(BeNil())
+				Ω(quorum).Should(BeTrue())
+			})
+		})
+	})
+
+	Context("Spec changes", func() {
+		BeforeEach(func() {
+			readyServers(3)
+			objects = append(objects, ecs.MakeZookeeperPodDisruptionBudget(p))
+		})
+
+		key := func(name string) types.NamespacedName {
+			return types.NamespacedName{Name: name, Namespace: p.Namespace}
+		}
+
+		getStatefulSet := func() *appsv1.StatefulSet {
+			sts := &appsv1.StatefulSet{}
+			Ω(client.Get(context.TODO(), key(util.StatefulSetNameForZookeeper(p.Name)), sts)).Should(Succeed())
+			return sts
+		}
+
+		It("should not change an ensemble that matches the spec", func() {
+			Ω(zookeeperChanged(getStatefulSet(), ecs.MakeZookeeperStatefulSet(p))).Should(BeFalse())
+		})
+
+		It("should roll out a new image and resources", func() {
+			p.Spec.Zookeeper.Image.Tag = "3.5.5"
+			p.Spec.Zookeeper.Resources.Limits[corev1.ResourceMemory] = resource.MustParse("4Gi")
+			_, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+
+			container := getStatefulSet().Spec.Template.Spec.Containers[0]
+			Ω(container.Image).Should(HaveSuffix(":3.5.5"))
+			Ω(container.Resources.Limits.Memory().String()).Should(Equal("4Gi"))
+		})
+
+		It("should roll out the servers of a resized ensemble", func() {
+			p.Spec.Zookeeper.Replicas = 5
+			quorum, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+			Ω(quorum).Should(BeTrue())
+
+			sts := getStatefulSet()
+			Ω(*sts.Spec.Replicas).Should(BeEquivalentTo(5))
+			Ω(sts.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{
+				Name:  "ZOO_SERVERS",
+				Value: zookeeperServersFor(p),
+			}))
+
+			pdb := &policyv1beta1.PodDisruptionBudget{}
+			Ω(client.Get(context.TODO(), key(util.PdbNameForZookeeper(p.Name)), pdb)).Should(Succeed())
+			Ω(pdb.Spec.MinAvailable.IntValue()).Should(Equal(3))
+		})
+
+		It("should wait for the quorum of the resized ensemble", func() {
+			p.Spec.Zookeeper.Replicas = 7
+			quorum, err := r.deployZookeeper(p)
+			Ω(err).Should(BeNil())
+			Ω(quorum).Should(BeFalse())
+		})
+	})
+})
+
+// zookeeperServersFor returns the ZOO_SERVERS of the ensemble of a cluster
+func zookeeperServersFor(p *v1alpha1.ECSCluster) string {
+	for _, env := range ecs.MakeZookeeperStatefulSet(p).Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "ZOO_SERVERS" {
+			return env.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+// Package health tracks the state of the operator process and serves it over
+// HTTP for the Deployment probes
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StallTimeout is the time after which a reconciliation that has not
+// finished is considered stuck, failing the liveness check
+var StallTimeout = 5 * time.Minute
+
+var state = struct {
+	sync.Mutex
+	leader  bool
+	caches  map[string]bool
+	running map[string]time.Time
+	failed  map[string]error
+	stopped map[string]error
+}{
+	caches:  make(map[string]bool),
+	running: make(map[string]time.Time),
+	failed:  make(map[string]error),
+	stopped: make(map[string]error),
+}
+
+// SetLeader records that the operator acquired the leader lock
+func SetLeader() {
+	state.Lock()
+	defer state.Unlock()
+	state.leader = true
+}
+
+// AddCache registers the cache of a manager. The operator is not ready until
+// every registered cache is synced
+func AddCache(name string) {
+	state.Lock()
+	defer state.Unlock()
+	state.caches[name] = false
+}
+
+// SetCacheSynced records that the cache of a manager is synced
+func SetCacheSynced(name string) {
+	state.Lock()
+	defer state.Unlock()
+	state.caches[name] = true
+}
+
+// SetManagerFailed records that the manager of a cache exited with an error.
+// A failed manager stops serving its namespaces for good, so it fails the
+// liveness check to have the operator restarted
+func SetManagerFailed(name string, err error) {
+	state.Lock()
+	defer state.Unlock()
+	state.stopped[name] = err
+}
+
+// ReconcileStarted records the start of the reconciliation of a resource
+func ReconcileStarted(key string) {
+	state.Lock()
+	defer state.Unlock()
+	state.running[key] = time.Now()
+}
+
+// ReconcileFinished records the end of the reconciliation of a resource and
+// its result. The error is kept until the next reconciliation of the same
+// resource
+func ReconcileFinished(key string, err error) {
+	state.Lock()
+	defer state.Unlock()
+	delete(state.running, key)
+	if err != nil {
+		state.failed[key] = err
+	} else {
+		delete(state.failed, key)
+	}
+}
+
+// checkLive returns an error if a manager failed or a reconciliation is
+// stuck
+func checkLive() error {
+	state.Lock()
+	defer state.Unlock()
+	var stopped []string
+	for name, err := range state.stopped {
+		stopped = append(stopped, fmt.Sprintf("%q: %v", name, err))
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("managers failed: %v", stopped)
+	}
+
+	for key, started := range state.running {
+		if time.Since(started) > StallTimeout {
+			return fmt.Errorf("reconciliation of %s running for %v", key, time.Since(started).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// checkReady returns an error unless the operator is the leader and all its
+// caches are synced. A cluster that fails to reconcile does not make the
+// operator unready, since the operator keeps serving the other clusters
+func checkReady() error {
+	state.Lock()
+	defer state.Unlock()
+	if !state.leader {
+		return fmt.Errorf("leader lock not acquired")
+	}
+
+	var unsynced []string
+	for name, synced := range state.caches {
+		if !synced {
+			unsynced = append(unsynced, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(unsynced) > 0 {
+		sort.Strings(unsynced)
+		return fmt.Errorf("caches not synced: %v", unsynced)
+	}
+
+	return nil
+}
+
+// failedReconciles returns the resources whose last reconciliation failed and
+// the error, sorted by key
+func failedReconciles() []string {
+	state.Lock()
+	defer state.Unlock()
+	var failed []string
+	for key, err := range state.failed {
+		failed = append(failed, fmt.Sprintf("%s: %v", key, err))
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+func handleCheck(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// handleReady reports the readiness of the operator, followed by the
+// resources whose last reconciliation failed for information
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if err := checkReady(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+	for _, failed := range failedReconciles() {
+		fmt.Fprintf(w, "reconciliation failed: %s\n", failed)
+	}
+}
+
+// Handler serves /healthz and /readyz, and the pprof endpoints under
+// /debug/pprof/ if enablePprof is set
+func Handler(enablePprof bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", handleCheck(checkLive))
+	mux.HandleFunc("/readyz", handleReady)
+
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
@@ -0,0 +1,117 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		Handler(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	BeforeEach(func() {
+		state.Lock()
+		state.leader = false
+		state.caches = make(map[string]bool)
+		state.running = make(map[string]time.Time)
+		state.failed = make(map[string]error)
+		state.stopped = make(map[string]error)
+		state.Unlock()
+	})
+
+	Context("Readiness", func() {
+		It("should not be ready without the leader lock", func() {
+			Ω(get("/readyz").Code).Should(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("should not be ready until every cache is synced", func() {
+			SetLeader()
+			AddCache("ns-a")
+			AddCache("ns-b")
+			SetCacheSynced("ns-a")
+			w := get("/readyz")
+			Ω(w.Code).Should(Equal(http.StatusServiceUnavailable))
+			Ω(w.Body.String()).Should(ContainSubstring(`"ns-b"`))
+
+			SetCacheSynced("ns-b")
+			Ω(get("/readyz").Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should stay ready when a cluster fails to reconcile", func() {
+			SetLeader()
+			ReconcileStarted("default/example")
+			ReconcileFinished("default/example", fmt.Errorf("zookeeper unreachable"))
+			ReconcileStarted("default/other")
+			ReconcileFinished("default/other", nil)
+
+			w := get("/readyz")
+			Ω(w.Code).Should(Equal(http.StatusOK))
+			Ω(w.Body.String()).Should(ContainSubstring("default/example: zookeeper unreachable"))
+			Ω(w.Body.String()).ShouldNot(ContainSubstring("default/other"))
+		})
+
+		It("should clear the error once the cluster reconciles", func() {
+			SetLeader()
+			ReconcileFinished("default/example", fmt.Errorf("zookeeper unreachable"))
+			ReconcileFinished("default/example", nil)
+			Ω(get("/readyz").Body.String()).Should(Equal("ok\n"))
+		})
+	})
+
+	Context("Liveness", func() {
+		It("should be live without running reconciliations", func() {
+			Ω(get("/healthz").Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should not be live when a reconciliation is stuck", func() {
+			state.Lock()
+			state.running["default/example"] = time.Now().Add(-2 * StallTimeout)
+			state.Unlock()
+			w := get("/healthz")
+			Ω(w.Code).Should(Equal(http.StatusServiceUnavailable))
+			Ω(w.Body.String()).Should(ContainSubstring("default/example"))
+		})
+
+		It("should be live once the reconciliation finishes", func() {
+			ReconcileStarted("default/example")
+			ReconcileFinished("default/example", nil)
+			Ω(get("/healthz").Code).Should(Equal(http.StatusOK))
+		})
+
+		It("should not be live when a manager failed", func() {
+			AddCache("ns-a")
+			SetManagerFailed("ns-a", fmt.Errorf("cache failed"))
+			w := get("/healthz")
+			Ω(w.Code).Should(Equal(http.StatusServiceUnavailable))
+			Ω(w.Body.String()).Should(ContainSubstring(`"ns-a": cache failed`))
+		})
+	})
+
+	Context("Profiling", func() {
+		It("should only serve pprof when enabled", func() {
+			Ω(get("/debug/pprof/").Code).Should(Equal(http.StatusNotFound))
+
+			w := httptest.NewRecorder()
+			Handler(true).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+			Ω(w.Code).Should(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+)
+
+const (
+	// ControllerHealthUp is the status of a healthy controller
+	ControllerHealthUp = "UP"
+
+	controllerTimeout = 5 * time.Second
+
+	controllerSegmentStoresPath = "/v1/cluster/segmentstores"
+	controllerContainersPath    = "/v1/cluster/containers"
+)
+
+// ControllerClient queries the REST API of the ECS controllers
+type ControllerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// ControllerHealth is the health of the controller serving the request
+type ControllerHealth struct {
+	// Status is one of UP, DOWN, STARTING, NEW, TERMINATED or UNKNOWN
+	Status    string `json:"status"`
+	Readiness bool   `json:"readiness"`
+	Liveness  bool   `json:"liveness"`
+}
+
+type segmentStoresResponse struct {
+	SegmentStores []string `json:"segmentStores"`
+}
+
+type containersResponse struct {
+	Containers map[string][]int32 `json:"containers"`
+}
+
+// NewControllerClient returns a client of the controller REST API at baseURL,
+// e.g. the URL returned by ECSControllerRESTURL
+func NewControllerClient(baseURL string) *ControllerClient {
+	return &ControllerClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: controllerTimeout},
+	}
+}
+
+// Health returns the health of a controller. Unhealthy controllers answer
+// with 503 Service Unavailable along with their health
+func (c *ControllerClient) Health() (*ControllerHealth, error) {
+	health := &ControllerHealth{}
+	err := c.get(v1alpha1.ControllerHealthPath, health, http.StatusOK, http.StatusServiceUnavailable)
+	if err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// SegmentStores returns the segment stores registered with the controllers,
+// as host:port
+func (c *ControllerClient) SegmentStores() ([]string, error) {
+	response := &segmentStoresResponse{}
+	err := c.get(controllerSegmentStoresPath, response, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(response.SegmentStores)
+	return response.SegmentStores, nil
+}
+
+// ContainerAssignment returns the segment containers assigned to each
+// segment store, keyed by host:port
+func (c *ControllerClient) ContainerAssignment() (map[string][]int32, error) {
+	response := &containersResponse{}
+	err := c.get(controllerContainersPath, response, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return response.Containers, nil
+}
+
+func (c *ControllerClient) get(path string, v interface{}, statusCodes ...int) error {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	expected := false
+	for _, statusCode := range statusCodes {
+		expected = expected || resp.StatusCode == statusCode
+	}
+	if !expected {
+		return fmt.Errorf("failed to get %s: %s", path, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestUtil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Util")
+}
+
+var _ = Describe("ControllerClient", func() {
+	var (
+		server           *httptest.Server
+		controllerClient *ControllerClient
+		responses        map[string]func(w http.ResponseWriter)
+	)
+
+	BeforeEach(func() {
+		responses = map[string]func(w http.ResponseWriter){}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			respond, ok := responses[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			respond(w)
+		}))
+		controllerClient = NewControllerClient(server.URL + "/")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Health", func() {
+		It("should return the health of a healthy controller", func() {
+			responses["/health"] = func(w http.ResponseWriter) {
+				fmt.Fprint(w, `{"name": "controller", "status": "UP", "readiness": true, "liveness": true}`)
+			}
+			health, err := controllerClient.Health()
+			Ω(err).Should(BeNil())
+			Ω(health.Status).Should(Equal(ControllerHealthUp))
+			Ω(health.Readiness).Should(BeTrue())
+		})
+
+		It("should return the health of an unhealthy controller", func() {
+			responses["/health"] = func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"status": "DOWN", "readiness": false, "liveness": true}`)
+			}
+			health, err := controllerClient.Health()
+			Ω(err).Should(BeNil())
+			Ω(health.Status).Should(Equal("DOWN"))
+		})
+
+		It("should fail on other errors", func() {
+			responses["/health"] = func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			_, err := controllerClient.Health()
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("SegmentStores", func() {
+		It("should return the sorted segment stores", func() {
+			responses["/v1/cluster/segmentstores"] = func(w http.ResponseWriter) {
+				fmt.Fprint(w, `{"segmentStores": ["10.0.0.2:12345", "10.0.0.1:12345"]}`)
+			}
+			segmentStores, err := controllerClient.SegmentStores()
+			Ω(err).Should(BeNil())
+			Ω(segmentStores).Should(Equal([]string{"10.0.0.1:12345", "10.0.0.2:12345"}))
+		})
+
+		It("should fail on invalid responses", func() {
+			responses["/v1/cluster/segmentstores"] = func(w http.ResponseWriter) {
+				fmt.Fprint(w, `not json`)
+			}
+			_, err := controllerClient.SegmentStores()
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("ContainerAssignment", func() {
+		It("should return the containers of each segment store", func() {
+			responses["/v1/cluster/containers"] = func(w http.ResponseWriter) {
+				fmt.Fprint(w, `{"containers": {"10.0.0.1:12345": [0, 2], "10.0.0.2:12345": [1, 3]}}`)
+			}
+			assignment, err := controllerClient.ContainerAssignment()
+			Ω(err).Should(BeNil())
+			Ω(assignment).Should(Equal(map[string][]int32{
+				"10.0.0.1:12345": {0, 2},
+				"10.0.0.2:12345": {1, 3},
+			}))
+		})
+	})
+
+	Context("Unreachable controller", func() {
+		It("should fail", func() {
+			server.Close()
+			_, err := controllerClient.Health()
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+})
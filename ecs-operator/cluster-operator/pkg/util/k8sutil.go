@@ -112,3 +112,20 @@ func IsPodReady(pod *corev1.Pod) bool {
 	}
 	return false
 }
+
+// NodeAddress returns the external IP address of a Kubernetes node, falling
+// back to its internal IP address when no external one is reported
+func NodeAddress(node *corev1.Node) string {
+	var internal string
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case corev1.NodeExternalIP:
+			return address.Address
+		case corev1.NodeInternalIP:
+			if internal == "" {
+				internal = address.Address
+			}
+		}
+	}
+	return internal
+}
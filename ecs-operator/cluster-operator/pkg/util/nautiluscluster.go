@@ -12,6 +12,7 @@ package util
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -30,6 +31,14 @@ func StatefulSetNameForBookie(clusterName string) string {
 	return fmt.Sprintf("%s-bookie", clusterName)
 }
 
+func DeploymentNameForAutoRecovery(clusterName string) string {
+	return fmt.Sprintf("%s-bookie-autorecovery", clusterName)
+}
+
+func PdbNameForAutoRecovery(clusterName string) string {
+	return fmt.Sprintf("%s-bookie-autorecovery", clusterName)
+}
+
 func PdbNameForController(clusterName string) string {
 	return fmt.Sprintf("%s-ecs-controller", clusterName)
 }
@@ -46,6 +55,22 @@ func ServiceNameForNode(clusterName string, index int32) string {
 	return fmt.Sprintf("%s-ecs-node-%d", clusterName, index)
 }
 
+// NodeOrdinalForService returns the ordinal of the ECS node exposed by a
+// per-node external service. The second return value is false when the
+// service name does not belong to a per-node external service of the cluster
+func NodeOrdinalForService(clusterName string, serviceName string) (int32, bool) {
+	prefix := fmt.Sprintf("%s-ecs-node-", clusterName)
+	if !strings.HasPrefix(serviceName, prefix) {
+		return 0, false
+	}
+
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(serviceName, prefix))
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
 func HeadlessServiceNameForNode(clusterName string) string {
 	return fmt.Sprintf("%s-ecs-node-headless", clusterName)
 }
@@ -70,12 +95,58 @@ func StatefulSetNameForNode(clusterName string) string {
 	return fmt.Sprintf("%s-ecs-node", clusterName)
 }
 
+func StatefulSetNameForZookeeper(clusterName string) string {
+	return fmt.Sprintf("%s-zookeeper", clusterName)
+}
+
+func HeadlessServiceNameForZookeeper(clusterName string) string {
+	return fmt.Sprintf("%s-zookeeper-headless", clusterName)
+}
+
+// ClientServiceNameForZookeeper is the name of the Service in the URI returned
+// by EmbeddedZookeeperUri
+func ClientServiceNameForZookeeper(clusterName string) string {
+	return fmt.Sprintf("%s-zookeeper-client", clusterName)
+}
+
+func PdbNameForZookeeper(clusterName string) string {
+	return fmt.Sprintf("%s-zookeeper", clusterName)
+}
+
+func NetworkPolicyNameForBookie(clusterName string) string {
+	return fmt.Sprintf("%s-bookie", clusterName)
+}
+
+func NetworkPolicyNameForController(clusterName string) string {
+	return fmt.Sprintf("%s-ecs-controller", clusterName)
+}
+
+func NetworkPolicyNameForNode(clusterName string) string {
+	return fmt.Sprintf("%s-ecs-node", clusterName)
+}
+
+func PodNameForNode(clusterName string, index int32) string {
+	return fmt.Sprintf("%s-%d", StatefulSetNameForNode(clusterName), index)
+}
+
 func LabelsForBookie(ecsCluster *v1alpha1.ECSCluster) map[string]string {
 	labels := LabelsForECSCluster(ecsCluster)
 	labels["component"] = "bookie"
 	return labels
 }
 
+func LabelsForAutoRecovery(ecsCluster *v1alpha1.ECSCluster) map[string]string {
+	labels := LabelsForECSCluster(ecsCluster)
+	labels["component"] = "bookie-autorecovery"
+	return labels
+}
+
+func LabelsForZookeeper(ecsCluster *v1alpha1.ECSCluster) map[string]string {
+	labels := LabelsForECSCluster(ecsCluster)
+	labels["component"] = "zookeeper"
+	return labels
+}
+
 func LabelsForController(ecsCluster *v1alpha1.ECSCluster) map[string]string {
 	labels := LabelsForECSCluster(ecsCluster)
 	labels["component"] = "ecs-controller"
@@ -88,6 +159,22 @@ func LabelsForNode(ecsCluster *v1alpha1.ECSCluster) map[string]string {
 	return labels
 }
 
+// ServiceLabelsForBookie returns the labels selected by the bookie
+// PodDisruptionBudget. Cordoned bookies do not have them
+func ServiceLabelsForBookie(ecsCluster *v1alpha1.ECSCluster) map[string]string {
+	labels := LabelsForBookie(ecsCluster)
+	labels[InServiceLabel] = "true"
+	return labels
+}
+
+// ServiceLabelsForNode returns the labels selected by the node
+// PodDisruptionBudget. Cordoned nodes do not have them
+func ServiceLabelsForNode(ecsCluster *v1alpha1.ECSCluster) map[string]string {
+	labels := LabelsForNode(ecsCluster)
+	labels[InServiceLabel] = "true"
+	return labels
+}
+
 func LabelsForECSCluster(ecsCluster *v1alpha1.ECSCluster) map[string]string {
 	return map[string]string{
 		"app":             "ecs-cluster",
@@ -95,6 +182,122 @@ func LabelsForECSCluster(ecsCluster *v1alpha1.ECSCluster) map[string]string {
 	}
 }
 
+// ClusterNameForLabels returns the name of the ECS cluster that a resource
+// belongs to, based on the labels set by LabelsForECSCluster
+func ClusterNameForLabels(labels map[string]string) (string, bool) {
+	if labels["app"] != "ecs-cluster" || labels["ecs_cluster"] == "" {
+		return "", false
+	}
+	return labels["ecs_cluster"], true
+}
+
+// PausedAnnotation stops the reconciliation of an ECSCluster when set to
+// "true", like its paused field
+const PausedAnnotation = "ecs.ecs.io/paused"
+
+// InServiceLabel is "true" on the bookie and node pods that are not under
+// maintenance. The client Services and the PodDisruptionBudgets select it,
+// while the StatefulSets and their governing Services do not, so that
+// cordoned pods keep running and keep their DNS names
+const InServiceLabel = "ecs.ecs.io/in-service"
+
+// ManagedAnnotationsAnnotation lists the annotations set by the operator on
+// the external node Services, so that the annotations added by others, e.g.
+// by cloud controllers, are left untouched
+const ManagedAnnotationsAnnotation = "ecs.ecs.io/managed-annotations"
+
+// RestartedAtAnnotation is set on the pod templates to the restartedAt field
+// of their component, so that changing the field restarts the pods
+const RestartedAtAnnotation = "ecs.ecs.io/restarted-at"
+
+// AnnotationsForRestart returns the pod template annotations for the
+// restartedAt field of a component
+func AnnotationsForRestart(restartedAt string) map[string]string {
+	if restartedAt == "" {
+		return nil
+	}
+	return map[string]string{RestartedAtAnnotation: restartedAt}
+}
+
+// IsPaused returns true while the reconciliation of the cluster is paused
+func IsPaused(p *v1alpha1.ECSCluster) bool {
+	return p.Spec.Paused || p.Annotations[PausedAnnotation] == "true"
+}
+
+// OrdinalForPod returns the ordinal of a pod of a StatefulSet. The second
+// return value is false when the pod does not belong to the StatefulSet
+func OrdinalForPod(stsName string, podName string) (int32, bool) {
+	prefix := fmt.Sprintf("%s-", stsName)
+	if !strings.HasPrefix(podName, prefix) {
+		return 0, false
+	}
+
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(podName, prefix))
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
+// QuiesceAnnotation is set on an ECSCluster while a backup stops its bookies
+// and nodes. Its value is the name of the backup. The cluster is not scaled
+// back up while it is set
+const QuiesceAnnotation = "ecs.ecs.io/quiesced-by"
+
+// IsQuiesced returns true while a backup stops the bookies and nodes
+func IsQuiesced(p *v1alpha1.ECSCluster) bool {
+	return p.Annotations[QuiesceAnnotation] != ""
+}
+
+// UsesHostNameAsBookieID returns true if the bookies register with their
+// hostname instead of their pod IP, which changes when a pod is recreated
+func UsesHostNameAsBookieID(p *v1alpha1.ECSCluster) bool {
+	return p.Spec.Bookkeeper.Options["useHostNameAsBookieID"] == "true"
+}
+
+func PvcNameForBackup(backupName string) string {
+	return fmt.Sprintf("%s-zookeeper", backupName)
+}
+
+func JobNameForBackup(backupName string) string {
+	return fmt.Sprintf("%s-zookeeper-export", backupName)
+}
+
+func JobNameForRestore(restoreName string) string {
+	return fmt.Sprintf("%s-zookeeper-import", restoreName)
+}
+
+func VolumeSnapshotNameForBackup(backupName string, pvcName string) string {
+	return fmt.Sprintf("%s-%s", backupName, pvcName)
+}
+
+// PvcNameForStatefulSet returns the name of the PVC created by a StatefulSet
+// for a volume claim template and a pod ordinal
+func PvcNameForStatefulSet(claimTemplate string, stsName string, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", claimTemplate, stsName, ordinal)
+}
+
+// ClaimTemplateForPvc returns the volume claim template and the pod ordinal of
+// a PVC created by a StatefulSet. The last return value is false when the PVC
+// was not created by the StatefulSet
+func ClaimTemplateForPvc(pvcName string, stsName string) (string, int32, bool) {
+	index := strings.LastIndex(pvcName, "-")
+	if index == -1 {
+		return "", 0, false
+	}
+
+	ordinal, err := strconv.Atoi(pvcName[index+1:])
+	if err != nil || ordinal < 0 {
+		return "", 0, false
+	}
+
+	suffix := fmt.Sprintf("-%s", stsName)
+	if !strings.HasSuffix(pvcName[:index], suffix) {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(pvcName[:index], suffix), int32(ordinal), true
+}
+
 func PvcIsOrphan(stsPvcName string, replicas int32) bool {
 	index := strings.LastIndexAny(stsPvcName, "-")
 	if index == -1 {
@@ -113,8 +316,9 @@ func ECSControllerServiceURL(ecsCluster v1alpha1.ECSCluster) string {
 	return fmt.Sprintf("tcp://%v.%v:%v", ServiceNameForController(ecsCluster.Name), ecsCluster.Namespace, "9090")
 }
 
-func HealthcheckCommand(port int32) []string {
-	return []string{"/bin/sh", "-c", fmt.Sprintf("netstat -ltn 2> /dev/null | grep %d || ss -ltn 2> /dev/null | grep %d", port, port)}
+// ECSControllerRESTURL is the base URL of the REST API of the controllers
+func ECSControllerRESTURL(ecsCluster v1alpha1.ECSCluster) string {
+	return fmt.Sprintf("http://%v.%v:%v", ServiceNameForController(ecsCluster.Name), ecsCluster.Namespace, v1alpha1.DefaultControllerRESTPort)
 }
 
 // Min returns the smaller of x or y.
@@ -134,6 +338,17 @@ func ContainsString(slice []string, str string) bool {
 	return false
 }
 
+// SortedKeys returns the keys of the map in lexical order, so that the
+// configuration generated from it is stable across reconciliations
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func RemoveString(slice []string, str string) (result []string) {
 	for _, item := range slice {
 		if item == str {
@@ -145,5 +360,12 @@ func RemoveString(slice []string, str string) (result []string) {
 }
 
 func GetClusterExpectedSize(p *v1alpha1.ECSCluster) (size int) {
-	return int(p.Spec.ECS.ControllerReplicas + p.Spec.ECS.NodeReplicas + p.Spec.Bookkeeper.Replicas)
+	size = int(p.Spec.ECS.ControllerReplicas + p.Spec.ECS.NodeReplicas + p.Spec.Bookkeeper.Replicas)
+	if p.Spec.Bookkeeper.StandaloneAutoRecovery() {
+		size += int(p.Spec.Bookkeeper.AutoRecoveryDeployment.Replicas)
+	}
+	if p.Spec.Zookeeper != nil {
+		size += int(p.Spec.Zookeeper.Replicas)
+	}
+	return size
 }
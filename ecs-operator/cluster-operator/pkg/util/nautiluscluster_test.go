@@ -0,0 +1,69 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"fmt"
+
+	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OrdinalForPod", func() {
+	It("should return the ordinal of a pod of the stateful-set", func() {
+		ordinal, ok := OrdinalForPod("example-bookie", "example-bookie-12")
+		Ω(ok).Should(BeTrue())
+		Ω(ordinal).Should(BeEquivalentTo(12))
+	})
+
+	It("should ignore the pods of other stateful-sets", func() {
+		_, ok := OrdinalForPod("example-bookie", "example-ecs-node-0")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should ignore the pods of a stateful-set with a longer name", func() {
+		_, ok := OrdinalForPod("example-bookie", "example-bookie-autorecovery-0")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should ignore names without an ordinal", func() {
+		for _, name := range []string{"example-bookie-", "example-bookie--1", "example-bookie"} {
+			_, ok := OrdinalForPod("example-bookie", name)
+			Ω(ok).Should(BeFalse(), name)
+		}
+	})
+})
+
+var _ = Describe("ClusterNameForLabels", func() {
+	It("should return the cluster of its resources", func() {
+		p := &v1alpha1.ECSCluster{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+		for _, labels := range []map[string]string{LabelsForECSCluster(p), LabelsForBookie(p)} {
+			name, ok := ClusterNameForLabels(labels)
+			Ω(ok).Should(BeTrue())
+			Ω(name).Should(Equal("example"))
+		}
+	})
+
+	It("should ignore the labels of other applications", func() {
+		for _, labels := range []map[string]string{
+			nil,
+			{"app": "zookeeper", "ecs_cluster": "example"},
+			{"app": "ecs-cluster"},
+			{"app": "ecs-cluster", "ecs_cluster": ""},
+		} {
+			_, ok := ClusterNameForLabels(labels)
+			Ω(ok).Should(BeFalse(), fmt.Sprintf("%v", labels))
+		}
+	})
+})
@@ -0,0 +1,160 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// Keys of the Tier 2 credentials Secret, as consumed by the ECS nodes
+	Tier2AccessKeyIdKey = "ACCESS_KEY_ID"
+	Tier2SecretKeyKey   = "SECRET_KEY"
+
+	tier2Timeout = 10 * time.Second
+
+	// Default port of the HDFS name node RPC service
+	defaultHdfsPort = "8020"
+
+	// Port of the NFS service
+	nfsPort = "2049"
+
+	// Region of the buckets created without a location constraint
+	defaultS3Region = "us-east-1"
+)
+
+// S3BucketConfig contains the details needed to reach a bucket in an
+// S3-compatible system
+type S3BucketConfig struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	PathStyleAccess bool
+	AccessKeyId     string
+	SecretKey       string
+	// CABundle is the PEM encoded CA bundle used to verify the endpoint.
+	// If empty, the system CAs are used
+	CABundle []byte
+	// CreateBucket creates the bucket if it does not exist
+	CreateBucket bool
+}
+
+// CheckS3Bucket verifies that the bucket exists and is accessible with the
+// given credentials, creating it if requested
+func CheckS3Bucket(c S3BucketConfig) (err error) {
+	httpClient := &http.Client{Timeout: tier2Timeout}
+	if len(c.CABundle) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(c.CABundle) {
+			return fmt.Errorf("failed to parse CA bundle")
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	config := &aws.Config{
+		Region:           aws.String(c.Region),
+		Credentials:      credentials.NewStaticCredentials(c.AccessKeyId, c.SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(c.PathStyleAccess),
+		HTTPClient:       httpClient,
+		MaxRetries:       aws.Int(1),
+	}
+	if c.Endpoint != "" {
+		config.Endpoint = aws.String(c.Endpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return fmt.Errorf("failed to create S3 session: %v", err)
+	}
+	client := s3.New(sess)
+
+	_, err = client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(c.Bucket)})
+	if err == nil {
+		return nil
+	}
+
+	if reqErr, ok := err.(awserr.RequestFailure); !ok || reqErr.StatusCode() != http.StatusNotFound {
+		return fmt.Errorf("failed to access bucket (%s): %v", c.Bucket, err)
+	}
+
+	if !c.CreateBucket {
+		return fmt.Errorf("bucket (%s) does not exist", c.Bucket)
+	}
+
+	// Buckets are created in us-east-1 unless another region is given, which
+	// us-east-1 itself rejects
+	input := &s3.CreateBucketInput{Bucket: aws.String(c.Bucket)}
+	if c.Region != "" && c.Region != defaultS3Region {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(c.Region),
+		}
+	}
+	_, err = client.CreateBucket(input)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket (%s): %v", c.Bucket, err)
+	}
+	return nil
+}
+
+// CheckHdfsReachable verifies that the HDFS name node in the given URI accepts
+// TCP connections
+func CheckHdfsReachable(uri string) (err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("failed to parse HDFS uri (%s): %v", uri, err)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("HDFS uri (%s) has no host", uri)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultHdfsPort
+	}
+
+	err = checkTCPReachable(u.Hostname(), port)
+	if err != nil {
+		return fmt.Errorf("failed to connect to HDFS name node: %v", err)
+	}
+	return nil
+}
+
+// CheckNfsReachable verifies that the NFS server accepts TCP connections
+func CheckNfsReachable(server string) (err error) {
+	err = checkTCPReachable(server, nfsPort)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NFS server: %v", err)
+	}
+	return nil
+}
+
+func checkTCPReachable(host string, port string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), tier2Timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
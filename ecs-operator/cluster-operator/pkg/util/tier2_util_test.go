@@ -0,0 +1,84 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckS3Bucket", func() {
+	var (
+		server     *httptest.Server
+		config     S3BucketConfig
+		bucketBody string
+		created    bool
+	)
+
+	BeforeEach(func() {
+		bucketBody = ""
+		created = false
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodHead:
+				if created {
+					return
+				}
+				w.WriteHeader(http.StatusNotFound)
+			case http.MethodPut:
+				body, _ := ioutil.ReadAll(r.Body)
+				bucketBody = string(body)
+				created = true
+			}
+		}))
+		config = S3BucketConfig{
+			Endpoint:        server.URL,
+			Region:          defaultS3Region,
+			Bucket:          "ecs",
+			PathStyleAccess: true,
+			AccessKeyId:     "access",
+			SecretKey:       "secret",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should fail when the bucket does not exist", func() {
+		Ω(CheckS3Bucket(config)).ShouldNot(BeNil())
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should create the bucket without location constraint in us-east-1", func() {
+		config.CreateBucket = true
+		Ω(CheckS3Bucket(config)).Should(BeNil())
+		Ω(created).Should(BeTrue())
+		Ω(bucketBody).ShouldNot(ContainSubstring("LocationConstraint"))
+	})
+
+	It("should create the bucket in its region", func() {
+		config.CreateBucket = true
+		config.Region = "eu-west-1"
+		Ω(CheckS3Bucket(config)).Should(BeNil())
+		Ω(bucketBody).Should(ContainSubstring("<LocationConstraint>eu-west-1</LocationConstraint>"))
+	})
+
+	It("should succeed when the bucket exists", func() {
+		created = true
+		Ω(CheckS3Bucket(config)).Should(BeNil())
+		Ω(bucketBody).Should(BeEmpty())
+	})
+})
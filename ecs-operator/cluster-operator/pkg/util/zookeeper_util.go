@@ -13,6 +13,9 @@ package util
 import (
 	"container/list"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ecs/ecs-operator/pkg/apis/ecs/v1alpha1"
@@ -25,12 +28,239 @@ const (
 	ZkFinalizer = "cleanUpZookeeper"
 )
 
+// connectZookeeper connects to a ZooKeeper ensemble and waits for a session.
+// Requests wait for a session indefinitely, so the session is awaited with a
+// timeout
+func connectZookeeper(zookeeperUri string) (*zk.Conn, error) {
+	host := []string{zookeeperUri}
+	conn, events, err := zk.Connect(host, time.Second*5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to zookeeper: %v", err)
+	}
+
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case event := <-events:
+			if event.State == zk.StateHasSession {
+				return conn, nil
+			}
+		case <-timeout:
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect to zookeeper: timed out")
+		}
+	}
+}
+
+// ProbeZookeeper connects to the ZooKeeper ensemble of a cluster and returns
+// the round trip time of a request and whether the root znode of the cluster
+// exists
+func ProbeZookeeper(p *v1alpha1.ECSCluster) (latency time.Duration, exist bool, err error) {
+	conn, err := connectZookeeper(p.Spec.ZookeeperUri)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	root := fmt.Sprintf("/%s/%s", ECSPath, p.Name)
+	start := time.Now()
+	exist, _, err = conn.Exists(root)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check if zookeeper path exists: %v", err)
+	}
+	return time.Since(start), exist, nil
+}
+
+// auditorBookieIdRegexp extracts the bookie ID from the text format of the
+// auditor election vote
+var auditorBookieIdRegexp = regexp.MustCompile(`bookieId:\s*"([^"]*)"`)
+
+// GetAuditor returns the bookie ID of the BookKeeper auditor elected among the
+// AutoRecovery processes, or an empty string if none is elected
+func GetAuditor(p *v1alpha1.ECSCluster) (string, error) {
+	conn, err := connectZookeeper(p.Spec.ZookeeperUri)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	election := fmt.Sprintf("/%s/%s/bookkeeper/ledgers/underreplication/auditorelection", ECSPath, p.Name)
+	votes, _, err := conn.Children(election)
+	if err == zk.ErrNoNode {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to list auditor election votes: %v", err)
+	}
+	if len(votes) == 0 {
+		return "", nil
+	}
+
+	// The vote with the lowest sequence number wins the election
+	sort.Strings(votes)
+	data, _, err := conn.Get(fmt.Sprintf("%s/%s", election, votes[0]))
+	if err == zk.ErrNoNode {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read auditor election vote: %v", err)
+	}
+
+	if match := auditorBookieIdRegexp.FindSubmatch(data); match != nil {
+		return string(match[1]), nil
+	}
+	return string(data), nil
+}
+
+// GetBookieRegistrations returns the IDs of the writable and of the read-only
+// bookies registered in ZooKeeper. Registrations are ephemeral znodes held by
+// the bookie processes
+func GetBookieRegistrations(p *v1alpha1.ECSCluster) (available []string, readOnly []string, err error) {
+	conn, err := connectZookeeper(p.Spec.ZookeeperUri)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	root := fmt.Sprintf("/%s/%s/bookkeeper/ledgers/available", ECSPath, p.Name)
+	children, _, err := conn.Children(root)
+	if err == zk.ErrNoNode {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list available bookies: %v", err)
+	}
+	for _, child := range children {
+		// Read-only bookies are registered under their own znode
+		if child != "readonly" {
+			available = append(available, child)
+		}
+	}
+
+	readOnly, _, err = conn.Children(fmt.Sprintf("%s/readonly", root))
+	if err != nil && err != zk.ErrNoNode {
+		return nil, nil, fmt.Errorf("failed to list read-only bookies: %v", err)
+	}
+
+	sort.Strings(available)
+	sort.Strings(readOnly)
+	return available, readOnly, nil
+}
+
+// CountUnderReplicatedLedgers returns the number of ledgers that the auditor
+// marked as under-replicated and that are not replicated yet
+func CountUnderReplicatedLedgers(p *v1alpha1.ECSCluster) (int, error) {
+	conn, err := connectZookeeper(p.Spec.ZookeeperUri)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	root := fmt.Sprintf("/%s/%s/bookkeeper/ledgers/underreplication/ledgers", ECSPath, p.Name)
+	exist, _, err := conn.Exists(root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if zookeeper path exists: %v", err)
+	}
+	if !exist {
+		return 0, nil
+	}
+
+	// Ledgers are stored under a hierarchy of znodes derived from their ID,
+	// in leaves named urL<ledger ID>
+	tree, err := ListSubTreeBFS(conn, root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to construct BFS tree: %v", err)
+	}
+
+	count := 0
+	for e := tree.Front(); e != nil; e = e.Next() {
+		path := e.Value.(string)
+		if strings.HasPrefix(path[strings.LastIndex(path, "/")+1:], "urL") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Znode is a persistent znode of a cluster exported by ExportZnodes. Its path
+// is relative to the root znode of the cluster
+type Znode struct {
+	Path string `json:"path"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// ExportZnodes returns the persistent znodes of a cluster, parents first.
+// Ephemeral znodes belong to running processes and are not exported
+func ExportZnodes(zookeeperUri string, clusterName string) ([]Znode, error) {
+	conn, err := connectZookeeper(zookeeperUri)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	root := fmt.Sprintf("/%s/%s", ECSPath, clusterName)
+	tree, err := ListSubTreeBFS(conn, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct BFS tree: %v", err)
+	}
+
+	var znodes []Znode
+	for e := tree.Front(); e != nil; e = e.Next() {
+		path := e.Value.(string)
+		data, stat, err := conn.Get(path)
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get znode (%s): %v", path, err)
+		}
+		if stat.EphemeralOwner != 0 {
+			continue
+		}
+		znodes = append(znodes, Znode{Path: strings.TrimPrefix(path, root), Data: data})
+	}
+	return znodes, nil
+}
+
+// ImportZnodes creates the exported znodes under the root znode of a cluster.
+// The root znode must not exist
+func ImportZnodes(zookeeperUri string, clusterName string, znodes []Znode) error {
+	conn, err := connectZookeeper(zookeeperUri)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	parent := fmt.Sprintf("/%s", ECSPath)
+	_, err = conn.Create(parent, nil, 0, zk.WorldACL(zk.PermAll))
+	if err != nil && err != zk.ErrNodeExists {
+		return fmt.Errorf("failed to create znode (%s): %v", parent, err)
+	}
+
+	root := fmt.Sprintf("%s/%s", parent, clusterName)
+	exist, _, err := conn.Exists(root)
+	if err != nil {
+		return fmt.Errorf("failed to check if zookeeper path exists: %v", err)
+	}
+	if exist {
+		return fmt.Errorf("znode (%s) already exists", root)
+	}
+
+	for _, znode := range znodes {
+		path := root + znode.Path
+		_, err = conn.Create(path, znode.Data, 0, zk.WorldACL(zk.PermAll))
+		if err != nil {
+			return fmt.Errorf("failed to create znode (%s): %v", path, err)
+		}
+	}
+	return nil
+}
+
 // Delete all znodes related to a specific ECS cluster
 func DeleteAllZnodes(p *v1alpha1.ECSCluster) (err error) {
-	host := []string{p.Spec.ZookeeperUri}
-	conn, _, err := zk.Connect(host, time.Second*5)
+	conn, err := connectZookeeper(p.Spec.ZookeeperUri)
 	if err != nil {
-		return fmt.Errorf("failed to connect to zookeeper: %v", err)
+		return err
 	}
 	defer conn.Close()
 